@@ -0,0 +1,224 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+	"todo-server/db"
+	"todo-server/internal"
+	"todo-server/models"
+	"todo-server/utils"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/go-chi/chi/v5"
+)
+
+func (h *HandlerFn) downloadAttachment(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid attachment ID", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	attachment, err := db.GetAttachment(h.DB, id)
+
+	if err == sql.ErrNoRows {
+		utils.JsonResponse(w, http.StatusNotFound, models.ErrorResponseV2{Message: fmt.Sprintf("Attachment with ID {%v} does not exist.", id), Status: http.StatusNotFound, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Fetching attachment failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	file, err := internal.OpenAttachmentFile(attachment.StorageKey)
+
+	if errors.Is(err, fs.ErrNotExist) {
+		utils.JsonResponse(w, http.StatusNotFound, models.ErrorResponseV2{Message: fmt.Sprintf("The file of attachment with ID {%v} is missing.", id), Status: http.StatusNotFound, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Reading attachment failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.FileName}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	// Files on disk support range requests, the ones from S3 are streamed through.
+	if seeker, ok := file.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, "", time.Time{}, seeker)
+
+		return
+	}
+
+	w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	io.Copy(w, file)
+}
+
+// uploadAttachment takes the "file" field of a multipart form. The content type
+// is detected from the content, the one sent by the client is ignored.
+func (h *HandlerFn) uploadAttachment(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid task ID", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	maxSize := internal.MaxAttachmentSize()
+
+	// Leaves room for the rest of the form.
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize+1<<20)
+
+	file, header, err := r.FormFile("file")
+
+	var maxBytesErr *http.MaxBytesError
+
+	if errors.As(err, &maxBytesErr) {
+		utils.JsonResponse(w, http.StatusRequestEntityTooLarge, models.ErrorResponseV2{Message: fmt.Sprintf("Attachments can be at most %d MB", maxSize>>20), Status: http.StatusRequestEntityTooLarge, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid request body, the file is expected in the \"file\" field of a multipart form", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Reading the file failed", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	if int64(len(data)) > maxSize {
+		utils.JsonResponse(w, http.StatusRequestEntityTooLarge, models.ErrorResponseV2{Message: fmt.Sprintf("Attachments can be at most %d MB", maxSize>>20), Status: http.StatusRequestEntityTooLarge, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	detected := mimetype.Detect(data)
+
+	fileName := filepath.Base(header.Filename)
+
+	if fileName == "." || fileName == "/" {
+		fileName = "attachment" + detected.Extension()
+	}
+
+	key, err := internal.SaveAttachmentFile(fileName, detected.String(), data)
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Saving the file failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	attachment := models.Attachment{
+		TaskID:      taskID,
+		FileName:    fileName,
+		ContentType: detected.String(),
+		Size:        int64(len(data)),
+		StorageKey:  key,
+	}
+
+	attachment.ID, err = db.InsertAttachment(h.DB, attachment)
+
+	if err != nil {
+		internal.RemoveAttachmentFiles([]string{key})
+	}
+
+	if err == sql.ErrNoRows {
+		utils.JsonResponse(w, http.StatusNotFound, models.ErrorResponseV2{Message: fmt.Sprintf("Task with ID {%v} does not exist.", taskID), Status: http.StatusNotFound, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Saving attachment failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	attachment.URL = internal.AttachmentURL(attachment.ID)
+
+	utils.JsonResponse(w, http.StatusCreated, models.Response{Data: attachment})
+}
+
+func (h *HandlerFn) taskAttachments(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid task ID", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	attachments, err := db.GetTaskAttachments(h.DB, taskID)
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Fetching attachments failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	for i := range attachments {
+		attachments[i].URL = internal.AttachmentURL(attachments[i].ID)
+	}
+
+	utils.JsonResponse(w, http.StatusOK, models.Response{Data: attachments})
+}
+
+// deleteAttachment removes the attachment right away, unlike tasks it does not go
+// to the trash. When removing the file fails, the cron job retries it.
+func (h *HandlerFn) deleteAttachment(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid attachment ID", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	key, err := db.DeleteAttachment(h.DB, id)
+
+	if err == sql.ErrNoRows {
+		utils.JsonResponse(w, http.StatusNotFound, models.ErrorResponseV2{Message: fmt.Sprintf("Attachment with ID {%v} does not exist.", id), Status: http.StatusNotFound, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Deleting attachment failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	if err := internal.AttachmentStorage().Delete(key); err == nil {
+		db.FinishAttachmentDeletion(h.DB, key)
+	}
+
+	utils.JsonResponse(w, http.StatusOK, models.MsgResponse{Message: fmt.Sprintf("Deleted attachment with ID {%v} successfully.", id)})
+}
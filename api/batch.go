@@ -0,0 +1,327 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"todo-server/db"
+	"todo-server/internal"
+	"todo-server/models"
+	"todo-server/utils"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// batchRun holds the transaction of a batch and the ids of the rows it created,
+// keyed by the client ids they were given.
+type batchRun struct {
+	tx       *sql.Tx
+	validate *validator.Validate
+	ids      map[string]int
+	// urls are the links of the written tasks, tracked once the batch commits.
+	urls []string
+}
+
+// batch replays the queued changes of an offline client in order and in one
+// transaction. Operations can refer to rows created earlier in the same batch by
+// their client_id. If any operation fails nothing is applied.
+func (h *HandlerFn) batch(w http.ResponseWriter, r *http.Request) {
+	var request models.BatchRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid request body", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	validate := validator.New()
+
+	if err := validate.Struct(request); err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{
+			Status:        http.StatusBadRequest,
+			Code:          internal.ErrorCodeValidationFailed,
+			Message:       "One or more fields are invalid",
+			InvalidFields: internal.ConstructInvalidFieldData(err)})
+
+		return
+	}
+
+	tx, err := h.DB.Begin()
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Running batch failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+	defer tx.Rollback()
+
+	run := batchRun{tx: tx, validate: validate, ids: map[string]int{}}
+	response := models.BatchResponse{IDs: run.ids, Results: []models.BatchResult{}}
+
+	for i, operation := range request.Operations {
+		id, failure := run.apply(operation)
+
+		if failure != nil {
+			failure.Message = fmt.Sprintf("Operation %d (%s) failed: %s", i, operation.Op, failure.Message)
+			utils.JsonResponse(w, failure.Status, failure)
+
+			return
+		}
+
+		if operation.ClientID != "" {
+			if _, taken := run.ids[operation.ClientID]; taken {
+				utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: fmt.Sprintf("Operation %d (%s) failed: client_id %q is used twice", i, operation.Op, operation.ClientID), Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+				return
+			}
+
+			run.ids[operation.ClientID] = id
+		}
+
+		response.Results = append(response.Results, models.BatchResult{Index: i, Op: operation.Op, ID: id})
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Running batch failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	internal.TrackLinks(h.DB, run.urls)
+
+	utils.JsonResponse(w, http.StatusOK, response)
+}
+
+func batchFailure(status int, message string, err error) *models.ErrorResponseV2 {
+	failure := models.ErrorResponseV2{Message: message, Status: status, Code: internal.ErrorCodeErrorMessage}
+
+	if err != nil {
+		failure.Error = err.Error()
+	}
+
+	return &failure
+}
+
+func (run *batchRun) resolve(ref models.BatchRef) (*int, *models.ErrorResponseV2) {
+	if ref.ClientID == "" {
+		return ref.ID, nil
+	}
+
+	id, found := run.ids[ref.ClientID]
+
+	if !found {
+		return nil, batchFailure(http.StatusBadRequest, fmt.Sprintf("client_id %q is not created by an earlier operation", ref.ClientID), nil)
+	}
+
+	return &id, nil
+}
+
+func (run *batchRun) decode(operation models.BatchOperation, v interface{}) *models.ErrorResponseV2 {
+	if len(operation.Data) == 0 {
+		return batchFailure(http.StatusBadRequest, "data is required", nil)
+	}
+
+	if err := json.Unmarshal(operation.Data, v); err != nil {
+		return batchFailure(http.StatusBadRequest, "Invalid data", err)
+	}
+
+	if err := run.validate.Struct(v); err != nil {
+		return &models.ErrorResponseV2{
+			Status:        http.StatusBadRequest,
+			Code:          internal.ErrorCodeValidationFailed,
+			Message:       "One or more fields are invalid",
+			InvalidFields: internal.ConstructInvalidFieldData(err)}
+	}
+
+	return nil
+}
+
+// update runs a single row update and fails when the row does not exist.
+func (run *batchRun) update(query string, args ...interface{}) *models.ErrorResponseV2 {
+	result, err := run.tx.Exec(query, args...)
+
+	if err != nil {
+		return batchFailure(http.StatusInternalServerError, "Updating failed", err)
+	}
+
+	if rf, _ := result.RowsAffected(); rf != 1 {
+		return batchFailure(http.StatusNotFound, "Not found", nil)
+	}
+
+	return nil
+}
+
+func (run *batchRun) apply(operation models.BatchOperation) (int, *models.ErrorResponseV2) {
+	switch operation.Op {
+	case "create-list":
+		var list models.List
+
+		if failure := run.decode(operation, &list); failure != nil {
+			return 0, failure
+		}
+
+		id, err := db.InsertList(run.tx, list)
+
+		if err == db.ErrInvalidGroup {
+			return 0, batchFailure(http.StatusBadRequest, "List group does not exist in the list's profile", err)
+		}
+
+		if err != nil {
+			return 0, batchFailure(http.StatusInternalServerError, "Creating list failed", err)
+		}
+
+		return id, nil
+	case "create-task":
+		var task models.Task
+
+		if failure := run.decode(operation, &task); failure != nil {
+			return 0, failure
+		}
+
+		if operation.ListID.IsSet() {
+			listID, failure := run.resolve(operation.ListID)
+
+			if failure != nil {
+				return 0, failure
+			}
+
+			task.ListID = listID
+		}
+
+		id, err := db.InsertTask(run.tx, task)
+
+		if err != nil {
+			return 0, batchFailure(http.StatusInternalServerError, "Creating task failed", err)
+		}
+
+		run.urls = append(run.urls, internal.TaskURLs(task.Name, task.Metadata)...)
+
+		return id, nil
+	case "create-sub-task":
+		var subTask models.SubTask
+
+		if failure := run.decode(operation, &subTask); failure != nil {
+			return 0, failure
+		}
+
+		if operation.TaskID.IsSet() {
+			taskID, failure := run.resolve(operation.TaskID)
+
+			if failure != nil {
+				return 0, failure
+			}
+
+			subTask.TaskID = *taskID
+		}
+
+		if operation.ParentID.IsSet() {
+			parentID, failure := run.resolve(operation.ParentID)
+
+			if failure != nil {
+				return 0, failure
+			}
+
+			subTask.ParentID = parentID
+		}
+
+		id, err := db.InsertSubTask(run.tx, subTask)
+
+		if err == sql.ErrNoRows {
+			return 0, batchFailure(http.StatusBadRequest, "task_id does not exist or parent_id is not a sub task of it", nil)
+		}
+
+		if err != nil {
+			return 0, batchFailure(http.StatusInternalServerError, "Creating sub task failed", err)
+		}
+
+		return id, nil
+	}
+
+	// Everything else works on an existing row.
+	ref, failure := run.resolve(operation.ID)
+
+	if failure != nil {
+		return 0, failure
+	}
+
+	if ref == nil {
+		return 0, batchFailure(http.StatusBadRequest, "id is required", nil)
+	}
+
+	id := *ref
+
+	switch operation.Op {
+	case "update-list":
+		var list models.List
+
+		if failure := run.decode(operation, &list); failure != nil {
+			return 0, failure
+		}
+
+		failure = run.update("UPDATE lists SET name = $1 WHERE id = $2 AND deleted_at IS NULL", list.Name, id)
+	case "update-task":
+		var task models.Task
+
+		if failure := run.decode(operation, &task); failure != nil {
+			return 0, failure
+		}
+
+		failure = run.update("UPDATE tasks SET name = $1 WHERE id = $2 AND deleted_at IS NULL", task.Name, id)
+		run.urls = append(run.urls, internal.TaskURLs(task.Name)...)
+	case "update-sub-task":
+		var subTask models.SubTask
+
+		if failure := run.decode(operation, &subTask); failure != nil {
+			return 0, failure
+		}
+
+		failure = run.update("UPDATE sub_tasks SET name = $1 WHERE id = $2 AND deleted_at IS NULL", subTask.Name, id)
+	case "toggle-task":
+		var exists bool
+
+		if err := run.tx.QueryRow("SELECT EXISTS (SELECT 1 FROM tasks WHERE id = $1 AND deleted_at IS NULL)", id).Scan(&exists); err != nil {
+			return 0, batchFailure(http.StatusInternalServerError, "Toggling task failed", err)
+		}
+
+		if !exists {
+			return 0, batchFailure(http.StatusNotFound, "Not found", nil)
+		}
+
+		if err := db.ToggleTaskAndHandleRecurrence(run.tx, id); err != nil {
+			return 0, batchFailure(http.StatusInternalServerError, "Toggling task failed", err)
+		}
+	case "toggle-sub-task":
+		_, _, err := db.ToggleSubTask(run.tx, id)
+
+		if err == sql.ErrNoRows {
+			return 0, batchFailure(http.StatusNotFound, "Not found", nil)
+		}
+
+		if err != nil {
+			return 0, batchFailure(http.StatusInternalServerError, "Toggling sub task failed", err)
+		}
+	case "delete-task", "delete-sub-task":
+		softDelete := db.SoftDeleteTask
+
+		if operation.Op == "delete-sub-task" {
+			softDelete = db.SoftDeleteSubTask
+		}
+
+		ok, err := softDelete(run.tx, id)
+
+		if err != nil {
+			return 0, batchFailure(http.StatusInternalServerError, "Deleting failed", err)
+		}
+
+		if !ok {
+			return 0, batchFailure(http.StatusNotFound, "Not found", nil)
+		}
+	}
+
+	if failure != nil {
+		return 0, failure
+	}
+
+	return id, nil
+}
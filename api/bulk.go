@@ -0,0 +1,260 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+	"todo-server/db"
+	"todo-server/internal"
+	"todo-server/internal/query"
+	"todo-server/models"
+	"todo-server/utils"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/lib/pq"
+)
+
+const maxBulkTasks = 1000
+
+type bulkTaskState struct {
+	completed bool
+	important bool
+	inMyDay   bool
+	dueDate   string
+	listID    *int
+}
+
+// bulkTasks runs one operation over many tasks in a single transaction. Tasks that
+// are missing or already in the requested state are reported per item instead of
+// failing the request; any database error rolls back the whole batch.
+func (h *HandlerFn) bulkTasks(w http.ResponseWriter, r *http.Request) {
+	var bulk models.BulkTasks
+
+	if err := json.NewDecoder(r.Body).Decode(&bulk); err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid request body", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	validate := validator.New()
+
+	if err := validate.Struct(bulk); err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{
+			Status:        http.StatusBadRequest,
+			Code:          internal.ErrorCodeValidationFailed,
+			Message:       "One or more fields are invalid",
+			InvalidFields: internal.ConstructInvalidFieldData(err)})
+
+		return
+	}
+
+	if len(bulk.IDs) == 0 && bulk.Filter == nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Either ids or filter is required", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	if bulk.Operation == "set-due-date" && bulk.DueDate != "" {
+		if _, err := time.Parse("2006-01-02", bulk.DueDate); err != nil {
+			utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid Due Date", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+			return
+		}
+	}
+
+	value := bulk.Value == nil || *bulk.Value
+
+	tx, err := h.DB.Begin()
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Bulk update failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+	defer tx.Rollback()
+
+	ids := bulk.IDs
+
+	if len(ids) == 0 {
+		if ids, err = filteredTaskIDs(tx, *bulk.Filter); err != nil {
+			utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Fetching tasks failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+			return
+		}
+	}
+
+	if len(ids) > maxBulkTasks {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: fmt.Sprintf("At most %d tasks can be changed at once", maxBulkTasks), Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	if bulk.Operation == "move" && bulk.ListID != nil {
+		var exists bool
+
+		if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM lists WHERE id = $1 AND deleted_at IS NULL)", *bulk.ListID).Scan(&exists); err != nil || !exists {
+			utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: fmt.Sprintf("List with ID {%v} does not exist.", *bulk.ListID), Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+			return
+		}
+	}
+
+	states, err := bulkTaskStates(tx, ids)
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Fetching tasks failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	response := models.BulkResponse{Operation: bulk.Operation, Results: []models.BulkResult{}}
+	seen := map[int]bool{}
+
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+
+		seen[id] = true
+
+		state, found := states[id]
+
+		if !found {
+			response.Results = append(response.Results, models.BulkResult{ID: id, Status: "not_found"})
+			continue
+		}
+
+		changed, err := applyBulkOperation(tx, bulk, value, id, state)
+
+		if err != nil {
+			utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: fmt.Sprintf("Bulk update failed at task with ID {%v}. Nothing was changed.", id), Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+			return
+		}
+
+		status := "unchanged"
+
+		if changed {
+			status = "updated"
+			response.Updated++
+		}
+
+		response.Results = append(response.Results, models.BulkResult{ID: id, Status: status})
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Bulk update failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusOK, response)
+}
+
+func filteredTaskIDs(tx *sql.Tx, filter models.TaskFilter) ([]int, error) {
+	tasksQuery, args := query.GetTasksQuery(filter.Filter, filter.Query, filter.ShowCompleted, 0, filter.ListID, filter.ShowAllTasks, filter.ProfileID, "", filter.Priority)
+
+	rows, err := tx.Query("SELECT id FROM ("+tasksQuery+") filtered", args...)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+
+	for rows.Next() {
+		var id int
+
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// bulkTaskStates locks the tasks for the rest of the transaction.
+func bulkTaskStates(tx *sql.Tx, ids []int) (map[int]bulkTaskState, error) {
+	query := `
+	SELECT
+		id,
+		completed,
+		is_important,
+		marked_today != '' AND marked_today::DATE = CURRENT_DATE,
+		due_date,
+		list_id
+	FROM tasks
+	WHERE id = ANY($1) AND deleted_at IS NULL
+	FOR UPDATE
+	`
+
+	rows, err := tx.Query(query, pq.Array(ids))
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	states := map[int]bulkTaskState{}
+
+	for rows.Next() {
+		var id int
+		var state bulkTaskState
+
+		if err := rows.Scan(&id, &state.completed, &state.important, &state.inMyDay, &state.dueDate, &state.listID); err != nil {
+			return nil, err
+		}
+
+		states[id] = state
+	}
+
+	return states, rows.Err()
+}
+
+func applyBulkOperation(tx *sql.Tx, bulk models.BulkTasks, value bool, id int, state bulkTaskState) (bool, error) {
+	var err error
+
+	switch bulk.Operation {
+	case "complete":
+		if state.completed == value {
+			return false, nil
+		}
+
+		err = db.ToggleTaskAndHandleRecurrence(tx, id)
+	case "delete":
+		return db.SoftDeleteTask(tx, id)
+	case "move":
+		if db.SameID(state.listID, bulk.ListID) {
+			return false, nil
+		}
+
+		_, err = tx.Exec("UPDATE tasks SET list_id = $1 WHERE id = $2", bulk.ListID, id)
+	case "set-due-date":
+		if state.dueDate == bulk.DueDate {
+			return false, nil
+		}
+
+		_, err = tx.Exec("UPDATE tasks SET due_date = $1 WHERE id = $2", bulk.DueDate, id)
+	case "mark-important":
+		if state.important == value {
+			return false, nil
+		}
+
+		_, err = tx.Exec("UPDATE tasks SET is_important = $1, priority = CASE WHEN $1 THEN 'high' ELSE 'none' END::task_priority_enum WHERE id = $2", value, id)
+	case "add-to-my-day":
+		if state.inMyDay == value {
+			return false, nil
+		}
+
+		_, err = tx.Exec("UPDATE tasks SET marked_today = CASE WHEN $1 THEN CURRENT_TIMESTAMP::TEXT ELSE '' END WHERE id = $2", value, id)
+	default:
+		return false, fmt.Errorf("unknown operation %q", bulk.Operation)
+	}
+
+	return err == nil, err
+}
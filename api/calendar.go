@@ -0,0 +1,311 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"todo-server/db"
+	"todo-server/export"
+	"todo-server/internal"
+	"todo-server/models"
+	"todo-server/utils"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func feedURL(r *http.Request, token string) string {
+	return fmt.Sprintf("%s/calendar/%s.ics", internal.PublicBaseURL(r), token)
+}
+
+func (h *HandlerFn) createCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	var feed models.CalendarFeed
+
+	if err := json.NewDecoder(r.Body).Decode(&feed); err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid request body", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	if feed.ListID != nil {
+		var listProfileID *int
+
+		err := h.DB.QueryRow("SELECT profile_id FROM lists WHERE id = $1 AND deleted_at IS NULL", *feed.ListID).Scan(&listProfileID)
+
+		if err == sql.ErrNoRows || (err == nil && !db.SameID(listProfileID, feed.ProfileID)) {
+			utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: fmt.Sprintf("List with ID {%v} does not exist in the profile.", *feed.ListID), Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+			return
+		}
+
+		if err != nil {
+			utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Creating calendar feed failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+			return
+		}
+	}
+
+	token, err := internal.GenerateToken()
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Generating feed token failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	query := `
+	INSERT INTO calendar_feeds (token, profile_id, list_id)
+	VALUES ($1, $2, $3)
+	RETURNING id, created_at;
+	`
+
+	err = h.DB.QueryRow(query, token, feed.ProfileID, feed.ListID).Scan(&feed.ID, &feed.CreatedAt)
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Creating calendar feed failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	feed.Token = token
+	feed.URL = feedURL(r, token)
+
+	utils.JsonResponse(w, http.StatusCreated, models.Response{Data: feed})
+}
+
+func (h *HandlerFn) calendarFeeds(w http.ResponseWriter, r *http.Request) {
+	rows, err := h.DB.Query("SELECT id, token, profile_id, list_id, created_at FROM calendar_feeds ORDER BY created_at DESC")
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.MsgResponse{Message: err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	feeds := []models.CalendarFeed{}
+
+	for rows.Next() {
+		var feed models.CalendarFeed
+		if err := rows.Scan(&feed.ID, &feed.Token, &feed.ProfileID, &feed.ListID, &feed.CreatedAt); err != nil {
+			utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: err.Error(), Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage})
+
+			return
+		}
+
+		feed.URL = feedURL(r, feed.Token)
+		feeds = append(feeds, feed)
+	}
+
+	utils.JsonResponse(w, http.StatusOK, models.Response{Data: feeds})
+}
+
+func (h *HandlerFn) deleteCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, id_err := strconv.Atoi(idStr)
+
+	if id_err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid calendar feed ID", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	result, error := h.DB.Exec("DELETE FROM calendar_feeds where id = $1", id)
+
+	if error != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Deleting calendar feed failed", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	if rf, _ := result.RowsAffected(); rf != 1 {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: fmt.Sprintf("Calendar feed with ID {%v} does not exist.", id), Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusOK, models.MsgResponse{Message: fmt.Sprintf("Deleted calendar feed with ID {%v} successfully.", id)})
+}
+
+func (h *HandlerFn) feedByToken(token string) (*models.CalendarFeed, error) {
+	var feed models.CalendarFeed
+
+	err := h.DB.QueryRow("SELECT id, token, profile_id, list_id FROM calendar_feeds WHERE token = $1", token).
+		Scan(&feed.ID, &feed.Token, &feed.ProfileID, &feed.ListID)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return &feed, err
+}
+
+// calendarFeed is the subscription URL handed to calendar apps. It is not behind
+// the API key; the secret token in the URL is the authentication.
+func (h *HandlerFn) calendarFeed(w http.ResponseWriter, r *http.Request) {
+	feed, err := h.feedByToken(chi.URLParam(r, "token"))
+
+	if err != nil {
+		http.Error(w, "Failed to load calendar feed", http.StatusInternalServerError)
+		return
+	}
+
+	if feed == nil {
+		http.Error(w, "Calendar feed not found", http.StatusNotFound)
+		return
+	}
+
+	title := "MKTodo"
+
+	if feed.ListID != nil {
+		err := h.DB.QueryRow("SELECT name FROM lists WHERE id = $1 AND deleted_at IS NULL", *feed.ListID).Scan(&title)
+
+		if err == sql.ErrNoRows {
+			http.Error(w, "The list of the calendar feed is in the trash", http.StatusNotFound)
+			return
+		}
+
+		if err != nil {
+			http.Error(w, "Failed to load calendar feed", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	query := `
+	SELECT
+		t.id,
+		t.name,
+		t.completed,
+		t.completed_on,
+		t.is_important,
+		t.due_date,
+		t.metadata,
+		COALESCE(t.recurrence_pattern::TEXT, ''),
+		COALESCE(t.recurrence_interval, 0)
+	FROM tasks t
+	WHERE t.due_date != ''
+		AND t.deleted_at IS NULL
+		AND t.profile_id IS NOT DISTINCT FROM $1
+		AND ($2::INT IS NULL OR t.list_id = $2)
+	ORDER BY t.due_date ASC
+	`
+
+	rows, err := h.DB.Query(query, feed.ProfileID, feed.ListID)
+
+	if err != nil {
+		http.Error(w, "Failed to load tasks", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	var tasks []models.Task
+
+	for rows.Next() {
+		var task models.Task
+
+		if err := rows.Scan(&task.ID, &task.Name, &task.Completed, &task.CompletedOn, &task.IsImportant, &task.DueDate, &task.Metadata, &task.RecurrencePattern, &task.RecurrenceInterval); err != nil {
+			http.Error(w, "Failed to load tasks", http.StatusInternalServerError)
+			return
+		}
+
+		tasks = append(tasks, task)
+	}
+
+	component := export.ComponentTodo
+
+	if r.URL.Query().Get("component") == "vevent" {
+		component = export.ComponentEvent
+	}
+
+	writer := export.NewICSWriter(w, component, func(id int) string {
+		return fmt.Sprintf("%s/calendar/%s/task-%d.ics", internal.PublicBaseURL(r), feed.Token, id)
+	})
+
+	w.Header().Set("Content-Type", writer.ContentType())
+
+	// The status is sent with the first write, failures can only be logged.
+	if err := writer.Begin(title); err != nil {
+		log.Println("Writing calendar feed failed", err)
+		return
+	}
+
+	for _, task := range tasks {
+		if err := writer.WriteTask(task); err != nil {
+			log.Println("Writing calendar feed failed", err)
+			return
+		}
+	}
+
+	if err := writer.End(); err != nil {
+		log.Println("Writing calendar feed failed", err)
+	}
+}
+
+// updateTaskFromCalendar lets CalDAV style clients tick off a task by PUTting the
+// VTODO back with STATUS:COMPLETED (or NEEDS-ACTION to reopen it).
+func (h *HandlerFn) updateTaskFromCalendar(w http.ResponseWriter, r *http.Request) {
+	feed, err := h.feedByToken(chi.URLParam(r, "token"))
+
+	if err != nil {
+		http.Error(w, "Failed to load calendar feed", http.StatusInternalServerError)
+		return
+	}
+
+	if feed == nil {
+		http.Error(w, "Calendar feed not found", http.StatusNotFound)
+		return
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	status, err := export.ParseTodoStatus(body)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var completed bool
+
+	query := `
+	SELECT completed FROM tasks
+	WHERE id = $1
+		AND deleted_at IS NULL
+		AND profile_id IS NOT DISTINCT FROM $2
+		AND ($3::INT IS NULL OR list_id = $3)
+	`
+
+	if err := h.DB.QueryRow(query, id, feed.ProfileID, feed.ListID).Scan(&completed); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Task not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to load task", http.StatusInternalServerError)
+		}
+
+		return
+	}
+
+	if completed != (status == "COMPLETED") {
+		if err := db.ToggleTaskAndHandleRecurrence(h.DB, id); err != nil {
+			http.Error(w, "Toggling task failed", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
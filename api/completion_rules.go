@@ -0,0 +1,66 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"todo-server/internal"
+	"todo-server/models"
+	"todo-server/utils"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func (h *HandlerFn) updateListCompletionRule(w http.ResponseWriter, r *http.Request) {
+	h.updateCompletionRule(w, r, "lists", "list")
+}
+
+func (h *HandlerFn) updateProfileCompletionRule(w http.ResponseWriter, r *http.Request) {
+	h.updateCompletionRule(w, r, "profiles", "profile")
+}
+
+// updateCompletionRule sets whether completing the last sub task completes the
+// task for the tasks of a list or a profile.
+func (h *HandlerFn) updateCompletionRule(w http.ResponseWriter, r *http.Request, table string, what string) {
+	id, id_err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if id_err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: fmt.Sprintf("Invalid %s ID", what), Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	var rule models.CompletionRule
+
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid request body", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	// Only lists have something to fall back to.
+	if rule.AutoCompleteParent == nil && table == "profiles" {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "auto_complete_parent is required", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET auto_complete_parent = $1 WHERE id = $2 AND deleted_at IS NULL", table)
+
+	result, err := h.DB.Exec(query, rule.AutoCompleteParent, id)
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: fmt.Sprintf("Updating %s with ID {%v} failed.", what, id), Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	if rf, _ := result.RowsAffected(); rf != 1 {
+		utils.JsonResponse(w, http.StatusNotFound, models.ErrorResponseV2{Message: fmt.Sprintf("The %s with ID {%v} does not exist.", what, id), Status: http.StatusNotFound, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusOK, models.MsgResponse{Message: fmt.Sprintf("Updated %s successfully.", what)})
+}
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"todo-server/db"
+	"todo-server/internal"
+	"todo-server/models"
+	"todo-server/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+)
+
+func (h *HandlerFn) blockers(w http.ResponseWriter, r *http.Request) {
+	id, id_err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if id_err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid task ID", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	blockers, err := db.GetBlockers(h.DB, id)
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Fetching blockers failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusOK, models.Response{Data: blockers})
+}
+
+// addBlocker records that the task can only be done after blocker_id is completed.
+func (h *HandlerFn) addBlocker(w http.ResponseWriter, r *http.Request) {
+	id, id_err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if id_err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid task ID", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	var payload models.TaskBlocker
+
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid request body", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	validate := validator.New()
+
+	if err := validate.Struct(payload); err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{
+			Status:        http.StatusBadRequest,
+			Code:          internal.ErrorCodeValidationFailed,
+			Message:       "One or more fields are invalid",
+			InvalidFields: internal.ConstructInvalidFieldData(err)})
+
+		return
+	}
+
+	if payload.BlockerID == id {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "A task cannot block itself", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	tx, err := h.DB.Begin()
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Adding blocker failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+	defer tx.Rollback()
+
+	var found int
+
+	if err := tx.QueryRow("SELECT COUNT(*) FROM tasks WHERE id IN ($1, $2) AND deleted_at IS NULL", id, payload.BlockerID).Scan(&found); err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Adding blocker failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	if found != 2 {
+		utils.JsonResponse(w, http.StatusNotFound, models.ErrorResponseV2{Message: fmt.Sprintf("Task with ID {%v} or {%v} does not exist.", id, payload.BlockerID), Status: http.StatusNotFound, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	err = db.AddBlocker(tx, id, payload.BlockerID)
+
+	if err == db.ErrDependencyCycle {
+		utils.JsonResponse(w, http.StatusConflict, models.ErrorResponseV2{Message: fmt.Sprintf("Task with ID {%v} already waits for task {%v}.", payload.BlockerID, id), Status: http.StatusConflict, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	if err == nil {
+		err = tx.Commit()
+	}
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Adding blocker failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusCreated, models.MsgResponse{Message: fmt.Sprintf("Task with ID {%v} is now blocked by task {%v}.", id, payload.BlockerID)})
+}
+
+func (h *HandlerFn) removeBlocker(w http.ResponseWriter, r *http.Request) {
+	id, id_err := strconv.Atoi(chi.URLParam(r, "id"))
+	blockerID, blocker_err := strconv.Atoi(chi.URLParam(r, "blocker_id"))
+
+	if id_err != nil || blocker_err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid task ID", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	result, err := h.DB.Exec("DELETE FROM task_dependencies WHERE task_id = $1 AND blocker_id = $2", id, blockerID)
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Removing blocker failed", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	if rf, _ := result.RowsAffected(); rf != 1 {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: fmt.Sprintf("Task {%v} is not blocking task with ID {%v}.", blockerID, id), Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusOK, models.MsgResponse{Message: fmt.Sprintf("Removed blocker {%v} from task with ID {%v} successfully.", blockerID, id)})
+}
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"todo-server/db"
+	"todo-server/internal"
+	"todo-server/models"
+	"todo-server/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+)
+
+func decodeDuplicate(w http.ResponseWriter, r *http.Request) (*models.Duplicate, bool) {
+	var options models.Duplicate
+
+	// The body is optional, a plain copy is made without one.
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&options); err != nil {
+			utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid request body", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+			return nil, false
+		}
+	}
+
+	if err := validator.New().Struct(options); err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{
+			Status:        http.StatusBadRequest,
+			Code:          internal.ErrorCodeValidationFailed,
+			Message:       "One or more fields are invalid",
+			InvalidFields: internal.ConstructInvalidFieldData(err)})
+
+		return nil, false
+	}
+
+	return &options, true
+}
+
+func (h *HandlerFn) duplicateTask(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid task ID", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	options, ok := decodeDuplicate(w, r)
+
+	if !ok {
+		return
+	}
+
+	tx, err := h.DB.Begin()
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Duplicating task failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+	defer tx.Rollback()
+
+	var listID, profileID *int
+
+	err = tx.QueryRow("SELECT list_id, profile_id FROM tasks WHERE id = $1 AND deleted_at IS NULL", id).Scan(&listID, &profileID)
+
+	if err == sql.ErrNoRows {
+		utils.JsonResponse(w, http.StatusNotFound, models.ErrorResponseV2{Message: fmt.Sprintf("Task with ID {%v} does not exist.", id), Status: http.StatusNotFound, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	if err == nil && options.ProfileID != nil && !db.SameID(options.ProfileID, profileID) {
+		profileID = options.ProfileID
+		listID = nil
+	}
+
+	if err == nil && options.ListID != nil {
+		var listProfileID *int
+
+		err = tx.QueryRow("SELECT profile_id FROM lists WHERE id = $1 AND deleted_at IS NULL", *options.ListID).Scan(&listProfileID)
+
+		if err == sql.ErrNoRows || (err == nil && options.ProfileID != nil && !db.SameID(options.ProfileID, listProfileID)) {
+			utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: fmt.Sprintf("List with ID {%v} does not exist in the profile.", *options.ListID), Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+			return
+		}
+
+		listID = options.ListID
+		profileID = listProfileID
+	}
+
+	var taskID int
+
+	if err == nil {
+		taskID, err = db.DuplicateTask(tx, id, options.Name, listID, profileID, *options, false)
+	}
+
+	if err == nil {
+		err = tx.Commit()
+	}
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Duplicating task failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	h.trackLinks("id", taskID)
+
+	utils.JsonResponse(w, http.StatusCreated, models.CreateTaskResponse{Message: "Task duplicated successfully", ID: taskID})
+}
+
+func (h *HandlerFn) duplicateList(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid list ID", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	options, ok := decodeDuplicate(w, r)
+
+	if !ok {
+		return
+	}
+
+	tx, err := h.DB.Begin()
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Duplicating list failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+	defer tx.Rollback()
+
+	listID, err := db.DuplicateList(tx, id, *options)
+
+	if err == sql.ErrNoRows {
+		utils.JsonResponse(w, http.StatusNotFound, models.ErrorResponseV2{Message: fmt.Sprintf("List with ID {%v} does not exist.", id), Status: http.StatusNotFound, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	if err == nil {
+		err = tx.Commit()
+	}
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Duplicating list failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	h.trackLinks("list_id", listID)
+
+	utils.JsonResponse(w, http.StatusCreated, models.CreateTaskResponse{Message: "List duplicated successfully", ID: listID})
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"todo-server/internal"
+	"todo-server/models"
+	"todo-server/utils"
+
+	"github.com/lib/pq"
+)
+
+// updateIfMatch sets one column of a row, honouring the If-Match header of the
+// request. On success the new ETag is set and true is returned. When the row has
+// moved on since the client read it, 412 is answered with the current copy so the
+// client can merge and retry.
+func (h *HandlerFn) updateIfMatch(w http.ResponseWriter, r *http.Request, table string, what string, id int, column string, value interface{}) bool {
+	revisions := internal.IfMatch(r.Header.Get("If-Match"))
+
+	query := fmt.Sprintf(`
+	UPDATE %s SET %s = $1
+	WHERE id = $2 AND deleted_at IS NULL AND ($3::BIGINT[] IS NULL OR revision = ANY($3))
+	RETURNING revision
+	`, table, column)
+
+	var revision int64
+
+	err := h.DB.QueryRow(query, value, id, pq.Array(revisions)).Scan(&revision)
+
+	if err == nil {
+		w.Header().Set("ETag", internal.ETag(revision))
+
+		return true
+	}
+
+	if err != sql.ErrNoRows {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: fmt.Sprintf("Updating %s with ID {%v} failed.", what, id), Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return false
+	}
+
+	var current json.RawMessage
+
+	err = h.DB.QueryRow(fmt.Sprintf("SELECT revision, to_jsonb(x) FROM %s x WHERE id = $1 AND deleted_at IS NULL", table), id).Scan(&revision, &current)
+
+	if err == sql.ErrNoRows {
+		utils.JsonResponse(w, http.StatusNotFound, models.ErrorResponseV2{Message: fmt.Sprintf("Updating %s with ID {%v} failed. It may not be available.", what, id), Status: http.StatusNotFound, Code: internal.ErrorCodeErrorMessage})
+
+		return false
+	}
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: fmt.Sprintf("Updating %s with ID {%v} failed.", what, id), Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return false
+	}
+
+	w.Header().Set("ETag", internal.ETag(revision))
+	utils.JsonResponse(w, http.StatusPreconditionFailed, models.ConflictResponse{
+		ErrorResponseV2: models.ErrorResponseV2{Message: fmt.Sprintf("The %s with ID {%v} was changed by someone else.", what, id), Status: http.StatusPreconditionFailed, Code: internal.ErrorCodeErrorMessage},
+		Current:         current,
+	})
+
+	return false
+}
@@ -0,0 +1,171 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+	"todo-server/internal"
+	"todo-server/models"
+	"todo-server/utils"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+)
+
+// keepAlive is how often an idle stream is pinged so that proxies keep it open.
+const keepAlive = 25 * time.Second
+
+// subscribeEvents subscribes to the profile_id query parameter. Without one the
+// events of the rows without a profile are sent; profile_id=all sends every
+// profile's.
+func (h *HandlerFn) subscribeEvents(w http.ResponseWriter, r *http.Request) (<-chan models.Event, func(), bool) {
+	profileId := r.URL.Query().Get("profile_id")
+
+	switch profileId {
+	case "all":
+		events, unsubscribe := h.Events.SubscribeAll()
+
+		return events, unsubscribe, true
+	case "", "null":
+		events, unsubscribe := h.Events.Subscribe(nil)
+
+		return events, unsubscribe, true
+	}
+
+	id, err := strconv.Atoi(profileId)
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Profile ID is not valid", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return nil, nil, false
+	}
+
+	events, unsubscribe := h.Events.Subscribe(&id)
+
+	return events, unsubscribe, true
+}
+
+// events streams the changes of a profile as Server-Sent Events. The id of each
+// event is its revision. A client that reconnects catches up through the sync
+// endpoint with the token of its last sync.
+func (h *HandlerFn) events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+
+	if !ok {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Streaming is not supported", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	events, unsubscribe, ok := h.subscribeEvents(w, r)
+
+	if !ok {
+		return
+	}
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprint(w, "retry: 3000\n\n")
+	flusher.Flush()
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case event := <-events:
+			data, _ := json.Marshal(event)
+
+			if event.Action == "resync" {
+				fmt.Fprintf(w, "event: resync\ndata: %s\n\n", data)
+			} else {
+				fmt.Fprintf(w, "id: %d\nevent: %s.%s\ndata: %s\n\n", event.Revision, event.Table, event.Action, data)
+			}
+		}
+
+		flusher.Flush()
+	}
+}
+
+// lockedWriter lets the reader of a WebSocket answer pings while events are
+// being written.
+type lockedWriter struct {
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+func (l lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.w.Write(p)
+}
+
+// eventsWebSocket sends the same events as events, one JSON text message each.
+// Messages from the client are ignored.
+func (h *HandlerFn) eventsWebSocket(w http.ResponseWriter, r *http.Request) {
+	events, unsubscribe, ok := h.subscribeEvents(w, r)
+
+	if !ok {
+		return
+	}
+	defer unsubscribe()
+
+	conn, _, _, err := ws.UpgradeHTTP(r, w)
+
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	writer := lockedWriter{mu: &sync.Mutex{}, w: conn}
+	closed := make(chan struct{})
+
+	go func() {
+		defer close(closed)
+
+		rw := struct {
+			io.Reader
+			io.Writer
+		}{conn, writer}
+
+		for {
+			if _, _, err := wsutil.ReadClientData(rw); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+
+	for {
+		var err error
+
+		select {
+		case <-closed:
+			return
+		case <-ticker.C:
+			err = wsutil.WriteServerMessage(writer, ws.OpPing, nil)
+		case event := <-events:
+			data, _ := json.Marshal(event)
+			err = wsutil.WriteServerText(writer, data)
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
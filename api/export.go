@@ -0,0 +1,111 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+	"todo-server/db"
+	"todo-server/export"
+	"todo-server/internal"
+	"todo-server/models"
+	"todo-server/utils"
+
+	query "todo-server/internal/query"
+)
+
+func (h *HandlerFn) export(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	filter := r.URL.Query().Get("filter")
+	searchTerm := r.URL.Query().Get("query")
+	showCompleted := r.URL.Query().Get("showCompleted")
+	listID := internal.ParseSize(r.URL.Query().Get("list_id"))
+	showAllTasks := r.URL.Query().Get("show_all_tasks")
+	profileID := internal.ParseSize(r.URL.Query().Get("profile_id"))
+	sortBy := r.URL.Query().Get("sort")
+
+	writer, err := export.NewWriter(format, w)
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: err.Error(), Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	title := "Tasks"
+
+	if listID != nil {
+		if err := h.DB.QueryRow("SELECT name FROM lists WHERE id = $1 AND deleted_at IS NULL", *listID).Scan(&title); err != nil {
+			if err == sql.ErrNoRows {
+				utils.JsonResponse(w, http.StatusNotFound, models.ErrorResponseV2{Message: fmt.Sprintf("List with ID {%v} does not exist.", *listID), Status: http.StatusNotFound, Code: internal.ErrorCodeErrorMessage})
+			} else {
+				utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Failed to get list details", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+			}
+
+			return
+		}
+	}
+
+	query, args := query.GetTasksQuery(filter, searchTerm, showCompleted, 0, listID, showAllTasks, profileID, sortBy, "")
+
+	rows, err := h.DB.Query(query, args...)
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.MsgResponse{Message: err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	tasks, err := db.ScanTasks(rows)
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: err.Error(), Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	taskIDs := make([]int, len(tasks))
+
+	for i, task := range tasks {
+		taskIDs[i] = task.ID
+	}
+
+	subTasks, err := db.GetSubTasksByTaskIDs(h.DB, taskIDs)
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Failed to fetch sub tasks", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	filename := fmt.Sprintf("tasks-%s.%s", time.Now().Format("2006-01-02"), writer.Extension())
+
+	w.Header().Set("Content-Type", writer.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+
+	flusher, _ := w.(http.Flusher)
+
+	if err := writer.Begin(title); err != nil {
+		log.Println("Writing export failed", err)
+		return
+	}
+
+	for _, task := range tasks {
+		task.SubTasks = subTasks[task.ID]
+
+		if err := writer.WriteTask(task); err != nil {
+			log.Println("Writing export failed", err)
+			return
+		}
+
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	if err := writer.End(); err != nil {
+		log.Println("Writing export failed", err)
+	}
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"todo-server/importer"
+	"todo-server/internal"
+	"todo-server/models"
+	"todo-server/utils"
+)
+
+const maxImportSize = 10 << 20 // 10MB
+
+// importTasks expects the exported file as the request body, e.g.
+// POST /api/v1/import?source=todotxt&profile_id=1&dry_run=true
+func (h *HandlerFn) importTasks(w http.ResponseWriter, r *http.Request) {
+	source := r.URL.Query().Get("source")
+	listName := r.URL.Query().Get("list_name")
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+	profileID := internal.ParseSize(r.URL.Query().Get("profile_id"))
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportSize))
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid request body", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	lists, err := importer.Parse(source, data, listName)
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Parsing the import file failed", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	summary, err := importer.Import(h.DB, profileID, lists, dryRun)
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Importing tasks failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	if dryRun {
+		utils.JsonResponse(w, http.StatusOK, models.Response{Data: summary})
+
+		return
+	}
+
+	var urls []string
+
+	for _, list := range lists {
+		for _, task := range list.Tasks {
+			urls = append(urls, internal.TaskURLs(task.Name, task.Metadata)...)
+		}
+	}
+
+	internal.TrackLinks(h.DB, urls)
+
+	utils.JsonResponse(w, http.StatusCreated, models.Response{Data: summary})
+}
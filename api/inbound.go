@@ -0,0 +1,111 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"todo-server/internal"
+	"todo-server/internal/inbound"
+	"todo-server/models"
+	"todo-server/utils"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func inboundAddress(token string) string {
+	return fmt.Sprintf("tasks+%s@%s", token, inbound.Domain())
+}
+
+// createInboundAddress hands out a secret address of the email gateway. Mail to
+// it becomes a task in the profile, so the address has to be kept private.
+func (h *HandlerFn) createInboundAddress(w http.ResponseWriter, r *http.Request) {
+	var address models.InboundAddress
+
+	if err := json.NewDecoder(r.Body).Decode(&address); err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid request body", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	token, err := internal.GenerateToken()
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Generating address token failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	query := `
+	INSERT INTO inbound_addresses (token, profile_id)
+	VALUES ($1, $2)
+	RETURNING id, created_at;
+	`
+
+	err = h.DB.QueryRow(query, token, address.ProfileID).Scan(&address.ID, &address.CreatedAt)
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Creating email address failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	address.Token = token
+	address.Address = inboundAddress(token)
+
+	utils.JsonResponse(w, http.StatusCreated, models.Response{Data: address})
+}
+
+func (h *HandlerFn) inboundAddresses(w http.ResponseWriter, r *http.Request) {
+	rows, err := h.DB.Query("SELECT id, token, profile_id, created_at FROM inbound_addresses ORDER BY created_at DESC")
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Fetching email addresses failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+	defer rows.Close()
+
+	addresses := []models.InboundAddress{}
+
+	for rows.Next() {
+		var address models.InboundAddress
+
+		if err := rows.Scan(&address.ID, &address.Token, &address.ProfileID, &address.CreatedAt); err != nil {
+			utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Fetching email addresses failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+			return
+		}
+
+		address.Address = inboundAddress(address.Token)
+		addresses = append(addresses, address)
+	}
+
+	utils.JsonResponse(w, http.StatusOK, models.Response{Data: addresses})
+}
+
+func (h *HandlerFn) deleteInboundAddress(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid email address ID", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	result, err := h.DB.Exec("DELETE FROM inbound_addresses WHERE id = $1", id)
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Deleting email address failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	if rf, _ := result.RowsAffected(); rf != 1 {
+		utils.JsonResponse(w, http.StatusNotFound, models.ErrorResponseV2{Message: fmt.Sprintf("Email address with ID {%v} does not exist.", id), Status: http.StatusNotFound, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusOK, models.MsgResponse{Message: fmt.Sprintf("Deleted email address with ID {%v} successfully.", id)})
+}
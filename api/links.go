@@ -0,0 +1,122 @@
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+	"todo-server/db"
+	"todo-server/internal"
+	"todo-server/models"
+	"todo-server/utils"
+)
+
+// trackLinks records the links of the tasks written by the column (id or
+// list_id) being id, for the paths that don't have the task texts at hand.
+func (h *HandlerFn) trackLinks(column string, id int) {
+	texts, err := db.GetTaskTexts(h.DB, column, id)
+
+	if err != nil {
+		log.Println("Failed to fetch task links", err)
+
+		return
+	}
+
+	var urls []string
+
+	for _, task := range texts {
+		urls = append(urls, internal.TaskURLs(task...)...)
+	}
+
+	internal.TrackLinks(h.DB, urls)
+}
+
+// setLinks fills in the link previews of the listed tasks. Links that have not
+// been fetched yet only have their URL.
+func (h *HandlerFn) setLinks(tasks []models.Task) error {
+	var all []string
+
+	for i := range tasks {
+		urls := internal.TaskURLs(tasks[i].Name, tasks[i].Metadata)
+
+		tasks[i].Links = nil
+
+		for _, u := range urls {
+			tasks[i].Links = append(tasks[i].Links, models.LinkPreview{URL: u})
+		}
+
+		all = append(all, urls...)
+	}
+
+	if len(all) == 0 {
+		return nil
+	}
+
+	previews, err := db.GetLinkPreviews(h.DB, all)
+
+	if err != nil {
+		return err
+	}
+
+	for i := range tasks {
+		for j, link := range tasks[i].Links {
+			if preview, found := previews[link.URL]; found {
+				tasks[i].Links[j] = preview
+			}
+		}
+	}
+
+	return nil
+}
+
+// linkPreview returns the preview of any URL, fetching the page when it is not
+// known yet. GET /api/v1/link-preview?url=https://example.com
+func (h *HandlerFn) linkPreview(w http.ResponseWriter, r *http.Request) {
+	pageURL := r.URL.Query().Get("url")
+
+	if u, err := url.Parse(pageURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "URL is not valid", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	previews, err := db.GetLinkPreviews(h.DB, []string{pageURL})
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Fetching link preview failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	if preview, found := previews[pageURL]; found {
+		utils.JsonResponse(w, http.StatusOK, models.Response{Data: preview})
+
+		return
+	}
+
+	preview, ok := h.fetchLinkPreview(r.Context(), pageURL)
+
+	if !ok {
+		utils.JsonResponse(w, http.StatusUnprocessableEntity, models.MsgResponse{Message: "This URL is marked as Invalid."})
+
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusOK, models.Response{Data: preview})
+}
+
+// fetchLinkPreview fetches the page now and stores the outcome.
+func (h *HandlerFn) fetchLinkPreview(ctx context.Context, pageURL string) (models.LinkPreview, bool) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	preview, err := internal.FetchLinkPreview(ctx, pageURL)
+	preview.URL = pageURL
+
+	if saveErr := db.SaveLinkPreview(h.DB, preview, err == nil); saveErr != nil {
+		log.Println("Failed to save link preview", pageURL, saveErr)
+	}
+
+	return preview, err == nil
+}
@@ -0,0 +1,381 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"todo-server/db"
+	"todo-server/internal"
+	"todo-server/models"
+	"todo-server/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+)
+
+// listsTree puts the already ordered lists under their groups.
+func (h *HandlerFn) listsTree(profileID *int, lists []models.List) (*models.ListsTree, error) {
+	query := `
+	SELECT id, name, position, profile_id, created_at
+	FROM list_groups
+	WHERE profile_id IS NOT DISTINCT FROM $1
+	ORDER BY position ASC, id ASC
+	`
+
+	rows, err := h.DB.Query(query, profileID)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tree := models.ListsTree{Groups: []models.ListGroup{}, Lists: []models.List{}}
+
+	groupIndex := map[int]int{}
+
+	for rows.Next() {
+		group := models.ListGroup{Lists: []models.List{}}
+
+		if err := rows.Scan(&group.ID, &group.Name, &group.Position, &group.ProfileID, &group.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		groupIndex[group.ID] = len(tree.Groups)
+		tree.Groups = append(tree.Groups, group)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, list := range lists {
+		if list.GroupID != nil {
+			if i, ok := groupIndex[*list.GroupID]; ok {
+				tree.Groups[i].Lists = append(tree.Groups[i].Lists, list)
+				continue
+			}
+		}
+
+		tree.Lists = append(tree.Lists, list)
+	}
+
+	return &tree, nil
+}
+
+func (h *HandlerFn) createListGroup(w http.ResponseWriter, r *http.Request) {
+	var group models.ListGroup
+
+	if err := json.NewDecoder(r.Body).Decode(&group); err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid request body", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	validate := validator.New()
+
+	err := validate.Struct(group)
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{
+			Status:        http.StatusBadRequest,
+			Code:          internal.ErrorCodeValidationFailed,
+			Message:       "One or more fields are invalid",
+			InvalidFields: internal.ConstructInvalidFieldData(err)})
+
+		return
+	}
+
+	query := `
+		INSERT INTO list_groups (name, profile_id, position)
+		VALUES ($1, $2, (SELECT COALESCE(MAX(position) + 1, 0) FROM list_groups WHERE profile_id IS NOT DISTINCT FROM $2))
+		RETURNING id;
+	`
+
+	var groupID int
+
+	err = h.DB.QueryRow(query, group.Name, group.ProfileID).Scan(&groupID)
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Creating list group failed.", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusCreated, models.CreateTaskResponse{Message: "List group created successfully", ID: groupID})
+}
+
+func (h *HandlerFn) updateListGroupName(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, id_err := strconv.Atoi(idStr)
+
+	if id_err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid list group ID", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	var group models.ListGroup
+
+	if err := json.NewDecoder(r.Body).Decode(&group); err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid request body", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	validate := validator.New()
+
+	err := validate.Struct(group)
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{
+			Status:        http.StatusBadRequest,
+			Code:          internal.ErrorCodeValidationFailed,
+			Message:       "One or more fields are invalid",
+			InvalidFields: internal.ConstructInvalidFieldData(err)})
+
+		return
+	}
+
+	result, err := h.DB.Exec("UPDATE list_groups SET name=$1 WHERE id=$2", group.Name, id)
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: fmt.Sprintf("Updating list group with ID {%v} failed.", id), Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+		return
+	}
+
+	if rf, _ := result.RowsAffected(); rf != 1 {
+		utils.JsonResponse(w, http.StatusNotFound, models.ErrorResponseV2{Message: fmt.Sprintf("Updating list group with ID {%v} failed. List group may not be available.", id), Status: http.StatusNotFound, Code: internal.ErrorCodeErrorMessage})
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusOK, models.MsgResponse{Message: "Updated list group successfully."})
+}
+
+// deleteListGroup removes the group only. Its lists move to the top level after the
+// lists that are already there.
+func (h *HandlerFn) deleteListGroup(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, id_err := strconv.Atoi(idStr)
+
+	if id_err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid list group ID", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	tx, err := h.DB.Begin()
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Deleting list group failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+	defer tx.Rollback()
+
+	var profileID *int
+
+	if err := tx.QueryRow("SELECT profile_id FROM list_groups WHERE id = $1", id).Scan(&profileID); err != nil {
+		if err == sql.ErrNoRows {
+			utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: fmt.Sprintf("List group either already deleted or list group with ID {%v} does not exist.", id), Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+		} else {
+			utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Deleting list group failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+		}
+
+		return
+	}
+
+	ungrouped, err := db.GroupListIDs(tx, nil, profileID)
+
+	if err == nil {
+		var grouped []int
+
+		if grouped, err = db.GroupListIDs(tx, &id, profileID); err == nil {
+			err = db.SetPositions(tx, "lists", append(ungrouped, grouped...))
+		}
+	}
+
+	if err == nil {
+		_, err = tx.Exec("DELETE FROM list_groups WHERE id = $1", id)
+	}
+
+	if err == nil {
+		err = tx.Commit()
+	}
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Deleting list group failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusOK, models.MsgResponse{Message: fmt.Sprintf("Deleted list group with ID {%v} successfully.", id)})
+}
+
+func (h *HandlerFn) reorderListGroups(w http.ResponseWriter, r *http.Request) {
+	h.reorder(w, r, "list_groups")
+}
+
+func (h *HandlerFn) reorderLists(w http.ResponseWriter, r *http.Request) {
+	h.reorder(w, r, "lists")
+}
+
+// reorder stores the order of the ids in the body as positions.
+func (h *HandlerFn) reorder(w http.ResponseWriter, r *http.Request, table string) {
+	var payload models.ReorderPayload
+
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid request body", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	validate := validator.New()
+
+	err := validate.Struct(payload)
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{
+			Status:        http.StatusBadRequest,
+			Code:          internal.ErrorCodeValidationFailed,
+			Message:       "One or more fields are invalid",
+			InvalidFields: internal.ConstructInvalidFieldData(err)})
+
+		return
+	}
+
+	tx, err := h.DB.Begin()
+
+	if err == nil {
+		defer tx.Rollback()
+
+		if err = db.SetPositions(tx, table, payload.IDs); err == nil {
+			err = tx.Commit()
+		}
+	}
+
+	if err == db.ErrInvalidOrder {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "ids have to be distinct, of the same profile and group and not in the trash", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Reordering failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusOK, models.MsgResponse{Message: "Reordered successfully."})
+}
+
+// moveList puts a list into a group (or to the top level when group_id is null)
+// at the given position, shifting the lists after it down.
+func (h *HandlerFn) moveList(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, id_err := strconv.Atoi(idStr)
+
+	if id_err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid list ID", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	var move models.MoveList
+
+	if err := json.NewDecoder(r.Body).Decode(&move); err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid request body", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	validate := validator.New()
+
+	if err := validate.Struct(move); err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{
+			Status:        http.StatusBadRequest,
+			Code:          internal.ErrorCodeValidationFailed,
+			Message:       "One or more fields are invalid",
+			InvalidFields: internal.ConstructInvalidFieldData(err)})
+
+		return
+	}
+
+	if move.GroupID != nil && *move.GroupID == 0 {
+		move.GroupID = nil
+	}
+
+	tx, err := h.DB.Begin()
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Moving list failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+	defer tx.Rollback()
+
+	var profileID *int
+
+	if err := tx.QueryRow("SELECT profile_id FROM lists WHERE id = $1 AND deleted_at IS NULL", id).Scan(&profileID); err != nil {
+		if err == sql.ErrNoRows {
+			utils.JsonResponse(w, http.StatusNotFound, models.ErrorResponseV2{Message: fmt.Sprintf("List with ID {%v} does not exist.", id), Status: http.StatusNotFound, Code: internal.ErrorCodeErrorMessage})
+		} else {
+			utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Moving list failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+		}
+
+		return
+	}
+
+	if move.GroupID != nil {
+		var groupProfileID *int
+
+		err := tx.QueryRow("SELECT profile_id FROM list_groups WHERE id = $1", *move.GroupID).Scan(&groupProfileID)
+
+		if err == sql.ErrNoRows || (err == nil && !db.SameID(groupProfileID, profileID)) {
+			utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: fmt.Sprintf("List group with ID {%v} does not exist in the list's profile.", *move.GroupID), Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+			return
+		}
+
+		if err != nil {
+			utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Moving list failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+			return
+		}
+	}
+
+	ids, err := db.GroupListIDs(tx, move.GroupID, profileID)
+
+	if err == nil {
+		ordered := make([]int, 0, len(ids)+1)
+
+		for _, listID := range ids {
+			if listID != id {
+				ordered = append(ordered, listID)
+			}
+		}
+
+		position := min(move.Position, len(ordered))
+		ordered = append(ordered[:position], append([]int{id}, ordered[position:]...)...)
+
+		if _, err = tx.Exec("UPDATE lists SET group_id = $1 WHERE id = $2", move.GroupID, id); err == nil {
+			err = db.SetPositions(tx, "lists", ordered)
+		}
+	}
+
+	if err == nil {
+		err = tx.Commit()
+	}
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Moving list failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusOK, models.MsgResponse{Message: "Moved list successfully."})
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"todo-server/db"
+	"todo-server/internal"
+	"todo-server/models"
+	"todo-server/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+)
+
+func (h *HandlerFn) getNote(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid task ID", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	note, err := db.GetNote(h.DB, taskID)
+
+	if err == sql.ErrNoRows {
+		utils.JsonResponse(w, http.StatusNotFound, models.ErrorResponseV2{Message: fmt.Sprintf("Task with ID {%v} does not exist.", taskID), Status: http.StatusNotFound, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Fetching note failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	w.Header().Set("ETag", internal.ETag(note.Version))
+	utils.JsonResponse(w, http.StatusOK, models.Response{Data: note})
+}
+
+// saveNote replaces the note and keeps the previous text in the history. Like the
+// other updates it honours If-Match; a note that was never saved is at "0".
+func (h *HandlerFn) saveNote(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid task ID", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	var body models.Note
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid request body", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	if err := validator.New().Struct(body); err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{
+			Status:        http.StatusBadRequest,
+			Code:          internal.ErrorCodeValidationFailed,
+			Message:       "One or more fields are invalid",
+			InvalidFields: internal.ConstructInvalidFieldData(err)})
+
+		return
+	}
+
+	note, err := db.SaveNote(h.DB, taskID, body.Content, internal.IfMatch(r.Header.Get("If-Match")))
+
+	if err == nil {
+		w.Header().Set("ETag", internal.ETag(note.Version))
+		utils.JsonResponse(w, http.StatusOK, models.Response{Data: note})
+
+		return
+	}
+
+	if err != sql.ErrNoRows {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Saving note failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	current, err := db.GetNote(h.DB, taskID)
+
+	if err == sql.ErrNoRows {
+		utils.JsonResponse(w, http.StatusNotFound, models.ErrorResponseV2{Message: fmt.Sprintf("Task with ID {%v} does not exist.", taskID), Status: http.StatusNotFound, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Saving note failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	data, _ := json.Marshal(current)
+
+	w.Header().Set("ETag", internal.ETag(current.Version))
+	utils.JsonResponse(w, http.StatusPreconditionFailed, models.ConflictResponse{
+		ErrorResponseV2: models.ErrorResponseV2{Message: fmt.Sprintf("The note of task with ID {%v} was changed by someone else.", taskID), Status: http.StatusPreconditionFailed, Code: internal.ErrorCodeErrorMessage},
+		Current:         data,
+	})
+}
+
+func (h *HandlerFn) noteHistory(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid task ID", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	versions, err := db.GetNoteVersions(h.DB, taskID)
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Fetching note history failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusOK, models.Response{Data: versions})
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+	"todo-server/db"
+	"todo-server/internal"
+	"todo-server/internal/quickadd"
+	"todo-server/models"
+	"todo-server/utils"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// quickAddTask creates a task from the text in its name, e.g. "Pay rent every
+// month on the 1st #Home !important". The list_id and profile_id of the body are
+// used unless the text names a list. With dry_run=true nothing is created and
+// only what was understood is returned. Dates that were left out, e.g. because
+// the recurrence is on another day, are named in parsed.conflict.
+func (h *HandlerFn) quickAddTask(w http.ResponseWriter, r *http.Request, task models.Task) {
+	parsed := quickadd.Parse(task.Name, time.Now())
+
+	parsed.ListID = task.ListID
+
+	if parsed.List != "" {
+		listID, err := db.FindListByName(h.DB, task.ProfileID, parsed.List)
+
+		switch err {
+		case nil:
+			parsed.ListID = &listID
+		case sql.ErrNoRows:
+			// Stays in the given list; the client sees that the tag did not match.
+		default:
+			utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Fetching list failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+			return
+		}
+	}
+
+	task.Name = parsed.Name
+	task.ListID = parsed.ListID
+	task.RecurrencePattern = parsed.RecurrencePattern
+	task.RecurrenceInterval = parsed.RecurrenceInterval
+
+	if parsed.DueDate != "" {
+		task.DueDate = parsed.DueDate
+	}
+
+	if parsed.Priority != "" {
+		task.Priority = parsed.Priority
+		task.IsImportant = parsed.IsImportant
+	}
+
+	if err := validator.New().Struct(task); err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{
+			Status:        http.StatusBadRequest,
+			Code:          internal.ErrorCodeValidationFailed,
+			Message:       "One or more fields are invalid",
+			InvalidFields: internal.ConstructInvalidFieldData(err)})
+
+		return
+	}
+
+	if r.URL.Query().Get("dry_run") == "true" {
+		utils.JsonResponse(w, http.StatusOK, models.QuickAddResponse{Message: "Task was not created", Parsed: parsed})
+
+		return
+	}
+
+	taskID, err := db.InsertTask(h.DB, task)
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Creating task failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	internal.TrackLinks(h.DB, internal.TaskURLs(task.Name, task.Metadata))
+
+	utils.JsonResponse(w, http.StatusCreated, models.QuickAddResponse{Message: "Task created successfully", ID: taskID, Parsed: parsed})
+}
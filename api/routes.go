@@ -11,6 +11,7 @@ import (
 	"todo-server/db"
 	"todo-server/internal"
 	"todo-server/models"
+	"unicode/utf8"
 
 	"database/sql"
 
@@ -23,7 +24,8 @@ import (
 )
 
 type HandlerFn struct {
-	DB *sql.DB
+	DB     *sql.DB
+	Events *internal.Broker
 }
 
 func healthCheckWithDB(w http.ResponseWriter, r *http.Request) {
@@ -77,6 +79,7 @@ func (h *HandlerFn) getTask(w http.ResponseWriter, r *http.Request) {
     t.completed_on, 
     t.created_at, 
     t.is_important, 
+    t.priority,
     t.marked_today, 
     t.due_date, 
     t.metadata, 
@@ -84,20 +87,25 @@ func (h *HandlerFn) getTask(w http.ResponseWriter, r *http.Request) {
     t.recurrence_pattern, 
     t.recurrence_interval,
 		t.list_id,
+    t.revision,
     st.id AS sub_task_id, 
     st.name AS sub_task_name, 
     st.completed AS sub_task_completed, 
-    st.created_at AS sub_task_created_at
+    st.created_at AS sub_task_created_at,
+    st.parent_id AS sub_task_parent_id,
+    st.due_date AS sub_task_due_date,
+    st.is_important AS sub_task_is_important,
+    st.revision AS sub_task_revision
 	FROM 
     tasks t
 	LEFT JOIN 
     sub_tasks st 
 	ON 
-    t.id = st.task_id
+    t.id = st.task_id AND st.deleted_at IS NULL
 	WHERE 
-    t.id = $1
+    t.id = $1 AND t.deleted_at IS NULL
 	ORDER BY 
-    st.created_at ASC;
+    st.created_at ASC, st.id ASC;
   `
 
 	rows, err := h.DB.Query(query, id)
@@ -127,6 +135,10 @@ func (h *HandlerFn) getTask(w http.ResponseWriter, r *http.Request) {
 		var recurrenceInterval sql.NullInt64
 		var subTaskCreatedAt sql.NullTime // Use sql.NullTime for nullable time
 		var listID sql.NullInt64
+		var subTaskParentID sql.NullInt64
+		var subTaskDueDate sql.NullString
+		var subTaskIsImportant sql.NullBool
+		var subTaskRevision sql.NullInt64
 
 		if err := rows.Scan(
 			&task.ID,
@@ -135,6 +147,7 @@ func (h *HandlerFn) getTask(w http.ResponseWriter, r *http.Request) {
 			&completedOn,
 			&task.CreatedAt,
 			&task.IsImportant,
+			&task.Priority,
 			&task.MarkedToday,
 			&task.DueDate,
 			&task.Metadata,
@@ -142,10 +155,15 @@ func (h *HandlerFn) getTask(w http.ResponseWriter, r *http.Request) {
 			&recurrencePattern,
 			&recurrenceInterval,
 			&listID,
+			&task.Revision,
 			&subTaskID,
 			&subTaskName,
 			&subTaskCompleted,
 			&subTaskCreatedAt,
+			&subTaskParentID,
+			&subTaskDueDate,
+			&subTaskIsImportant,
+			&subTaskRevision,
 		); err != nil {
 			utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{
 				Message: "Failed to scan task",
@@ -195,6 +213,15 @@ func (h *HandlerFn) getTask(w http.ResponseWriter, r *http.Request) {
 				subTask.CreatedAt = time.Time{}
 			}
 
+			if subTaskParentID.Valid {
+				parentID := int(subTaskParentID.Int64)
+				subTask.ParentID = &parentID
+			}
+
+			subTask.DueDate = subTaskDueDate.String
+			subTask.IsImportant = subTaskIsImportant.Bool
+			subTask.Revision = subTaskRevision.Int64
+
 			subTasks = append(subTasks, subTask)
 			hasSubTasks = true
 		}
@@ -211,7 +238,23 @@ func (h *HandlerFn) getTask(w http.ResponseWriter, r *http.Request) {
 	if !hasSubTasks {
 		task.SubTasks = nil
 	} else {
-		task.SubTasks = subTasks
+		task.SubTasks = internal.SubTaskTree(subTasks)
+	}
+
+	task.Progress = internal.Progress(task.Completed, task.SubTasks)
+
+	if task.ID != 0 {
+		w.Header().Set("ETag", internal.ETag(task.Revision))
+
+		tasks := []models.Task{task}
+
+		if err := h.setLinks(tasks); err != nil {
+			utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: err.Error(), Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage})
+
+			return
+		}
+
+		task = tasks[0]
 	}
 
 	utils.JsonResponse(w, http.StatusOK, models.Response{Data: task})
@@ -288,8 +331,16 @@ func (h *HandlerFn) tasks(w http.ResponseWriter, r *http.Request) {
 	listID := internal.ParseSize(r.URL.Query().Get("list_id"))
 	showAllTasks := r.URL.Query().Get("show_all_tasks")
 	profileID := internal.ParseSize(r.URL.Query().Get("profile_id"))
+	sortBy := r.URL.Query().Get("sort")
+	priority := r.URL.Query().Get("priority")
+
+	if err := validator.New().Var(priority, "omitempty,oneof=none low medium high urgent"); err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Priority is not valid", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
 
-	query, args := query.GetTasksQuery(filter, searchTerm, showCompleted, size, listID, showAllTasks, profileID)
+	query, args := query.GetTasksQuery(filter, searchTerm, showCompleted, size, listID, showAllTasks, profileID, sortBy, priority)
 
 	rows, err := h.DB.Query(query, args...)
 
@@ -298,18 +349,15 @@ func (h *HandlerFn) tasks(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var tasks []models.Task
+	defer rows.Close()
 
-	for rows.Next() {
-		var task models.Task
-		if err := rows.Scan(&task.ID, &task.Name, &task.Completed, &task.CompletedOn, &task.CreatedAt, &task.MarkedToday, &task.IsImportant, &task.DueDate, &task.Metadata, &task.ListID, &task.ProfileID, &task.RecurrencePattern, &task.InCompleteSubTaskCount, &task.SubTaskCount); err != nil {
-			utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: err.Error(), Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage})
+	tasks, err := db.ScanTasks(rows)
 
-			return
-		}
-		tasks = append(tasks, task)
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: err.Error(), Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage})
+
+		return
 	}
-	defer rows.Close()
 
 	if len(tasks) == 0 {
 		utils.JsonResponse(w, http.StatusOK, models.Response{Data: []models.Task{}})
@@ -317,6 +365,18 @@ func (h *HandlerFn) tasks(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := h.setProgress(tasks); err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: err.Error(), Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	if err := h.setLinks(tasks); err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: err.Error(), Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
 	utils.JsonResponse(w, http.StatusOK, models.Response{Data: tasks})
 }
 
@@ -329,6 +389,12 @@ func (h *HandlerFn) createTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if r.URL.Query().Get("quick_add") == "true" {
+		h.quickAddTask(w, r, newTask)
+
+		return
+	}
+
 	validate := validator.New()
 
 	err := validate.Struct(newTask)
@@ -344,28 +410,7 @@ func (h *HandlerFn) createTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var taskID int
-
-	query := `
-	INSERT INTO tasks 
-		(name, completed, completed_on, marked_today, is_important, due_date, metadata, list_id, profile_id)
-	VALUES 
-		($1, $2, $3, $4, $5, $6, $7, $8, $9)
-	RETURNING id;
-`
-
-	err = h.DB.QueryRow(
-		query,
-		newTask.Name,
-		newTask.Completed,
-		newTask.CompletedOn,
-		newTask.MarkedToday,
-		newTask.IsImportant,
-		newTask.DueDate,
-		newTask.Metadata,
-		newTask.ListID,
-		newTask.ProfileID,
-	).Scan(&taskID)
+	taskID, err := db.InsertTask(h.DB, newTask)
 
 	if err != nil {
 		utils.JsonResponse(w, http.StatusInternalServerError, models.MsgResponse{Message: err.Error()})
@@ -373,6 +418,8 @@ func (h *HandlerFn) createTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	internal.TrackLinks(h.DB, internal.TaskURLs(newTask.Name, newTask.Metadata))
+
 	utils.JsonResponse(w, http.StatusCreated, models.CreateTaskResponse{Message: "Task created successfully", ID: taskID})
 }
 
@@ -383,6 +430,7 @@ func (h *HandlerFn) updateTask(w http.ResponseWriter, r *http.Request) {
 
 	if id_err != nil {
 		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid task ID", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+		return
 	}
 
 	var task models.Task
@@ -407,20 +455,11 @@ func (h *HandlerFn) updateTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	query := "UPDATE tasks SET name=$1 WHERE id=$2"
-
-	result, err := h.DB.Exec(query, task.Name, id)
-
-	if err != nil {
-		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: fmt.Sprintf("Updating task with ID {%v} failed.", id), Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+	if !h.updateIfMatch(w, r, "tasks", "task", id, "name", task.Name) {
 		return
 	}
 
-	if rf, _ := result.RowsAffected(); rf != 1 {
-		// TODO - check whether not found here is okay
-		utils.JsonResponse(w, http.StatusNotFound, models.ErrorResponseV2{Message: fmt.Sprintf("Updating task with ID {%v} failed. Task may not be available.", id), Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
-		return
-	}
+	internal.TrackLinks(h.DB, internal.TaskURLs(task.Name))
 
 	utils.JsonResponse(w, http.StatusOK, models.MsgResponse{Message: "Updated Task successfully."})
 }
@@ -432,6 +471,7 @@ func (h *HandlerFn) updateSubTask(w http.ResponseWriter, r *http.Request) {
 
 	if id_err != nil {
 		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid sub task ID", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+		return
 	}
 
 	var subTask models.SubTask
@@ -456,18 +496,7 @@ func (h *HandlerFn) updateSubTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	query := "UPDATE sub_tasks SET name=$1 WHERE id=$2"
-
-	result, err := h.DB.Exec(query, subTask.Name, id)
-
-	if err != nil {
-		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: fmt.Sprintf("Updating sub task with ID {%v} failed.", id), Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
-		return
-	}
-
-	if rf, _ := result.RowsAffected(); rf != 1 {
-		// TODO - check whether not found here is okay
-		utils.JsonResponse(w, http.StatusNotFound, models.ErrorResponseV2{Message: fmt.Sprintf("Updating sub task with ID {%v} failed. Task may not be available.", id), Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+	if !h.updateIfMatch(w, r, "sub_tasks", "sub task", id, "name", subTask.Name) {
 		return
 	}
 
@@ -481,6 +510,7 @@ func (h *HandlerFn) updateTaskMetadata(w http.ResponseWriter, r *http.Request) {
 
 	if id_err != nil {
 		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid task ID", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+		return
 	}
 
 	var task models.Task
@@ -505,20 +535,19 @@ func (h *HandlerFn) updateTaskMetadata(w http.ResponseWriter, r *http.Request) {
 	// 	return
 	// }
 
-	query := "UPDATE tasks SET metadata=$1 WHERE id=$2"
-
-	result, err := h.DB.Exec(query, task.Metadata, id)
+	// The column is a VARCHAR(255), longer text belongs in the note of the task.
+	if utf8.RuneCountInString(task.Metadata) > 255 {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Metadata can be at most 255 characters, use the note of the task for longer text", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
 
-	if err != nil {
-		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: fmt.Sprintf("Updating task with ID {%v} failed.", id), Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
 		return
 	}
 
-	if rf, _ := result.RowsAffected(); rf != 1 {
-		utils.JsonResponse(w, http.StatusNotFound, models.ErrorResponseV2{Message: fmt.Sprintf("Updating task with ID {%v} failed. Task may not be available.", id), Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+	if !h.updateIfMatch(w, r, "tasks", "task", id, "metadata", task.Metadata) {
 		return
 	}
 
+	internal.TrackLinks(h.DB, internal.TaskURLs(task.Metadata))
+
 	utils.JsonResponse(w, http.StatusOK, models.MsgResponse{Message: "Updated Task metadata successfully."})
 }
 
@@ -533,21 +562,7 @@ func (h *HandlerFn) deleteTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, error := h.DB.Exec("DELETE FROM tasks where id = $1", id)
-
-	if error != nil {
-		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Deleting task failed", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
-
-		return
-	}
-
-	if rf, _ := result.RowsAffected(); rf != 1 {
-		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: fmt.Sprintf("Task either already deleted or task with ID {%v} does not exist.", id), Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
-
-		return
-	}
-
-	utils.JsonResponse(w, http.StatusOK, models.MsgResponse{Message: fmt.Sprintf("Deleted task with ID {%v} successfully.", id)})
+	h.moveToTrash(w, id, "task", db.SoftDeleteTask)
 }
 
 func (h *HandlerFn) deleteSubTask(w http.ResponseWriter, r *http.Request) {
@@ -561,21 +576,7 @@ func (h *HandlerFn) deleteSubTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, error := h.DB.Exec("DELETE FROM sub_tasks where id = $1", id)
-
-	if error != nil {
-		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Deleting sub task failed", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
-
-		return
-	}
-
-	if rf, _ := result.RowsAffected(); rf != 1 {
-		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: fmt.Sprintf("Sub Task either already deleted or task with ID {%v} does not exist.", id), Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
-
-		return
-	}
-
-	utils.JsonResponse(w, http.StatusOK, models.MsgResponse{Message: fmt.Sprintf("Deleted sub task with ID {%v} successfully.", id)})
+	h.moveToTrash(w, id, "sub task", db.SoftDeleteSubTask)
 }
 
 func (h *HandlerFn) toggleTask(w http.ResponseWriter, r *http.Request) {
@@ -591,6 +592,12 @@ func (h *HandlerFn) toggleTask(w http.ResponseWriter, r *http.Request) {
 
 	error := db.ToggleTaskAndHandleRecurrence(h.DB, id)
 
+	if error == sql.ErrNoRows {
+		utils.JsonResponse(w, http.StatusNotFound, models.ErrorResponseV2{Message: fmt.Sprintf("Task with ID {%v} does not exist.", id), Status: http.StatusNotFound, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
 	if error != nil {
 		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Toggling task failed", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage, Error: error.Error()})
 
@@ -611,27 +618,34 @@ func (h *HandlerFn) toggleSubTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	query := `
-	UPDATE sub_tasks 
-	SET completed = NOT completed
-	WHERE id = $1;
-	`
+	tx, err := h.DB.Begin()
 
-	result, error := h.DB.Exec(query, id)
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Toggling sub task failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
 
-	if error != nil {
-		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Toggling sub task failed", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage, Error: error.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	completed, parentCompleted, err := db.ToggleSubTask(tx, id)
+
+	if err == sql.ErrNoRows {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: fmt.Sprintf("Sub Task with ID {%v} does not exist.", id), Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
 
 		return
 	}
 
-	if rf, error := result.RowsAffected(); rf != 1 {
-		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: fmt.Sprintf("Sub Task with ID {%v} does not exist.", id), Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage, Error: error.Error()})
+	if err == nil {
+		err = tx.Commit()
+	}
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Toggling sub task failed", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
 
 		return
 	}
 
-	utils.JsonResponse(w, http.StatusOK, models.MsgResponse{Message: fmt.Sprintf("Toggled sub task with ID {%v} successfully.", id)})
+	utils.JsonResponse(w, http.StatusOK, models.ToggleSubTaskResponse{Message: fmt.Sprintf("Toggled sub task with ID {%v} successfully.", id), Completed: completed, ParentCompleted: parentCompleted})
 }
 
 func (h *HandlerFn) toggleImportant(w http.ResponseWriter, r *http.Request) {
@@ -644,13 +658,15 @@ func (h *HandlerFn) toggleImportant(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The toggle is kept for older clients. It switches between high and no priority.
 	query := `
 update
 	tasks
 set
-	is_important = not is_important
+	is_important = not is_important,
+	priority = CASE WHEN is_important THEN 'none' ELSE 'high' END::task_priority_enum
 where
-	id = $1;
+	id = $1 and deleted_at is null;
 	`
 
 	result, err := h.DB.Exec(query, id)
@@ -662,7 +678,7 @@ where
 	}
 
 	if rf, _ := result.RowsAffected(); rf != 1 {
-		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: fmt.Sprintf("Task with ID {%v} does not exist.", id), Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+		utils.JsonResponse(w, http.StatusNotFound, models.ErrorResponseV2{Message: fmt.Sprintf("Task with ID {%v} does not exist.", id), Status: http.StatusNotFound, Code: internal.ErrorCodeErrorMessage})
 
 		return
 	}
@@ -670,6 +686,60 @@ where
 	utils.JsonResponse(w, http.StatusOK, models.MsgResponse{Message: "Toggled Task's important"})
 }
 
+func (h *HandlerFn) updateTaskPriority(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, err := strconv.Atoi(idStr)
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Task ID is not valid.", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+		return
+	}
+
+	var payload models.TaskPriority
+
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid request body", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	validate := validator.New()
+
+	if err := validate.Struct(payload); err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{
+			Status:        http.StatusBadRequest,
+			Code:          internal.ErrorCodeValidationFailed,
+			Message:       "One or more fields are invalid",
+			InvalidFields: internal.ConstructInvalidFieldData(err)})
+
+		return
+	}
+
+	// is_important follows the priority so the important filter keeps working.
+	query := `
+	UPDATE tasks
+	SET priority = $1, is_important = $1 IN ('high', 'urgent')
+	WHERE id = $2 AND deleted_at IS NULL;
+	`
+
+	result, err := h.DB.Exec(query, payload.Priority, id)
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Updating task priority failed", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	if rf, _ := result.RowsAffected(); rf != 1 {
+		utils.JsonResponse(w, http.StatusNotFound, models.ErrorResponseV2{Message: fmt.Sprintf("Task with ID {%v} does not exist.", id), Status: http.StatusNotFound, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusOK, models.MsgResponse{Message: "Updated task priority successfully."})
+}
+
 func (h *HandlerFn) toggleAddToMyToday(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
 
@@ -688,7 +758,7 @@ func (h *HandlerFn) toggleAddToMyToday(w http.ResponseWriter, r *http.Request) {
 													WHEN marked_today::DATE != CURRENT_DATE THEN CURRENT_TIMESTAMP::TEXT
 													ELSE ''
 											END
-	WHERE id = $1;
+	WHERE id = $1 AND deleted_at IS NULL;
 	`
 
 	result, err := h.DB.Exec(query, id)
@@ -700,7 +770,7 @@ func (h *HandlerFn) toggleAddToMyToday(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if rf, _ := result.RowsAffected(); rf != 1 {
-		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: fmt.Sprintf("Task with ID {%v} does not exist.", id), Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+		utils.JsonResponse(w, http.StatusNotFound, models.ErrorResponseV2{Message: fmt.Sprintf("Task with ID {%v} does not exist.", id), Status: http.StatusNotFound, Code: internal.ErrorCodeErrorMessage})
 
 		return
 	}
@@ -735,7 +805,7 @@ func (h *HandlerFn) addDueDate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	query := "update tasks set due_date=$1 where id = $2"
+	query := "update tasks set due_date=$1 where id = $2 and deleted_at is null"
 
 	result, err := h.DB.Exec(query, task.DueDate, id)
 
@@ -769,7 +839,8 @@ func (h *HandlerFn) fetchWebPageTitle(w http.ResponseWriter, r *http.Request) {
 
 	url_title, _ := db.GetURLTitle(h.DB, url)
 
-	if url_title != nil {
+	// An empty valid title is a link of a task that was not fetched yet.
+	if url_title != nil && (url_title.Title != "" || !url_title.IsValid) {
 		if url_title.IsValid {
 			utils.JsonResponse(w, http.StatusOK, models.Response{Data: url_title.Title})
 		} else {
@@ -778,33 +849,14 @@ func (h *HandlerFn) fetchWebPageTitle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// pageTitle, err := chrome.GetTitleFromURLUsingChrome(url)
-
-	// if err != nil {
-	// 	db.SaveOrUpdateURLTitle(h.DB, pageTitle, url, false)
-
-	// 	utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{
-	// 		Status:  http.StatusBadRequest,
-	// 		Message: "Fetching Title using headless browser failed",
-	// 		Error:   err.Error(),
-	// 	})
+	preview, ok := h.fetchLinkPreview(r.Context(), url)
 
-	// 	return
-	// }
-
-	// if pageTitle == "" {
-	// 	db.SaveOrUpdateURLTitle(h.DB, pageTitle, url, false)
-
-	// 	utils.JsonResponse(w, http.StatusUnprocessableEntity, models.MsgResponse{Message: "Page title not found."})
-	// 	return
-	// }
-
-	// db.SaveOrUpdateURLTitle(h.DB, pageTitle, url, true)
-
-	// utils.JsonResponse(w, http.StatusOK, models.Response{Data: pageTitle})
+	if !ok {
+		utils.JsonResponse(w, http.StatusUnprocessableEntity, models.MsgResponse{Message: "Page title not found."})
+		return
+	}
 
-	// TODO: for now this the response for this api. Once the server is migrated to better server will enabled it.
-	utils.JsonResponse(w, http.StatusUnprocessableEntity, models.MsgResponse{Message: "This URL is marked as Invalid."})
+	utils.JsonResponse(w, http.StatusOK, models.Response{Data: preview.Title})
 }
 
 func (h *HandlerFn) syncTitle(w http.ResponseWriter, r *http.Request) {
@@ -934,15 +986,29 @@ func (h *HandlerFn) createSubTask(w http.ResponseWriter, r *http.Request) {
 
 	utils.Assert(len(newSubTask.Name) > 0, "Sub Task name length should be greater than 0")
 
-	query := `
-	INSERT INTO sub_tasks (name, task_id, completed)
-	VALUES ($1, $2, $3)
-	RETURNING id;
-`
+	if _, err := time.Parse("2006-01-02", newSubTask.DueDate); err != nil && newSubTask.DueDate != "" {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid Due Date", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	subTaskID, err := db.InsertSubTask(h.DB, newSubTask)
+
+	if err == sql.ErrNoRows {
+		var taskExists bool
+
+		h.DB.QueryRow("SELECT EXISTS (SELECT 1 FROM tasks WHERE id = $1 AND deleted_at IS NULL)", newSubTask.TaskID).Scan(&taskExists)
 
-	var subTaskID int
+		if !taskExists {
+			utils.JsonResponse(w, http.StatusNotFound, models.ErrorResponseV2{Message: fmt.Sprintf("Task with ID {%v} does not exist.", newSubTask.TaskID), Status: http.StatusNotFound, Code: internal.ErrorCodeErrorMessage})
 
-	err = h.DB.QueryRow(query, newSubTask.Name, newSubTask.TaskID, newSubTask.Completed).Scan(&subTaskID)
+			return
+		}
+
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "parent_id is not a sub task of task_id", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
 
 	if err != nil {
 		utils.JsonResponse(w, http.StatusInternalServerError, models.MsgResponse{Message: err.Error()})
@@ -986,7 +1052,7 @@ func (h *HandlerFn) updateRecurrencePattern(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	query := "UPDATE tasks SET recurrence_pattern=$1, recurrence_interval=$2, start_date=$3, due_date=$4 WHERE id=$5"
+	query := "UPDATE tasks SET recurrence_pattern=$1, recurrence_interval=$2, start_date=$3, due_date=$4 WHERE id=$5 AND deleted_at IS NULL"
 
 	var result sql.Result
 
@@ -1003,7 +1069,7 @@ func (h *HandlerFn) updateRecurrencePattern(w http.ResponseWriter, r *http.Reque
 
 	if rf, _ := result.RowsAffected(); rf != 1 {
 		// TODO - check whether not found here is okay
-		utils.JsonResponse(w, http.StatusNotFound, models.ErrorResponseV2{Message: fmt.Sprintf("Updating task with ID {%v} failed. Task may not be available.", id), Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+		utils.JsonResponse(w, http.StatusNotFound, models.ErrorResponseV2{Message: fmt.Sprintf("Updating task with ID {%v} failed. Task may not be available.", id), Status: http.StatusNotFound, Code: internal.ErrorCodeErrorMessage})
 		return
 	}
 
@@ -1033,21 +1099,19 @@ func (h *HandlerFn) createList(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	query := `
-		INSERT INTO lists (name, profile_id) 
-		VALUES ($1, $2)
-		RETURNING id;
-	`
-
-	var listID int
-
 	if list.ProfileID != nil {
 		fmt.Println("Profile ID:", *list.ProfileID)
 	} else {
 		fmt.Println("Profile ID is nil")
 	}
 
-	err = h.DB.QueryRow(query, list.Name, list.ProfileID).Scan(&listID)
+	listID, err := db.InsertList(h.DB, list)
+
+	if err == db.ErrInvalidGroup {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: fmt.Sprintf("List group with ID {%v} does not exist in the list's profile.", *list.GroupID), Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
 
 	if err != nil {
 		utils.JsonResponse(w, http.StatusInternalServerError, "Creating list failed.")
@@ -1095,21 +1159,28 @@ func (h *HandlerFn) lists(w http.ResponseWriter, r *http.Request) {
 		l.name, 
 		l.created_at, 
 		COUNT(t.id) AS tasks_count,
-		l.profile_id
+		l.profile_id,
+		l.group_id,
+		l.position,
+		l.auto_complete_parent
 	FROM 
 		lists l
 	LEFT JOIN 
-		tasks t ON l.id = t.list_id
+		tasks t ON l.id = t.list_id AND t.deleted_at IS NULL
+	WHERE
+		l.deleted_at IS NULL
 	`
 
 	if profileID != nil {
-		query += " WHERE l.profile_id = $1 "
+		query += " AND l.profile_id = $1 "
 		args = append(args, *profileID)
 	}
 
 	query += `
 	GROUP BY 
 		l.id, l.name, l.created_at
+	ORDER BY
+		l.position ASC, l.id ASC
 	`
 
 	rows, err := h.DB.Query(query, args...)
@@ -1123,7 +1194,7 @@ func (h *HandlerFn) lists(w http.ResponseWriter, r *http.Request) {
 
 	for rows.Next() {
 		var list models.List
-		if err := rows.Scan(&list.ID, &list.Name, &list.CreatedAt, &list.TasksCount, &list.ProfileID); err != nil {
+		if err := rows.Scan(&list.ID, &list.Name, &list.CreatedAt, &list.TasksCount, &list.ProfileID, &list.GroupID, &list.Position, &list.AutoCompleteParent); err != nil {
 			utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: err.Error(), Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage})
 
 			return
@@ -1132,6 +1203,20 @@ func (h *HandlerFn) lists(w http.ResponseWriter, r *http.Request) {
 	}
 	defer rows.Close()
 
+	if r.URL.Query().Get("tree") == "true" {
+		tree, err := h.listsTree(profileID, lists)
+
+		if err != nil {
+			utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Failed to fetch list groups", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+			return
+		}
+
+		utils.JsonResponse(w, http.StatusOK, models.Response{Data: tree})
+
+		return
+	}
+
 	if len(lists) == 0 {
 		utils.JsonResponse(w, http.StatusOK, models.Response{Data: []models.List{}})
 
@@ -1163,7 +1248,7 @@ func (h *HandlerFn) updateTaskListId(w http.ResponseWriter, r *http.Request) {
 	}
 
 	query := `
-	update tasks SET list_id=$1 WHERE id=$2;
+	update tasks SET list_id=$1 WHERE id=$2 AND deleted_at IS NULL;
 	`
 
 	var result sql.Result
@@ -1180,7 +1265,7 @@ func (h *HandlerFn) updateTaskListId(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if rf, _ := result.RowsAffected(); rf != 1 {
-		utils.JsonResponse(w, http.StatusNotFound, models.ErrorResponseV2{Message: fmt.Sprintf("Updating task's list with ID {%v} failed. Task may not be available.", taskId), Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+		utils.JsonResponse(w, http.StatusNotFound, models.ErrorResponseV2{Message: fmt.Sprintf("Updating task's list with ID {%v} failed. Task may not be available.", taskId), Status: http.StatusNotFound, Code: internal.ErrorCodeErrorMessage})
 		return
 	}
 
@@ -1195,6 +1280,7 @@ func (h *HandlerFn) updateListName(w http.ResponseWriter, r *http.Request) {
 
 	if id_err != nil {
 		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid list ID", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+		return
 	}
 
 	var list models.List
@@ -1219,18 +1305,7 @@ func (h *HandlerFn) updateListName(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	query := "UPDATE lists SET name=$1 WHERE id=$2"
-
-	result, err := h.DB.Exec(query, list.Name, id)
-
-	if err != nil {
-		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: fmt.Sprintf("Updating list with ID {%v} failed.", id), Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
-		return
-	}
-
-	if rf, _ := result.RowsAffected(); rf != 1 {
-		// TODO - check whether not found here is okay
-		utils.JsonResponse(w, http.StatusNotFound, models.ErrorResponseV2{Message: fmt.Sprintf("Updating list with ID {%v} failed. list may not be available.", id), Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+	if !h.updateIfMatch(w, r, "lists", "list", id, "name", list.Name) {
 		return
 	}
 
@@ -1248,21 +1323,99 @@ func (h *HandlerFn) deleteList(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, error := h.DB.Exec("DELETE FROM lists where id = $1", id)
+	// What happens to the tasks of the list: they are moved to another list
+	// (move_to), go to the trash with the list (delete_tasks=true), or the list
+	// is only deleted when it is empty.
+	var moveTo *int
 
-	if error != nil {
-		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Deleting list failed", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+	if moveToStr := r.URL.Query().Get("move_to"); moveToStr != "" {
+		target, err := strconv.Atoi(moveToStr)
+
+		if err != nil || target == id {
+			utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "move_to is not a valid list ID", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+			return
+		}
+
+		moveTo = &target
+	}
+
+	deleteTasks := r.URL.Query().Get("delete_tasks") == "true"
+
+	if moveTo != nil && deleteTasks {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Use either move_to or delete_tasks, not both", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
 
 		return
 	}
 
-	if rf, _ := result.RowsAffected(); rf != 1 {
-		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: fmt.Sprintf("List either already deleted or list with ID {%v} does not exist.", id), Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+	tx, err := h.DB.Begin()
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Deleting list failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
 
 		return
 	}
+	defer tx.Rollback()
+
+	var profileID *int
+
+	if err := tx.QueryRow("SELECT profile_id FROM lists WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", id).Scan(&profileID); err != nil {
+		if err == sql.ErrNoRows {
+			utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: fmt.Sprintf("List either already deleted or list with ID {%v} does not exist.", id), Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+		} else {
+			utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Deleting list failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+		}
 
-	utils.JsonResponse(w, http.StatusOK, models.MsgResponse{Message: fmt.Sprintf("Deleted list with ID {%v} successfully.", id)})
+		return
+	}
+
+	response := models.DeleteListResponse{Message: fmt.Sprintf("Deleted list with ID {%v} successfully.", id)}
+
+	if moveTo != nil {
+		var targetProfileID *int
+
+		err := tx.QueryRow("SELECT profile_id FROM lists WHERE id = $1 AND deleted_at IS NULL", *moveTo).Scan(&targetProfileID)
+
+		if err == sql.ErrNoRows || (err == nil && !db.SameID(profileID, targetProfileID)) {
+			utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: fmt.Sprintf("List with ID {%v} does not exist or belongs to another profile.", *moveTo), Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+			return
+		}
+
+		if err == nil {
+			response.MovedTasks, err = db.MoveListTasks(tx, id, *moveTo)
+		}
+
+		if err != nil {
+			utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Moving tasks failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+			return
+		}
+	} else {
+		if err := tx.QueryRow("SELECT COUNT(*) FROM tasks WHERE list_id = $1 AND deleted_at IS NULL", id).Scan(&response.DeletedTasks); err != nil {
+			utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Deleting list failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+			return
+		}
+
+		if response.DeletedTasks > 0 && !deleteTasks {
+			utils.JsonResponse(w, http.StatusConflict, models.ErrorResponseV2{Message: fmt.Sprintf("List with ID {%v} still has %v tasks. Pass move_to or delete_tasks=true.", id, response.DeletedTasks), Status: http.StatusConflict, Code: internal.ErrorCodeErrorMessage})
+
+			return
+		}
+	}
+
+	if _, err = db.SoftDeleteList(tx, id); err == nil {
+		err = tx.Commit()
+	}
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Deleting list failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusOK, response)
 }
 
 func (h *HandlerFn) getList(w http.ResponseWriter, r *http.Request) {
@@ -1279,13 +1432,13 @@ func (h *HandlerFn) getList(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	query := `SELECT id, name, created_at from lists where id=$1;`
+	query := `SELECT id, name, created_at, profile_id, group_id, position, auto_complete_parent, revision from lists where id=$1 AND deleted_at IS NULL;`
 
 	var list models.List
 
 	row := h.DB.QueryRow(query, id)
 
-	err = row.Scan(&list.ID, &list.Name, &list.CreatedAt)
+	err = row.Scan(&list.ID, &list.Name, &list.CreatedAt, &list.ProfileID, &list.GroupID, &list.Position, &list.AutoCompleteParent, &list.Revision)
 
 	if err != nil {
 		var message string
@@ -1304,6 +1457,7 @@ func (h *HandlerFn) getList(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	w.Header().Set("ETag", internal.ETag(list.Revision))
 	utils.JsonResponse(w, http.StatusOK, models.Response{Data: list})
 }
 
@@ -1312,9 +1466,12 @@ func (h *HandlerFn) profiles(w http.ResponseWriter, r *http.Request) {
 	SELECT 
     id, 
     name, 
-    created_at
+    created_at,
+    auto_complete_parent
 	FROM 
-    profiles;
+    profiles
+	WHERE
+    deleted_at IS NULL;
 	`
 
 	rows, err := h.DB.Query(query)
@@ -1328,7 +1485,7 @@ func (h *HandlerFn) profiles(w http.ResponseWriter, r *http.Request) {
 
 	for rows.Next() {
 		var profile models.Profile
-		if err := rows.Scan(&profile.ID, &profile.Name, &profile.CreatedAt); err != nil {
+		if err := rows.Scan(&profile.ID, &profile.Name, &profile.CreatedAt, &profile.AutoCompleteParent); err != nil {
 			utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: err.Error(), Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage})
 
 			return
@@ -1446,25 +1603,11 @@ func (h *HandlerFn) deleteProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, error := h.DB.Exec("DELETE FROM profiles where id = $1", id)
-
-	if error != nil {
-		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Deleting profile failed", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
-
-		return
-	}
-
-	if rf, _ := result.RowsAffected(); rf != 1 {
-		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: fmt.Sprintf("Profile either already deleted or Profile with ID {%v} does not exist.", id), Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
-
-		return
-	}
-
-	utils.JsonResponse(w, http.StatusOK, models.MsgResponse{Message: fmt.Sprintf("Deleted profile with ID {%v} successfully.", id)})
+	h.moveToTrash(w, id, "profile", db.SoftDeleteProfile)
 }
 
-func SetupRoutes(r *chi.Mux, db *sql.DB) {
-	routeHandler := HandlerFn{db}
+func SetupRoutes(r *chi.Mux, db *sql.DB, events *internal.Broker) {
+	routeHandler := HandlerFn{DB: db, Events: events}
 
 	r.Get("/", root)
 	r.Get("/health", healthCheck)
@@ -1472,10 +1615,18 @@ func SetupRoutes(r *chi.Mux, db *sql.DB) {
 
 	r.Get("/api/v1/hello-world", helloWorld)
 
+	r.Get("/calendar/{token}.ics", routeHandler.calendarFeed)
+	r.Put("/calendar/{token}/task-{id}.ics", routeHandler.updateTaskFromCalendar)
+
 	r.Group(func(r chi.Router) {
 		r.Use(internal.AuthWithApiKey)
 
 		r.Get("/api/v1/tasks", routeHandler.tasks)
+		r.Post("/api/v1/tasks/bulk", routeHandler.bulkTasks)
+		r.Post("/api/v1/batch", routeHandler.batch)
+		r.Get("/api/v1/sync", routeHandler.sync)
+		r.Get("/api/v1/events", routeHandler.events)
+		r.Get("/api/v1/events/ws", routeHandler.eventsWebSocket)
 		r.Post("/api/v1/task/create", routeHandler.createTask)
 		r.Post("/api/v1/task/sub-task/create", routeHandler.createSubTask)
 
@@ -1485,6 +1636,12 @@ func SetupRoutes(r *chi.Mux, db *sql.DB) {
 
 		r.Post("/api/v1/task/{id}/metadata", routeHandler.updateTaskMetadata)
 		r.Post("/api/v1/task/{id}/recurrence", routeHandler.updateRecurrencePattern)
+		r.Post("/api/v1/task/{id}/move", routeHandler.moveTask)
+		r.Post("/api/v1/task/{id}/duplicate", routeHandler.duplicateTask)
+
+		r.Get("/api/v1/task/{id}/blockers", routeHandler.blockers)
+		r.Post("/api/v1/task/{id}/blockers", routeHandler.addBlocker)
+		r.Delete("/api/v1/task/{id}/blocker/{blocker_id}", routeHandler.removeBlocker)
 
 		r.Delete("/api/v1/task/{id}", routeHandler.deleteTask)
 		r.Delete("/api/v1/sub-task/{id}", routeHandler.deleteSubTask)
@@ -1492,11 +1649,17 @@ func SetupRoutes(r *chi.Mux, db *sql.DB) {
 
 		r.Post("/api/v1/task/{id}/completed/toggle", routeHandler.toggleTask)
 		r.Post("/api/v1/sub-task/{id}/completed/toggle", routeHandler.toggleSubTask)
+		r.Post("/api/v1/sub-task/{id}/important/toggle", routeHandler.toggleSubTaskImportant)
+		r.Post("/api/v1/sub-task/{id}/add/due-date", routeHandler.addSubTaskDueDate)
+		r.Post("/api/v1/sub-task/{id}/promote", routeHandler.promoteSubTask)
+		r.Post("/api/v1/task/{id}/demote", routeHandler.demoteTask)
 
 		r.Post("/api/v1/task/{id}/important/toggle", routeHandler.toggleImportant)
+		r.Post("/api/v1/task/{id}/priority", routeHandler.updateTaskPriority)
 		r.Post("/api/v1/task/{id}/add-to-my-day/toggle", routeHandler.toggleAddToMyToday)
 
 		r.Get("/api/v1/fetch-title", routeHandler.fetchWebPageTitle)
+		r.Get("/api/v1/link-preview", routeHandler.linkPreview)
 		r.Get("/api/v1/title/sync", routeHandler.syncTitle)
 
 		r.Post("/api/v1/list/new", routeHandler.createList)
@@ -1504,15 +1667,63 @@ func SetupRoutes(r *chi.Mux, db *sql.DB) {
 		r.Post("/api/v1/list/{id}", routeHandler.updateListName)
 		r.Delete("/api/v1/list/{id}", routeHandler.deleteList)
 		r.Get("/api/v1/list/{id}", routeHandler.getList)
+		r.Post("/api/v1/list/{id}/move", routeHandler.moveList)
+		r.Post("/api/v1/list/{id}/duplicate", routeHandler.duplicateList)
+		r.Post("/api/v1/list/{id}/completion-rule", routeHandler.updateListCompletionRule)
+		r.Post("/api/v1/lists/reorder", routeHandler.reorderLists)
+
+		r.Post("/api/v1/list-group/new", routeHandler.createListGroup)
+		r.Post("/api/v1/list-group/{id}", routeHandler.updateListGroupName)
+		r.Delete("/api/v1/list-group/{id}", routeHandler.deleteListGroup)
+		r.Post("/api/v1/list-groups/reorder", routeHandler.reorderListGroups)
 
 		r.Post("/api/v1/profiles/new", routeHandler.createProfile)
 		r.Get("/api/v1/profiles", routeHandler.profiles)
 		r.Post("/api/v1/profile/{id}", routeHandler.updateProfileName)
 		r.Delete("/api/v1/profile/{id}", routeHandler.deleteProfile)
+		r.Post("/api/v1/profile/{id}/completion-rule", routeHandler.updateProfileCompletionRule)
+
+		r.Get("/api/v1/trash", routeHandler.trash)
+		r.Post("/api/v1/trash/{type}/{id}/restore", routeHandler.restoreFromTrash)
 		r.Post("/api/v1/task/{taskId}/list/update", routeHandler.updateTaskListId)
 
 		r.Post("/api/v1/task/{taskId}/list/update", routeHandler.updateTaskListId)
 
+		r.Get("/api/v1/task/{id}/note", routeHandler.getNote)
+		r.Post("/api/v1/task/{id}/note", routeHandler.saveNote)
+		r.Get("/api/v1/task/{id}/note/history", routeHandler.noteHistory)
+
+		r.Post("/api/v1/task/{id}/attachments", routeHandler.uploadAttachment)
+		r.Get("/api/v1/task/{id}/attachments", routeHandler.taskAttachments)
+		r.Get("/api/v1/attachment/{id}", routeHandler.downloadAttachment)
+		r.Delete("/api/v1/attachment/{id}", routeHandler.deleteAttachment)
+
+		r.Post("/api/v1/templates/new", routeHandler.createTemplate)
+		r.Get("/api/v1/templates", routeHandler.templates)
+		r.Get("/api/v1/templates/{id}", routeHandler.getTemplate)
+		r.Post("/api/v1/templates/{id}", routeHandler.updateTemplate)
+		r.Delete("/api/v1/templates/{id}", routeHandler.deleteTemplate)
+		r.Post("/api/v1/templates/{id}/instantiate", routeHandler.instantiateTemplate)
+
+		r.Get("/api/v1/export", routeHandler.export)
+		r.Post("/api/v1/import", routeHandler.importTasks)
+
+		r.Post("/api/v1/calendar/feeds", routeHandler.createCalendarFeed)
+		r.Get("/api/v1/calendar/feeds", routeHandler.calendarFeeds)
+		r.Delete("/api/v1/calendar/feed/{id}", routeHandler.deleteCalendarFeed)
+
+		r.Post("/api/v1/inbound-addresses", routeHandler.createInboundAddress)
+		r.Get("/api/v1/inbound-addresses", routeHandler.inboundAddresses)
+		r.Delete("/api/v1/inbound-address/{id}", routeHandler.deleteInboundAddress)
+
+		r.Post("/api/v1/webhooks/new", routeHandler.createWebhook)
+		r.Get("/api/v1/webhooks", routeHandler.webhooks)
+		r.Post("/api/v1/webhook/{id}", routeHandler.updateWebhook)
+		r.Delete("/api/v1/webhook/{id}", routeHandler.deleteWebhook)
+		r.Get("/api/v1/webhook/{id}/deliveries", routeHandler.webhookDeliveries)
+		r.Post("/api/v1/webhook/{id}/ping", routeHandler.pingWebhook)
+		r.Post("/api/v1/webhook-delivery/{id}/retry", routeHandler.retryWebhookDelivery)
+
 		r.Get("/api/v1/log", routeHandler.logs)
 		r.Post("/api/v1/log", routeHandler.createLog)
 	})
@@ -0,0 +1,227 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"todo-server/db"
+	"todo-server/internal"
+	"todo-server/models"
+	"todo-server/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+)
+
+// setProgress fills in the progress of the listed tasks from their sub task trees.
+func (h *HandlerFn) setProgress(tasks []models.Task) error {
+	var taskIDs []int
+
+	for i := range tasks {
+		tasks[i].Progress = internal.Progress(tasks[i].Completed, nil)
+
+		if tasks[i].SubTaskCount > 0 {
+			taskIDs = append(taskIDs, tasks[i].ID)
+		}
+	}
+
+	if len(taskIDs) == 0 {
+		return nil
+	}
+
+	subTasks, err := db.GetSubTasksByTaskIDs(h.DB, taskIDs)
+
+	if err != nil {
+		return err
+	}
+
+	for i := range tasks {
+		if tree := internal.SubTaskTree(subTasks[tasks[i].ID]); len(tree) > 0 {
+			tasks[i].Progress = internal.Progress(tasks[i].Completed, tree)
+		}
+	}
+
+	return nil
+}
+
+func (h *HandlerFn) promoteSubTask(w http.ResponseWriter, r *http.Request) {
+	id, id_err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if id_err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid sub task ID", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	tx, err := h.DB.Begin()
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Promoting sub task failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+	defer tx.Rollback()
+
+	taskID, err := db.PromoteSubTask(tx, id)
+
+	if err == sql.ErrNoRows {
+		utils.JsonResponse(w, http.StatusNotFound, models.ErrorResponseV2{Message: fmt.Sprintf("Sub task with ID {%v} does not exist.", id), Status: http.StatusNotFound, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	if err == nil {
+		err = tx.Commit()
+	}
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Promoting sub task failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	h.trackLinks("id", taskID)
+
+	utils.JsonResponse(w, http.StatusCreated, models.CreateTaskResponse{Message: "Sub task promoted to a task successfully", ID: taskID})
+}
+
+func (h *HandlerFn) demoteTask(w http.ResponseWriter, r *http.Request) {
+	id, id_err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if id_err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid task ID", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	var demote models.Demote
+
+	if err := json.NewDecoder(r.Body).Decode(&demote); err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid request body", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	validate := validator.New()
+
+	if err := validate.Struct(demote); err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{
+			Status:        http.StatusBadRequest,
+			Code:          internal.ErrorCodeValidationFailed,
+			Message:       "One or more fields are invalid",
+			InvalidFields: internal.ConstructInvalidFieldData(err)})
+
+		return
+	}
+
+	if demote.TaskID == id {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "A task cannot become its own sub task", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	tx, err := h.DB.Begin()
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Demoting task failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+	defer tx.Rollback()
+
+	subTaskID, err := db.DemoteTask(tx, id, demote.TaskID, demote.ParentID)
+
+	switch err {
+	case nil:
+		err = tx.Commit()
+	case sql.ErrNoRows:
+		utils.JsonResponse(w, http.StatusNotFound, models.ErrorResponseV2{Message: fmt.Sprintf("Task with ID {%v} or {%v} does not exist.", id, demote.TaskID), Status: http.StatusNotFound, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	case db.ErrInvalidParent:
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "parent_id is not a sub task of task_id", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	case db.ErrTaskHasData:
+		utils.JsonResponse(w, http.StatusConflict, models.ErrorResponseV2{Message: fmt.Sprintf("Task with ID {%v} has dependencies, a note or attachments. Remove them before demoting it.", id), Status: http.StatusConflict, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Demoting task failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusCreated, models.CreateTaskResponse{Message: "Task demoted to a sub task successfully", ID: subTaskID})
+}
+
+func (h *HandlerFn) toggleSubTaskImportant(w http.ResponseWriter, r *http.Request) {
+	id, id_err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if id_err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid sub task ID", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	result, err := h.DB.Exec("UPDATE sub_tasks SET is_important = NOT is_important WHERE id = $1 AND deleted_at IS NULL", id)
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Toggling sub task importance failed", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	if rf, _ := result.RowsAffected(); rf != 1 {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: fmt.Sprintf("Sub Task with ID {%v} does not exist.", id), Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusOK, models.MsgResponse{Message: "Toggled sub task's important"})
+}
+
+func (h *HandlerFn) addSubTaskDueDate(w http.ResponseWriter, r *http.Request) {
+	id, id_err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if id_err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid sub task ID", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	var subTask models.SubTask
+
+	if err := json.NewDecoder(r.Body).Decode(&subTask); err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid request body", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	if _, err := time.Parse("2006-01-02", subTask.DueDate); err != nil && subTask.DueDate != "" {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid Due Date", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	result, err := h.DB.Exec("UPDATE sub_tasks SET due_date = $1 WHERE id = $2 AND deleted_at IS NULL", subTask.DueDate, id)
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Updating sub task due date failed", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	if rf, _ := result.RowsAffected(); rf != 1 {
+		utils.JsonResponse(w, http.StatusNotFound, models.ErrorResponseV2{Message: fmt.Sprintf("Sub task with %v ID does not exist", id), Status: http.StatusNotFound, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusOK, models.MsgResponse{Message: "Due date updated successfully"})
+}
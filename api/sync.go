@@ -0,0 +1,36 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"todo-server/db"
+	"todo-server/internal"
+	"todo-server/models"
+	"todo-server/utils"
+)
+
+// sync returns the rows that changed since the token of the previous sync. The
+// first sync is made without a token and returns everything.
+func (h *HandlerFn) sync(w http.ResponseWriter, r *http.Request) {
+	var since int64
+
+	if token := r.URL.Query().Get("since"); token != "" {
+		var err error
+
+		if since, err = strconv.ParseInt(token, 10, 64); err != nil || since < 0 {
+			utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid sync token", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+			return
+		}
+	}
+
+	changes, err := db.Changes(h.DB, since)
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Fetching changes failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusOK, models.Response{Data: changes})
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"todo-server/db"
+	"todo-server/internal"
+	"todo-server/models"
+	"todo-server/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+)
+
+// moveTask places a task between two neighbours of a view ("list" or "my-day").
+// before_id is the task that should end up right above it and after_id the one
+// right below; either one is enough.
+func (h *HandlerFn) moveTask(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+
+	id, id_err := strconv.Atoi(idStr)
+
+	if id_err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid task ID", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	var move models.MoveTask
+
+	if err := json.NewDecoder(r.Body).Decode(&move); err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid request body", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	validate := validator.New()
+
+	if err := validate.Struct(move); err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{
+			Status:        http.StatusBadRequest,
+			Code:          internal.ErrorCodeValidationFailed,
+			Message:       "One or more fields are invalid",
+			InvalidFields: internal.ConstructInvalidFieldData(err)})
+
+		return
+	}
+
+	if move.BeforeID == nil && move.AfterID == nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Either before_id or after_id is required", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	tx, err := h.DB.Begin()
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Moving task failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+	defer tx.Rollback()
+
+	ids, positions, err := db.TaskOrder(tx, move.View, id)
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Moving task failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	if indexOf(ids, id) < 0 {
+		utils.JsonResponse(w, http.StatusNotFound, models.ErrorResponseV2{Message: "Task does not exist or is not part of this view", Status: http.StatusNotFound, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	// Tasks that were never moved don't have a position yet. Give every task of the
+	// view one in the order they are shown now, so there is something to go between.
+	for _, position := range positions {
+		if position == "" {
+			positions = internal.RankSequence(len(ids))
+
+			if err := db.SetTaskPositions(tx, move.View, ids, positions); err != nil {
+				utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Moving task failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+				return
+			}
+
+			break
+		}
+	}
+
+	var others []int
+	var otherPositions []string
+
+	for i := range ids {
+		if ids[i] != id {
+			others = append(others, ids[i])
+			otherPositions = append(otherPositions, positions[i])
+		}
+	}
+
+	var before, after string
+
+	if move.BeforeID != nil {
+		i := indexOf(others, *move.BeforeID)
+
+		if i < 0 {
+			utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "before_id is not part of this view", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+			return
+		}
+
+		before = otherPositions[i]
+
+		if i+1 < len(others) {
+			after = otherPositions[i+1]
+		}
+	}
+
+	if move.AfterID != nil {
+		i := indexOf(others, *move.AfterID)
+
+		if i < 0 {
+			utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "after_id is not part of this view", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+			return
+		}
+
+		after = otherPositions[i]
+
+		if move.BeforeID == nil && i > 0 {
+			before = otherPositions[i-1]
+		}
+	}
+
+	position, err := internal.RankBetween(before, after)
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "before_id and after_id are not next to each other", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	if err := db.SetTaskPositions(tx, move.View, []int{id}, []string{position}); err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Moving task failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Moving task failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusOK, models.Response{Data: models.TaskPosition{ID: id, Position: position}})
+}
+
+func indexOf(ids []int, id int) int {
+	for i := range ids {
+		if ids[i] == id {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,289 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"todo-server/db"
+	"todo-server/internal"
+	"todo-server/models"
+	"todo-server/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+)
+
+// maxTemplateSubTasks bounds the sub tasks of a template, nested ones included.
+const maxTemplateSubTasks = 500
+
+func countTemplateSubTasks(subTasks []models.TemplateSubTask) int {
+	count := len(subTasks)
+
+	for _, subTask := range subTasks {
+		count += countTemplateSubTasks(subTask.SubTasks)
+	}
+
+	return count
+}
+
+func templateID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid template ID", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return 0, false
+	}
+
+	return id, true
+}
+
+func decodeTemplate(w http.ResponseWriter, r *http.Request) (*models.TaskTemplate, bool) {
+	var template models.TaskTemplate
+
+	if err := json.NewDecoder(r.Body).Decode(&template); err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid request body", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return nil, false
+	}
+
+	if err := validator.New().Struct(template); err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{
+			Status:        http.StatusBadRequest,
+			Code:          internal.ErrorCodeValidationFailed,
+			Message:       "One or more fields are invalid",
+			InvalidFields: internal.ConstructInvalidFieldData(err)})
+
+		return nil, false
+	}
+
+	if countTemplateSubTasks(template.SubTasks) > maxTemplateSubTasks {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: fmt.Sprintf("A template can have at most %d sub tasks", maxTemplateSubTasks), Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return nil, false
+	}
+
+	return &template, true
+}
+
+func (h *HandlerFn) createTemplate(w http.ResponseWriter, r *http.Request) {
+	template, ok := decodeTemplate(w, r)
+
+	if !ok {
+		return
+	}
+
+	template.ID = 0
+
+	id, err := db.SaveTemplate(h.DB, *template)
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Creating template failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusCreated, models.CreateTaskResponse{Message: "Template created successfully", ID: id})
+}
+
+func (h *HandlerFn) templates(w http.ResponseWriter, r *http.Request) {
+	var profileID *int
+
+	if profileId := r.URL.Query().Get("profile_id"); profileId != "" && profileId != "null" {
+		id, err := strconv.Atoi(profileId)
+
+		if err != nil {
+			utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Profile ID is not valid", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+			return
+		}
+
+		profileID = &id
+	}
+
+	templates, err := db.GetTemplates(h.DB, profileID)
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Fetching templates failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusOK, models.Response{Data: templates})
+}
+
+func (h *HandlerFn) getTemplate(w http.ResponseWriter, r *http.Request) {
+	id, ok := templateID(w, r)
+
+	if !ok {
+		return
+	}
+
+	template, err := db.GetTemplate(h.DB, id)
+
+	if err == sql.ErrNoRows {
+		utils.JsonResponse(w, http.StatusNotFound, models.ErrorResponseV2{Message: fmt.Sprintf("Template with ID {%v} does not exist.", id), Status: http.StatusNotFound, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Fetching template failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusOK, models.Response{Data: template})
+}
+
+// updateTemplate replaces the template, sub tasks included.
+func (h *HandlerFn) updateTemplate(w http.ResponseWriter, r *http.Request) {
+	id, ok := templateID(w, r)
+
+	if !ok {
+		return
+	}
+
+	template, ok := decodeTemplate(w, r)
+
+	if !ok {
+		return
+	}
+
+	template.ID = id
+
+	_, err := db.SaveTemplate(h.DB, *template)
+
+	if err == sql.ErrNoRows {
+		utils.JsonResponse(w, http.StatusNotFound, models.ErrorResponseV2{Message: fmt.Sprintf("Template with ID {%v} does not exist.", id), Status: http.StatusNotFound, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: fmt.Sprintf("Updating template with ID {%v} failed.", id), Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusOK, models.MsgResponse{Message: "Updated template successfully."})
+}
+
+func (h *HandlerFn) deleteTemplate(w http.ResponseWriter, r *http.Request) {
+	id, ok := templateID(w, r)
+
+	if !ok {
+		return
+	}
+
+	result, err := h.DB.Exec("DELETE FROM task_templates WHERE id = $1", id)
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Deleting template failed", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	if rf, _ := result.RowsAffected(); rf != 1 {
+		utils.JsonResponse(w, http.StatusNotFound, models.ErrorResponseV2{Message: fmt.Sprintf("Template with ID {%v} does not exist.", id), Status: http.StatusNotFound, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusOK, models.MsgResponse{Message: fmt.Sprintf("Deleted template with ID {%v} successfully.", id)})
+}
+
+// instantiateTemplate creates the task of a template with all of its sub tasks,
+// or nothing at all.
+func (h *HandlerFn) instantiateTemplate(w http.ResponseWriter, r *http.Request) {
+	id, ok := templateID(w, r)
+
+	if !ok {
+		return
+	}
+
+	var options models.InstantiateTemplate
+
+	// The body is optional.
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&options); err != nil {
+			utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid request body", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+			return
+		}
+	}
+
+	start := time.Now()
+
+	if options.StartDate != "" {
+		var err error
+
+		if start, err = time.Parse("2006-01-02", options.StartDate); err != nil {
+			utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid start date", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+			return
+		}
+	}
+
+	tx, err := h.DB.Begin()
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Instantiating template failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+	defer tx.Rollback()
+
+	template, err := db.GetTemplate(tx, id)
+
+	if err == sql.ErrNoRows {
+		utils.JsonResponse(w, http.StatusNotFound, models.ErrorResponseV2{Message: fmt.Sprintf("Template with ID {%v} does not exist.", id), Status: http.StatusNotFound, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	if err == nil && options.ProfileID != nil && !db.SameID(options.ProfileID, template.ProfileID) {
+		// The list of the template belongs to its profile.
+		template.ProfileID = options.ProfileID
+		template.ListID = nil
+	}
+
+	if err == nil && options.ListID != nil {
+		template.ListID = options.ListID
+	}
+
+	if err == nil && template.ListID != nil {
+		var listProfileID *int
+
+		err = tx.QueryRow("SELECT profile_id FROM lists WHERE id = $1 AND deleted_at IS NULL", *template.ListID).Scan(&listProfileID)
+
+		if err == sql.ErrNoRows || (err == nil && !db.SameID(listProfileID, template.ProfileID)) {
+			utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: fmt.Sprintf("List with ID {%v} does not exist in the profile.", *template.ListID), Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+			return
+		}
+	}
+
+	var taskID int
+
+	if err == nil {
+		taskID, err = db.InstantiateTemplate(tx, *template, start)
+	}
+
+	if err == nil {
+		err = tx.Commit()
+	}
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Instantiating template failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	internal.TrackLinks(h.DB, internal.TaskURLs(template.TaskName, template.Metadata))
+
+	utils.JsonResponse(w, http.StatusCreated, models.CreateTaskResponse{Message: "Task created from template successfully", ID: taskID})
+}
@@ -0,0 +1,167 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"todo-server/db"
+	"todo-server/internal"
+	"todo-server/models"
+	"todo-server/utils"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// moveToTrash is shared by the delete endpoints. Nothing is removed here; the
+// purge job deletes trashed rows once they are older than the retention period.
+func (h *HandlerFn) moveToTrash(w http.ResponseWriter, id int, what string, softDelete func(*sql.Tx, int) (bool, error)) {
+	tx, err := h.DB.Begin()
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: fmt.Sprintf("Deleting %s failed", what), Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+	defer tx.Rollback()
+
+	ok, err := softDelete(tx, id)
+
+	if err == nil && ok {
+		err = tx.Commit()
+	}
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: fmt.Sprintf("Deleting %s failed", what), Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	if !ok {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: fmt.Sprintf("%s either already deleted or %s with ID {%v} does not exist.", strings.ToUpper(what[:1])+what[1:], what, id), Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusOK, models.MsgResponse{Message: fmt.Sprintf("Deleted %s with ID {%v} successfully.", what, id)})
+}
+
+func (h *HandlerFn) trash(w http.ResponseWriter, r *http.Request) {
+	var profileID *int
+
+	if profileId := r.URL.Query().Get("profile_id"); profileId != "" && profileId != "null" {
+		id, err := strconv.Atoi(profileId)
+
+		if err != nil {
+			utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Profile ID is not valid", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+			return
+		}
+
+		profileID = &id
+	}
+
+	query := `
+	SELECT type, id, name, list_id, profile_id, deleted_at
+	FROM (
+		SELECT 'task' AS type, id, name, list_id, profile_id, deleted_at
+		FROM tasks WHERE deleted_at IS NOT NULL
+		UNION ALL
+		SELECT 'sub-task', st.id, st.name, t.list_id, t.profile_id, st.deleted_at
+		FROM sub_tasks st JOIN tasks t ON t.id = st.task_id
+		WHERE st.deleted_at IS NOT NULL
+		UNION ALL
+		SELECT 'list', id, name, NULL, profile_id, deleted_at
+		FROM lists WHERE deleted_at IS NOT NULL
+		UNION ALL
+		SELECT 'profile', id, name, NULL, id, deleted_at
+		FROM profiles WHERE deleted_at IS NOT NULL
+	) trash
+	WHERE $1::INT IS NULL OR profile_id = $1
+	ORDER BY deleted_at DESC, type, id
+	`
+
+	rows, err := h.DB.Query(query, profileID)
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Fetching trash failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+	defer rows.Close()
+
+	items := []models.TrashItem{}
+
+	for rows.Next() {
+		var item models.TrashItem
+
+		if err := rows.Scan(&item.Type, &item.ID, &item.Name, &item.ListID, &item.ProfileID, &item.DeletedAt); err != nil {
+			utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Fetching trash failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+			return
+		}
+
+		items = append(items, item)
+	}
+
+	utils.JsonResponse(w, http.StatusOK, models.Response{Data: items})
+}
+
+var restorers = map[string]func(*sql.Tx, int) (bool, error){
+	"task":     db.RestoreTask,
+	"sub-task": db.RestoreSubTask,
+	"list":     db.RestoreList,
+	"profile":  db.RestoreProfile,
+}
+
+// restoreFromTrash takes an item of the trash back. A task goes back into its
+// original list, which is restored with it when it was deleted as well.
+func (h *HandlerFn) restoreFromTrash(w http.ResponseWriter, r *http.Request) {
+	kind := chi.URLParam(r, "type")
+
+	restore, found := restorers[kind]
+
+	if !found {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: fmt.Sprintf("Unknown trash item type %q", kind), Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	id, id_err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if id_err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid ID", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	tx, err := h.DB.Begin()
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Restoring failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+	defer tx.Rollback()
+
+	ok, err := restore(tx, id)
+
+	if err == nil && ok {
+		err = tx.Commit()
+	}
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Restoring failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	if !ok {
+		utils.JsonResponse(w, http.StatusNotFound, models.ErrorResponseV2{Message: fmt.Sprintf("There is no %s with ID {%v} in the trash.", kind, id), Status: http.StatusNotFound, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusOK, models.MsgResponse{Message: fmt.Sprintf("Restored %s with ID {%v} successfully.", kind, id)})
+}
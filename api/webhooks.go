@@ -0,0 +1,340 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"todo-server/db"
+	"todo-server/internal"
+	"todo-server/models"
+	"todo-server/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/lib/pq"
+)
+
+const webhookDeliveryColumns = "id, webhook_id, event, payload, status, attempts, response_status, error, next_attempt_at, delivered_at, created_at"
+
+func decodeWebhook(w http.ResponseWriter, r *http.Request) (*models.Webhook, bool) {
+	var webhook models.Webhook
+
+	if err := json.NewDecoder(r.Body).Decode(&webhook); err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid request body", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return nil, false
+	}
+
+	if err := validator.New().Struct(webhook); err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{
+			Status:        http.StatusBadRequest,
+			Code:          internal.ErrorCodeValidationFailed,
+			Message:       "One or more fields are invalid",
+			InvalidFields: internal.ConstructInvalidFieldData(err)})
+
+		return nil, false
+	}
+
+	if webhook.AllProfiles && webhook.ProfileID != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "profile_id and all_profiles can't be given together", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return nil, false
+	}
+
+	return &webhook, true
+}
+
+func webhookID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid webhook ID", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return 0, false
+	}
+
+	return id, true
+}
+
+func scanWebhookDelivery(row interface{ Scan(...any) error }) (models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+
+	err := row.Scan(&delivery.ID, &delivery.WebhookID, &delivery.Event, &delivery.Payload, &delivery.Status, &delivery.Attempts,
+		&delivery.ResponseStatus, &delivery.Error, &delivery.NextAttemptAt, &delivery.DeliveredAt, &delivery.CreatedAt)
+
+	return delivery, err
+}
+
+// createWebhook subscribes a URL to events. A secret is generated when none is
+// given; deliveries are signed with it in the X-Todo-Signature header.
+func (h *HandlerFn) createWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := decodeWebhook(w, r)
+
+	if !ok {
+		return
+	}
+
+	if webhook.Secret == "" {
+		secret, err := internal.GenerateToken()
+
+		if err != nil {
+			utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Generating webhook secret failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+			return
+		}
+
+		webhook.Secret = secret
+	}
+
+	if webhook.Active == nil {
+		active := true
+		webhook.Active = &active
+	}
+
+	query := `
+	INSERT INTO webhooks (url, secret, events, profile_id, all_profiles, active)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING id, created_at;
+	`
+
+	err := h.DB.QueryRow(query, webhook.URL, webhook.Secret, pq.Array(webhook.Events), webhook.ProfileID, webhook.AllProfiles, *webhook.Active).Scan(&webhook.ID, &webhook.CreatedAt)
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Creating webhook failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusCreated, models.Response{Data: webhook})
+}
+
+func (h *HandlerFn) webhooks(w http.ResponseWriter, r *http.Request) {
+	rows, err := h.DB.Query("SELECT id, url, events, profile_id, all_profiles, active, created_at FROM webhooks ORDER BY created_at DESC")
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Fetching webhooks failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+	defer rows.Close()
+
+	webhooks := []models.Webhook{}
+
+	for rows.Next() {
+		var webhook models.Webhook
+
+		if err := rows.Scan(&webhook.ID, &webhook.URL, pq.Array(&webhook.Events), &webhook.ProfileID, &webhook.AllProfiles, &webhook.Active, &webhook.CreatedAt); err != nil {
+			utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: err.Error(), Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage})
+
+			return
+		}
+
+		webhooks = append(webhooks, webhook)
+	}
+
+	utils.JsonResponse(w, http.StatusOK, models.Response{Data: webhooks})
+}
+
+// updateWebhook replaces the URL, events and profiles of a webhook. The secret is
+// only rotated and active only changed when they are given.
+func (h *HandlerFn) updateWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r)
+
+	if !ok {
+		return
+	}
+
+	webhook, ok := decodeWebhook(w, r)
+
+	if !ok {
+		return
+	}
+
+	query := `
+	UPDATE webhooks
+	SET url = $2, events = $3, profile_id = $4, all_profiles = $5, active = COALESCE($6, active), secret = COALESCE(NULLIF($7, ''), secret)
+	WHERE id = $1
+	`
+
+	result, err := h.DB.Exec(query, id, webhook.URL, pq.Array(webhook.Events), webhook.ProfileID, webhook.AllProfiles, webhook.Active, webhook.Secret)
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: fmt.Sprintf("Updating webhook with ID {%v} failed.", id), Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	if rf, _ := result.RowsAffected(); rf != 1 {
+		utils.JsonResponse(w, http.StatusNotFound, models.ErrorResponseV2{Message: fmt.Sprintf("Webhook with ID {%v} does not exist.", id), Status: http.StatusNotFound, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusOK, models.MsgResponse{Message: "Updated webhook successfully."})
+}
+
+func (h *HandlerFn) deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r)
+
+	if !ok {
+		return
+	}
+
+	result, err := h.DB.Exec("DELETE FROM webhooks WHERE id = $1", id)
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Deleting webhook failed", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	if rf, _ := result.RowsAffected(); rf != 1 {
+		utils.JsonResponse(w, http.StatusNotFound, models.ErrorResponseV2{Message: fmt.Sprintf("Webhook with ID {%v} does not exist.", id), Status: http.StatusNotFound, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusOK, models.MsgResponse{Message: fmt.Sprintf("Deleted webhook with ID {%v} successfully.", id)})
+}
+
+// webhookDeliveries is the delivery log of a webhook, newest first. It can be
+// narrowed down with status (pending, delivered or failed) and size.
+func (h *HandlerFn) webhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r)
+
+	if !ok {
+		return
+	}
+
+	size := 50
+
+	if value, err := strconv.Atoi(r.URL.Query().Get("size")); err == nil && value > 0 {
+		size = min(value, 200)
+	}
+
+	var status *string
+
+	if value := r.URL.Query().Get("status"); value != "" {
+		if value != "pending" && value != "delivered" && value != "failed" {
+			utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid status", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+			return
+		}
+
+		status = &value
+	}
+
+	query := "SELECT " + webhookDeliveryColumns + " FROM webhook_deliveries WHERE webhook_id = $1 AND ($2::webhook_delivery_status_enum IS NULL OR status = $2) ORDER BY id DESC LIMIT $3"
+
+	rows, err := h.DB.Query(query, id, status, size)
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Fetching webhook deliveries failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+
+		if err != nil {
+			utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: err.Error(), Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage})
+
+			return
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	utils.JsonResponse(w, http.StatusOK, models.Response{Data: deliveries})
+}
+
+// pingWebhook sends a ping event right away and returns how the delivery went,
+// to check the URL and the signature handling of a receiver.
+func (h *HandlerFn) pingWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r)
+
+	if !ok {
+		return
+	}
+
+	var job db.WebhookJob
+
+	query := `
+	WITH webhook AS (
+		SELECT id, url, secret FROM webhooks WHERE id = $1
+	), delivery AS (
+		INSERT INTO webhook_deliveries (webhook_id, event, payload, attempts, next_attempt_at)
+		SELECT id, 'ping', jsonb_build_object('webhook_id', id), 1, CURRENT_TIMESTAMP + INTERVAL '5 minutes'
+		FROM webhook
+		RETURNING id, event, payload
+	)
+	SELECT delivery.id, delivery.event, delivery.payload, webhook.url, webhook.secret
+	FROM delivery, webhook
+	`
+
+	err := h.DB.QueryRow(query, id).Scan(&job.ID, &job.Event, &job.Payload, &job.URL, &job.Secret)
+
+	if err == sql.ErrNoRows {
+		utils.JsonResponse(w, http.StatusNotFound, models.ErrorResponseV2{Message: fmt.Sprintf("Webhook with ID {%v} does not exist.", id), Status: http.StatusNotFound, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Pinging webhook failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	// A ping is not retried.
+	status, deliveryErr := internal.DeliverWebhook(job.URL, job.Secret, job.ID, job.Event, job.Payload)
+
+	if err := db.FinishWebhookDelivery(h.DB, job.ID, status, deliveryErr, 0); err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Saving webhook delivery failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	delivery, err := scanWebhookDelivery(h.DB.QueryRow("SELECT "+webhookDeliveryColumns+" FROM webhook_deliveries WHERE id = $1", job.ID))
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Fetching webhook delivery failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusOK, models.Response{Data: delivery})
+}
+
+// retryWebhookDelivery queues a failed delivery again with a fresh set of attempts.
+func (h *HandlerFn) retryWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusBadRequest, models.ErrorResponseV2{Message: "Invalid webhook delivery ID", Status: http.StatusBadRequest, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	result, err := h.DB.Exec("UPDATE webhook_deliveries SET status = 'pending', attempts = 0, next_attempt_at = CURRENT_TIMESTAMP WHERE id = $1 AND status = 'failed'", id)
+
+	if err != nil {
+		utils.JsonResponse(w, http.StatusInternalServerError, models.ErrorResponseV2{Message: "Retrying webhook delivery failed", Status: http.StatusInternalServerError, Code: internal.ErrorCodeErrorMessage, Error: err.Error()})
+
+		return
+	}
+
+	if rf, _ := result.RowsAffected(); rf != 1 {
+		utils.JsonResponse(w, http.StatusNotFound, models.ErrorResponseV2{Message: fmt.Sprintf("Failed webhook delivery with ID {%v} does not exist.", id), Status: http.StatusNotFound, Code: internal.ErrorCodeErrorMessage})
+
+		return
+	}
+
+	utils.JsonResponse(w, http.StatusOK, models.MsgResponse{Message: "Webhook delivery queued again."})
+}
@@ -4,7 +4,7 @@ import (
 	"bytes"
 	"database/sql"
 	"encoding/base64"
-	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"log"
 	"mime/multipart"
@@ -13,104 +13,233 @@ import (
 	"todo-server/models"
 )
 
+const (
+	KindFull  = "full"
+	KindDelta = "delta"
+)
+
+// Tables that are journaled in change_log. The order matters when restoring
+// because of the foreign keys between them.
+var Tables = []string{"profiles", "lists", "tasks", "sub_tasks"}
+
+// A full snapshot is taken at least this often so that a restore never has to
+// replay a long chain of deltas.
+const fullSnapshotInterval = 7 * 24 * time.Hour
+
 func BackupTasks(DB *sql.DB, emailAuth models.EmailAuth) {
-	csvData, err := generateCSVData(DB)
+	lastFull, lastChangeID, err := lastBackup(DB)
 
 	if err != nil {
-		log.Println("Failed to generate csv data", err)
+		log.Println("Failed to get the last backup", err)
 		return
 	}
 
-	encodedCsv := base64.StdEncoding.EncodeToString(csvData.Bytes())
+	var file *models.BackupFile
+
+	if lastFull.IsZero() || time.Since(lastFull) > fullSnapshotInterval {
+		file, err = generateSnapshot(DB)
+	} else {
+		file, err = generateDelta(DB, lastChangeID)
+	}
+
+	if err != nil {
+		log.Println("Failed to generate backup data", err)
+		return
+	}
+
+	if file.Kind == KindDelta && len(file.Changes) == 0 {
+		log.Println("Nothing changed since the last backup")
+		return
+	}
 
-	if success := sendEmail(emailAuth, "Attached is the backup CSV file you requested.", encodedCsv); !success {
+	data, err := json.Marshal(file)
+
+	if err != nil {
+		log.Println("Failed to encode backup data", err)
+		return
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(data)
+	filename := fmt.Sprintf("%s-%s.json", file.Kind, file.CreatedAt.Format("20060102-150405"))
+
+	body := fmt.Sprintf("Attached is the %s backup file. It contains changes up to change #%d.", file.Kind, file.ToChangeID)
+
+	if success := sendEmail(emailAuth, body, filename, encoded); !success {
 		log.Println("Sending backup email failed")
 
 		return
 	}
 
-	log.Println("Backup successful")
+	if err := recordBackup(DB, file); err != nil {
+		log.Println("Failed to record the backup", err)
+		return
+	}
+
+	log.Println("Backup successful", file.Kind, file.ToChangeID)
 }
 
-func sendEmail(emailAuth models.EmailAuth, body string, encodedCsv string) bool {
-	smtpHost := "smtp.gmail.com"
-	smtpPort := "587"
-	addr := fmt.Sprintf("%v:%v", smtpHost, smtpPort)
-	auth := smtp.PlainAuth("", emailAuth.FromEmail, emailAuth.Password, smtpHost)
+// lastBackup returns when the last full snapshot was taken and the change id
+// the most recent backup (full or delta) covers.
+func lastBackup(DB *sql.DB) (time.Time, int64, error) {
+	var lastFull sql.NullTime
+	var lastChangeID int64
 
-	now := time.Now()
-	subject := fmt.Sprintf("Backup file generated on %s", now.Format("Monday, January 2, 2006 3:04 PM"))
+	query := `
+	SELECT
+		(SELECT MAX(created_at) FROM backups WHERE kind = $1),
+		COALESCE((SELECT last_change_id FROM backups ORDER BY id DESC LIMIT 1), 0)
+	`
 
-	emailContent := createEmailWithAttachment(emailAuth.FromEmail, emailAuth.ToEmail, subject, body, "backup.csv", encodedCsv)
+	if err := DB.QueryRow(query, KindFull).Scan(&lastFull, &lastChangeID); err != nil {
+		return time.Time{}, 0, err
+	}
 
-	err := smtp.SendMail(addr, auth, emailAuth.FromEmail, []string{emailAuth.ToEmail}, emailContent.Bytes())
+	return lastFull.Time, lastChangeID, nil
+}
+
+func recordBackup(DB *sql.DB, file *models.BackupFile) error {
+	_, err := DB.Exec("INSERT INTO backups (kind, last_change_id) VALUES ($1, $2)", file.Kind, file.ToChangeID)
 
 	if err != nil {
-		log.Println("Failed to send backup email", err.Error())
-		return false
+		return err
 	}
 
-	return true
-}
+	if file.Kind != KindFull {
+		return nil
+	}
 
-func generateCSVData(DB *sql.DB) (*bytes.Buffer, error) {
-	query := "select * from tasks"
+	// Everything up to a full snapshot is already contained in it.
+	_, err = DB.Exec("DELETE FROM change_log WHERE id <= $1", file.ToChangeID)
 
-	rows, err := DB.Query(query)
-	defer rows.Close()
+	return err
+}
+
+func generateSnapshot(DB *sql.DB) (*models.BackupFile, error) {
+	// Repeatable read so that the rows and the change id are from the same point in time.
+	tx, err := DB.Begin()
 
 	if err != nil {
-		log.Println("Failed to execute query to backup tasks")
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("SET TRANSACTION ISOLATION LEVEL REPEATABLE READ"); err != nil {
+		return nil, err
+	}
+
+	if err := lockChangeLog(tx); err != nil {
 		return nil, err
 	}
 
-	var csvBuffer bytes.Buffer
-	writer := csv.NewWriter(&csvBuffer)
-	defer writer.Flush()
+	file := models.BackupFile{Kind: KindFull, CreatedAt: time.Now(), Tables: map[string]json.RawMessage{}}
 
-	columns, err := rows.Columns()
+	if err := tx.QueryRow("SELECT COALESCE(MAX(id), 0) FROM change_log").Scan(&file.ToChangeID); err != nil {
+		return nil, err
+	}
+
+	for _, table := range Tables {
+		var rows []byte
+
+		// Table names come from the Tables list above, never from user input.
+		query := fmt.Sprintf("SELECT COALESCE(jsonb_agg(to_jsonb(t) ORDER BY t.id), '[]'::jsonb) FROM %s t", table)
+
+		if err := tx.QueryRow(query).Scan(&rows); err != nil {
+			log.Println("Failed to snapshot table", table)
+			return nil, err
+		}
+
+		file.Tables[table] = rows
+	}
+
+	return &file, tx.Commit()
+}
+
+// lockChangeLog waits for the transactions that are writing to change_log to
+// commit and keeps new ones out until the backup is read. Change ids are taken
+// before commit, so without it a change could commit after the backup with an
+// id lower than the one the backup ends at, and no backup would ever contain it.
+// It has to run before the first query, which takes the snapshot.
+func lockChangeLog(tx *sql.Tx) error {
+	_, err := tx.Exec("LOCK TABLE change_log IN SHARE MODE")
+
+	return err
+}
+
+func generateDelta(DB *sql.DB, fromChangeID int64) (*models.BackupFile, error) {
+	tx, err := DB.Begin()
 
 	if err != nil {
-		log.Println("Getting all the rows failed", err.Error())
 		return nil, err
 	}
+	defer tx.Rollback()
 
-	if err := writer.Write(columns); err != nil {
-		log.Println("Writing columns to write failed", err.Error())
+	if _, err := tx.Exec("SET TRANSACTION ISOLATION LEVEL REPEATABLE READ"); err != nil {
 		return nil, err
 	}
 
-	for rows.Next() {
-		columns := make([]interface{}, len(columns))
-		columnsPoints := make([]interface{}, len(columns))
+	if err := lockChangeLog(tx); err != nil {
+		return nil, err
+	}
 
-		for i := range columns {
-			columnsPoints[i] = &columns[i]
-		}
+	query := `
+	SELECT id, table_name, operation, row_id, data, created_at
+	FROM change_log
+	WHERE id > $1
+	ORDER BY id ASC
+	`
+
+	rows, err := tx.Query(query, fromChangeID)
+
+	if err != nil {
+		log.Println("Failed to execute query to backup changes")
+		return nil, err
+	}
+	defer rows.Close()
+
+	file := models.BackupFile{Kind: KindDelta, FromChangeID: fromChangeID, ToChangeID: fromChangeID, CreatedAt: time.Now()}
 
-		if err := rows.Scan(columnsPoints...); err != nil {
+	for rows.Next() {
+		var entry models.ChangeLogEntry
+		var data []byte
+
+		if err := rows.Scan(&entry.ID, &entry.TableName, &entry.Operation, &entry.RowID, &data, &entry.CreatedAt); err != nil {
 			log.Println("Failed to scan rows", err.Error())
 			return nil, err
 		}
 
-		record := make([]string, len(columns))
-
-		for i, col := range columns {
-			if col != nil {
-				record[i] = fmt.Sprintf("%v", col)
-			}
+		if data != nil {
+			entry.Data = data
 		}
 
-		if err := writer.Write(record); err != nil {
-			log.Println("Writing record to csv writer failed", err.Error())
-			return nil, err
-		}
+		file.Changes = append(file.Changes, entry)
+		file.ToChangeID = entry.ID
+	}
+
+	return &file, rows.Err()
+}
+
+func sendEmail(emailAuth models.EmailAuth, body string, filename string, encodedAttachment string) bool {
+	smtpHost := "smtp.gmail.com"
+	smtpPort := "587"
+	addr := fmt.Sprintf("%v:%v", smtpHost, smtpPort)
+	auth := smtp.PlainAuth("", emailAuth.FromEmail, emailAuth.Password, smtpHost)
+
+	now := time.Now()
+	subject := fmt.Sprintf("Backup file generated on %s", now.Format("Monday, January 2, 2006 3:04 PM"))
+
+	emailContent := createEmailWithAttachment(emailAuth.FromEmail, emailAuth.ToEmail, subject, body, filename, "application/json", encodedAttachment)
+
+	err := smtp.SendMail(addr, auth, emailAuth.FromEmail, []string{emailAuth.ToEmail}, emailContent.Bytes())
+
+	if err != nil {
+		log.Println("Failed to send backup email", err.Error())
+		return false
 	}
 
-	return &csvBuffer, nil
+	return true
 }
 
-func createEmailWithAttachment(from, to, subject, body, filename, encodedAttachment string) *bytes.Buffer {
+func createEmailWithAttachment(from, to, subject, body, filename, contentType, encodedAttachment string) *bytes.Buffer {
 	var emailBuffer bytes.Buffer
 	boundary := "boundary12345"
 	writer := multipart.NewWriter(&emailBuffer)
@@ -130,7 +259,7 @@ func createEmailWithAttachment(from, to, subject, body, filename, encodedAttachm
 	emailBuffer.WriteString("\r\n")
 
 	emailBuffer.WriteString(fmt.Sprintf("--%s\r\n", boundary))
-	emailBuffer.WriteString(fmt.Sprintf("Content-Type: text/csv; name=\"%s\"\r\n", filename))
+	emailBuffer.WriteString(fmt.Sprintf("Content-Type: %s; name=\"%s\"\r\n", contentType, filename))
 	emailBuffer.WriteString("Content-Transfer-Encoding: base64\r\n")
 	emailBuffer.WriteString(fmt.Sprintf("Content-Disposition: attachment; filename=\"%s\"\r\n", filename))
 	emailBuffer.WriteString("\r\n")
@@ -0,0 +1,251 @@
+package backup
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+	"todo-server/models"
+
+	"github.com/lib/pq"
+)
+
+// Restore brings the journaled tables back to the snapshot and replays the
+// deltas on top of it. Changes made after until are skipped; a zero until
+// replays everything.
+func Restore(DB *sql.DB, snapshot models.BackupFile, deltas []models.BackupFile, until time.Time) error {
+	changes, err := ChangesToReplay(snapshot, deltas, until)
+
+	if err != nil {
+		return err
+	}
+
+	tx, err := DB.Begin()
+
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("SET LOCAL todo.skip_change_log = 'on'"); err != nil {
+		return err
+	}
+
+	// The rows of the snapshot are written over the current ones instead of
+	// truncating the tables, which would also empty every table referencing them
+	// (notes, attachments, dependencies, webhooks, ...). Only the rows that are
+	// not in the snapshot are deleted, children first.
+	for _, table := range Tables {
+		rows, ok := snapshot.Tables[table]
+
+		if !ok {
+			continue
+		}
+
+		if err := upsertRows(tx, table, rows); err != nil {
+			return fmt.Errorf("failed to restore %s from snapshot: %v", table, err)
+		}
+	}
+
+	for i := len(Tables) - 1; i >= 0; i-- {
+		table := Tables[i]
+
+		rows, ok := snapshot.Tables[table]
+
+		if !ok {
+			rows = json.RawMessage("[]")
+		}
+
+		query := fmt.Sprintf("DELETE FROM %s WHERE id NOT IN (SELECT id FROM jsonb_populate_recordset(NULL::%s, $1))", table, table)
+
+		if _, err := tx.Exec(query, []byte(rows)); err != nil {
+			return fmt.Errorf("failed to remove rows of %s that are not in the snapshot: %v", table, err)
+		}
+	}
+
+	for _, change := range changes {
+		if err := applyChange(tx, change); err != nil {
+			return fmt.Errorf("failed to replay change #%d: %v", change.ID, err)
+		}
+	}
+
+	// Sequences are never moved back, ids of removed rows are not handed out again.
+	for _, table := range Tables {
+		query := fmt.Sprintf("SELECT setval(pg_get_serial_sequence('%s', 'id'), GREATEST(COALESCE(MAX(id), 0), nextval(pg_get_serial_sequence('%s', 'id')) - 1, 1)) FROM %s", table, table, table)
+
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to reset id sequence of %s: %v", table, err)
+		}
+	}
+
+	// Triggers do not announce the restored rows, the clients are told to fetch
+	// everything again once the restore is committed.
+	if _, err := tx.Exec(`SELECT pg_notify('changes', '{"action":"resync"}')`); err != nil {
+		return err
+	}
+
+	log.Printf("Restored snapshot #%d and replayed %d changes", snapshot.ToChangeID, len(changes))
+
+	return tx.Commit()
+}
+
+// ChangesToReplay checks that the deltas form an unbroken chain starting at the
+// snapshot and returns their changes in order, up to and including until.
+func ChangesToReplay(snapshot models.BackupFile, deltas []models.BackupFile, until time.Time) ([]models.ChangeLogEntry, error) {
+	if snapshot.Kind != KindFull {
+		return nil, fmt.Errorf("expected a %s backup as the snapshot, got %q", KindFull, snapshot.Kind)
+	}
+
+	if !until.IsZero() && snapshot.CreatedAt.After(until) {
+		return nil, fmt.Errorf("snapshot was taken at %v which is after %v", snapshot.CreatedAt, until)
+	}
+
+	sorted := append([]models.BackupFile{}, deltas...)
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].FromChangeID < sorted[j].FromChangeID
+	})
+
+	var changes []models.ChangeLogEntry
+
+	next := snapshot.ToChangeID
+
+	for _, delta := range sorted {
+		if delta.Kind != KindDelta {
+			return nil, fmt.Errorf("expected a %s backup, got %q", KindDelta, delta.Kind)
+		}
+
+		// Deltas that are entirely covered by the snapshot are not needed.
+		if delta.ToChangeID <= snapshot.ToChangeID {
+			continue
+		}
+
+		if delta.FromChangeID != next {
+			return nil, fmt.Errorf("missing backup for changes #%d to #%d", next+1, delta.FromChangeID)
+		}
+
+		for _, change := range delta.Changes {
+			if !until.IsZero() && change.CreatedAt.After(until) {
+				return changes, nil
+			}
+
+			changes = append(changes, change)
+		}
+
+		next = delta.ToChangeID
+	}
+
+	return changes, nil
+}
+
+func applyChange(tx *sql.Tx, change models.ChangeLogEntry) error {
+	if !isJournaledTable(change.TableName) {
+		return fmt.Errorf("unknown table %q", change.TableName)
+	}
+
+	table := change.TableName
+
+	if change.Operation == "DELETE" {
+		_, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE id = $1", table), change.RowID)
+		return err
+	}
+
+	var row map[string]json.RawMessage
+
+	if err := json.Unmarshal(change.Data, &row); err != nil {
+		return err
+	}
+
+	var columns []string
+
+	for column := range row {
+		columns = append(columns, pq.QuoteIdentifier(column))
+	}
+
+	sort.Strings(columns)
+
+	columnList := strings.Join(columns, ", ")
+
+	// Update in place rather than delete and insert so that ON DELETE CASCADE does not
+	// remove the children of the row.
+	query := fmt.Sprintf(
+		"UPDATE %s SET (%s) = (SELECT %s FROM jsonb_populate_record(NULL::%s, $1)) WHERE id = $2",
+		table, columnList, columnList, table,
+	)
+
+	result, err := tx.Exec(query, []byte(change.Data), change.RowID)
+
+	if err != nil {
+		return err
+	}
+
+	if rf, _ := result.RowsAffected(); rf == 1 {
+		return nil
+	}
+
+	query = fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM jsonb_populate_record(NULL::%s, $1)", table, columnList, columnList, table)
+
+	_, err = tx.Exec(query, []byte(change.Data))
+
+	return err
+}
+
+// upsertRows inserts the rows of the table, updating the ones that exist already.
+// Only the columns present in the backup are written, so columns added since it
+// was taken get their defaults.
+func upsertRows(tx *sql.Tx, table string, rows json.RawMessage) error {
+	columnRows, err := tx.Query(`SELECT column_name FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = $1
+		AND column_name IN (SELECT jsonb_object_keys(r) FROM jsonb_array_elements($2::jsonb) r)
+		ORDER BY ordinal_position`, table, []byte(rows))
+
+	if err != nil {
+		return err
+	}
+	defer columnRows.Close()
+
+	var columns, excluded []string
+
+	for columnRows.Next() {
+		var column string
+
+		if err := columnRows.Scan(&column); err != nil {
+			return err
+		}
+
+		columns = append(columns, pq.QuoteIdentifier(column))
+		excluded = append(excluded, "EXCLUDED."+pq.QuoteIdentifier(column))
+	}
+
+	if err := columnRows.Err(); err != nil {
+		return err
+	}
+
+	if len(columns) == 0 {
+		return nil
+	}
+
+	columnList := strings.Join(columns, ", ")
+
+	query := fmt.Sprintf(
+		"INSERT INTO %s (%s) SELECT %s FROM jsonb_populate_recordset(NULL::%s, $1) ON CONFLICT (id) DO UPDATE SET (%s) = ROW(%s)",
+		table, columnList, columnList, table, columnList, strings.Join(excluded, ", "),
+	)
+
+	_, err = tx.Exec(query, []byte(rows))
+
+	return err
+}
+
+func isJournaledTable(name string) bool {
+	for _, table := range Tables {
+		if table == name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,69 @@
+package backup
+
+import (
+	"testing"
+	"time"
+	"todo-server/models"
+)
+
+func TestChangesToReplay(t *testing.T) {
+	base := time.Date(2024, 8, 1, 3, 0, 0, 0, time.UTC)
+
+	snapshot := models.BackupFile{Kind: KindFull, ToChangeID: 10, CreatedAt: base}
+
+	deltas := []models.BackupFile{
+		{Kind: KindDelta, FromChangeID: 12, ToChangeID: 13, Changes: []models.ChangeLogEntry{
+			{ID: 13, CreatedAt: base.Add(30 * time.Hour)},
+		}},
+		{Kind: KindDelta, FromChangeID: 10, ToChangeID: 12, Changes: []models.ChangeLogEntry{
+			{ID: 11, CreatedAt: base.Add(2 * time.Hour)},
+			{ID: 12, CreatedAt: base.Add(5 * time.Hour)},
+		}},
+		{Kind: KindDelta, FromChangeID: 4, ToChangeID: 10, Changes: []models.ChangeLogEntry{
+			{ID: 5, CreatedAt: base.Add(-time.Hour)},
+		}},
+	}
+
+	tests := []struct {
+		Until time.Time
+		IDs   []int64
+	}{
+		{Until: time.Time{}, IDs: []int64{11, 12, 13}},
+		{Until: base.Add(5 * time.Hour), IDs: []int64{11, 12}},
+		{Until: base.Add(time.Hour), IDs: nil},
+	}
+
+	for _, test := range tests {
+		changes, err := ChangesToReplay(snapshot, deltas, test.Until)
+
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(changes) != len(test.IDs) {
+			t.Fatalf("until %v: expected %d changes, got %d", test.Until, len(test.IDs), len(changes))
+		}
+
+		for i, change := range changes {
+			if change.ID != test.IDs[i] {
+				t.Fatalf("until %v: expected change #%d at %d, got #%d", test.Until, test.IDs[i], i, change.ID)
+			}
+		}
+	}
+}
+
+func TestChangesToReplayMissingDelta(t *testing.T) {
+	snapshot := models.BackupFile{Kind: KindFull, ToChangeID: 10}
+
+	deltas := []models.BackupFile{
+		{Kind: KindDelta, FromChangeID: 12, ToChangeID: 13},
+	}
+
+	if _, err := ChangesToReplay(snapshot, deltas, time.Time{}); err == nil {
+		t.Fatal("expected an error for a gap between the snapshot and the delta")
+	}
+
+	if _, err := ChangesToReplay(deltas[0], nil, time.Time{}); err == nil {
+		t.Fatal("expected an error when the snapshot is not a full backup")
+	}
+}
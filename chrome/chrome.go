@@ -2,40 +2,152 @@ package chrome
 
 import (
 	"context"
+	"errors"
+	"log"
+	"net/url"
 	"os"
+	"sync"
 	"time"
-	"todo-server/utils"
 
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
 	"github.com/chromedp/chromedp"
 )
 
-func GetTitleFromURLUsingChrome(url string) (string, error) {
-	chromePath := os.Getenv("CHROME_PATH")
+var ErrNoChrome = errors.New("CHROME_PATH is not set")
+
+// One browser is started on first use and kept for the life of the server. Every
+// page is loaded in a tab of its own.
+var browser struct {
+	mu     sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
+}
 
-	utils.Assert(chromePath != "", "Chrome Path ENV value is not set")
+// Available tells whether a Chrome binary is configured.
+func Available() bool {
+	return os.Getenv("CHROME_PATH") != ""
+}
+
+// browserContext returns the context of the shared browser, starting it again
+// when it has exited.
+func browserContext() (context.Context, error) {
+	browser.mu.Lock()
+	defer browser.mu.Unlock()
+
+	if browser.ctx != nil && browser.ctx.Err() == nil {
+		return browser.ctx, nil
+	}
+
+	if !Available() {
+		return nil, ErrNoChrome
+	}
 
 	opts := append(chromedp.DefaultExecAllocatorOptions[:],
-		chromedp.ExecPath(chromePath),
+		chromedp.ExecPath(os.Getenv("CHROME_PATH")),
 	)
 
-	allocCtx, cancel := chromedp.NewExecAllocator(context.Background(), opts...)
-	defer cancel()
+	allocCtx, cancelAlloc := chromedp.NewExecAllocator(context.Background(), opts...)
+	ctx, cancelCtx := chromedp.NewContext(allocCtx)
+
+	cancel := func() {
+		cancelCtx()
+		cancelAlloc()
+	}
+
+	// Starts the browser.
+	if err := chromedp.Run(ctx); err != nil {
+		cancel()
 
-	ctx, cancel := chromedp.NewContext(allocCtx)
+		return nil, err
+	}
+
+	browser.ctx, browser.cancel = ctx, cancel
+
+	return ctx, nil
+}
+
+// GetTitle loads the page in a new tab of the shared browser. The tab is closed
+// when the page is done or ctx is cancelled. Unless allowHost is nil, it is asked
+// about the host of every request the tab makes, redirects included, and the
+// requests it fails are blocked.
+func GetTitle(ctx context.Context, pageURL string, allowHost func(context.Context, string) error) (string, error) {
+	browserCtx, err := browserContext()
+
+	if err != nil {
+		return "", err
+	}
+
+	tabCtx, cancel := chromedp.NewContext(browserCtx)
 	defer cancel()
 
-	var pageTitle string
+	// The tab has to follow ctx as well as the browser.
+	stop := context.AfterFunc(ctx, cancel)
+	defer stop()
 
-	err := chromedp.Run(ctx,
-		chromedp.Navigate(url),
+	actions := []chromedp.Action{
+		chromedp.Navigate(pageURL),
 		chromedp.WaitReady("body"),
-		chromedp.Sleep(2*time.Second),
-		chromedp.Evaluate(`document.title`, &pageTitle),
-	)
+	}
+
+	if allowHost != nil {
+		chromedp.ListenTarget(tabCtx, func(ev interface{}) {
+			if paused, ok := ev.(*fetch.EventRequestPaused); ok {
+				// Handlers must not block the event loop.
+				go filterRequest(tabCtx, paused, allowHost)
+			}
+		})
+
+		actions = append([]chromedp.Action{fetch.Enable()}, actions...)
+	}
+
+	var pageTitle string
+
+	err = chromedp.Run(tabCtx, append(actions, chromedp.Evaluate(`document.title`, &pageTitle))...)
+
+	if err != nil && ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+
+	return pageTitle, err
+}
+
+// filterRequest lets the paused request go on when allowHost accepts its host.
+func filterRequest(tabCtx context.Context, paused *fetch.EventRequestPaused, allowHost func(context.Context, string) error) {
+	executor := cdp.WithExecutor(tabCtx, chromedp.FromContext(tabCtx).Target)
+
+	u, err := url.Parse(paused.Request.URL)
+
+	if err == nil {
+		err = allowHost(tabCtx, u.Hostname())
+	}
 
 	if err != nil {
-		return "", err
+		err = fetch.FailRequest(paused.RequestID, network.ErrorReasonBlockedByClient).Do(executor)
+	} else {
+		err = fetch.ContinueRequest(paused.RequestID).Do(executor)
+	}
+
+	if err != nil && tabCtx.Err() == nil {
+		log.Println("Failed to resume request", paused.Request.URL, err)
 	}
+}
+
+func GetTitleFromURLUsingChrome(url string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	return GetTitle(ctx, url, nil)
+}
+
+// Close stops the shared browser, if it was started.
+func Close() {
+	browser.mu.Lock()
+	defer browser.mu.Unlock()
 
-	return pageTitle, nil
+	if browser.cancel != nil {
+		browser.cancel()
+		browser.ctx, browser.cancel = nil, nil
+	}
 }
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"todo-server/backup"
+	"todo-server/internal"
+	"todo-server/models"
+)
+
+// Restores the database from a full snapshot and the delta backups that were
+// emailed after it:
+//
+//	todo-restore -snapshot full-20240801-030000.json -until 2024-08-03T18:00:00+05:30 delta-*.json
+func main() {
+	snapshotPath := flag.String("snapshot", "", "path to the full snapshot backup file")
+	untilStr := flag.String("until", "", "replay changes up to this time (RFC 3339). Defaults to all changes")
+
+	flag.Parse()
+
+	if *snapshotPath == "" {
+		log.Fatal("-snapshot is required")
+	}
+
+	var until time.Time
+
+	if *untilStr != "" {
+		parsed, err := time.Parse(time.RFC3339, *untilStr)
+
+		if err != nil {
+			log.Fatalf("Invalid -until value: %v", err)
+		}
+
+		until = parsed
+	}
+
+	snapshot, err := readBackupFile(*snapshotPath)
+
+	if err != nil {
+		log.Fatalf("Failed to read snapshot: %v", err)
+	}
+
+	var deltas []models.BackupFile
+
+	for _, path := range flag.Args() {
+		delta, err := readBackupFile(path)
+
+		if err != nil {
+			log.Fatalf("Failed to read delta %s: %v", path, err)
+		}
+
+		deltas = append(deltas, delta)
+	}
+
+	internal.LoadDotEnvFile()
+
+	db := internal.SetupDatabase()
+	defer db.Close()
+
+	if err := backup.Restore(db, snapshot, deltas, until); err != nil {
+		log.Fatalf("Restore failed: %v", err)
+	}
+
+	log.Println("Restore successful")
+}
+
+func readBackupFile(path string) (models.BackupFile, error) {
+	var file models.BackupFile
+
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return file, err
+	}
+
+	err = json.Unmarshal(data, &file)
+
+	return file, err
+}
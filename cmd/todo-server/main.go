@@ -13,6 +13,7 @@ import (
 
 	"todo-server/api"
 	"todo-server/internal"
+	"todo-server/internal/inbound"
 )
 
 func main() {
@@ -35,13 +36,22 @@ func main() {
 	c := cors.New(cors.Options{
 		AllowedOrigins:   []string{"http://localhost:1420", "http://127.0.0.1:8080", "*"},
 		AllowedMethods:   []string{"HEAD", "GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Content-Type", "Authorization", "x-api-key"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization", "x-api-key", "If-Match"},
+		ExposedHeaders:   []string{"ETag"},
 		AllowCredentials: true,
 	})
 
 	r.Use(c.Handler)
 
-	api.SetupRoutes(r, db)
+	events := internal.NewBroker()
+
+	go events.ListenForChanges(os.Getenv("POSTGRES_CONNECTION_STRING"))
+
+	api.SetupRoutes(r, db, events)
+
+	go inbound.Setup(db)
+
+	internal.StartBackgroundJobs(db)
 
 	// emailAuth := internal.LoadEmailCredentials()
 
@@ -0,0 +1,122 @@
+package db
+
+import (
+	"database/sql"
+	"todo-server/models"
+)
+
+// InsertAttachment returns sql.ErrNoRows when the task does not exist or is in
+// the trash.
+func InsertAttachment(db Querier, attachment models.Attachment) (int, error) {
+	query := `
+	INSERT INTO attachments (task_id, file_name, content_type, size, storage_key)
+	SELECT id, $2, $3, $4, $5 FROM tasks WHERE id = $1 AND deleted_at IS NULL
+	RETURNING id;
+	`
+
+	var id int
+
+	err := db.QueryRow(query, attachment.TaskID, attachment.FileName, attachment.ContentType, attachment.Size, attachment.StorageKey).Scan(&id)
+
+	return id, err
+}
+
+// GetAttachment returns sql.ErrNoRows when the attachment or its task is gone.
+func GetAttachment(db *sql.DB, id int) (*models.Attachment, error) {
+	query := `
+	SELECT a.id, a.task_id, a.file_name, a.content_type, a.size, a.storage_key, a.created_at
+	FROM attachments a
+	JOIN tasks t ON t.id = a.task_id AND t.deleted_at IS NULL
+	WHERE a.id = $1
+	`
+
+	var attachment models.Attachment
+
+	err := db.QueryRow(query, id).Scan(&attachment.ID, &attachment.TaskID, &attachment.FileName, &attachment.ContentType, &attachment.Size, &attachment.StorageKey, &attachment.CreatedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &attachment, nil
+}
+
+func GetTaskAttachments(db *sql.DB, taskID int) ([]models.Attachment, error) {
+	query := `
+	SELECT a.id, a.task_id, a.file_name, a.content_type, a.size, a.storage_key, a.created_at
+	FROM attachments a
+	JOIN tasks t ON t.id = a.task_id AND t.deleted_at IS NULL
+	WHERE a.task_id = $1
+	ORDER BY a.created_at, a.id
+	`
+
+	rows, err := db.Query(query, taskID)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := []models.Attachment{}
+
+	for rows.Next() {
+		var attachment models.Attachment
+
+		if err := rows.Scan(&attachment.ID, &attachment.TaskID, &attachment.FileName, &attachment.ContentType, &attachment.Size, &attachment.StorageKey, &attachment.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		attachments = append(attachments, attachment)
+	}
+
+	return attachments, rows.Err()
+}
+
+// DeleteAttachment returns the storage key of the removed attachment, or
+// sql.ErrNoRows. The file itself is queued for deletion by a trigger.
+func DeleteAttachment(db *sql.DB, id int) (string, error) {
+	query := `
+	DELETE FROM attachments a
+	USING tasks t
+	WHERE a.id = $1 AND t.id = a.task_id AND t.deleted_at IS NULL
+	RETURNING a.storage_key
+	`
+
+	var key string
+
+	err := db.QueryRow(query, id).Scan(&key)
+
+	return key, err
+}
+
+// GetAttachmentDeletions returns the oldest storage keys whose files still have
+// to be removed.
+func GetAttachmentDeletions(db *sql.DB, limit int) ([]string, error) {
+	rows, err := db.Query("SELECT storage_key FROM attachment_deletions ORDER BY created_at LIMIT $1", limit)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+
+	for rows.Next() {
+		var key string
+
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// FinishAttachmentDeletion is called once the file of the key is gone.
+func FinishAttachmentDeletion(db *sql.DB, key string) error {
+	_, err := db.Exec("DELETE FROM attachment_deletions WHERE storage_key = $1", key)
+
+	return err
+}
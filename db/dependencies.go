@@ -0,0 +1,73 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"todo-server/models"
+)
+
+var ErrDependencyCycle = errors.New("the blocker already depends on this task")
+
+// AddBlocker makes blockerID a blocker of taskID. It fails with ErrDependencyCycle
+// when the blocker is, directly or through other tasks, waiting for taskID.
+func AddBlocker(tx *sql.Tx, taskID int, blockerID int) error {
+	// Two concurrent inserts could each pass the cycle check on their own.
+	if _, err := tx.Exec("LOCK TABLE task_dependencies IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+		return err
+	}
+
+	query := `
+	WITH RECURSIVE chain(id) AS (
+		SELECT blocker_id FROM task_dependencies WHERE task_id = $1
+		UNION
+		SELECT d.blocker_id FROM task_dependencies d JOIN chain c ON d.task_id = c.id
+	)
+	SELECT EXISTS (SELECT 1 FROM chain WHERE id = $2)
+	`
+
+	var cycle bool
+
+	if err := tx.QueryRow(query, blockerID, taskID).Scan(&cycle); err != nil {
+		return err
+	}
+
+	if cycle {
+		return ErrDependencyCycle
+	}
+
+	_, err := tx.Exec("INSERT INTO task_dependencies (task_id, blocker_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", taskID, blockerID)
+
+	return err
+}
+
+// GetBlockers returns the tasks that taskID is waiting for, incomplete ones first.
+func GetBlockers(db *sql.DB, taskID int) ([]models.Task, error) {
+	query := `
+	SELECT t.id, t.name, t.completed, COALESCE(t.completed_on, ''), t.due_date, t.priority, t.list_id, t.profile_id
+	FROM task_dependencies d
+	JOIN tasks t ON t.id = d.blocker_id
+	WHERE d.task_id = $1 AND t.deleted_at IS NULL
+	ORDER BY t.completed ASC, d.created_at ASC
+	`
+
+	rows, err := db.Query(query, taskID)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	blockers := []models.Task{}
+
+	for rows.Next() {
+		var task models.Task
+
+		if err := rows.Scan(&task.ID, &task.Name, &task.Completed, &task.CompletedOn, &task.DueDate, &task.Priority, &task.ListID, &task.ProfileID); err != nil {
+			return nil, err
+		}
+
+		blockers = append(blockers, task)
+	}
+
+	return blockers, rows.Err()
+}
@@ -0,0 +1,172 @@
+package db
+
+import (
+	"database/sql"
+	"todo-server/models"
+)
+
+// shiftDate moves a YYYY-MM-DD column by $2 days and keeps empty dates empty.
+func shiftDate(column string) string {
+	return "CASE WHEN " + column + " = '' THEN '' ELSE TO_CHAR(TO_DATE(" + column + ", 'YYYY-MM-DD') + $2::INT, 'YYYY-MM-DD') END"
+}
+
+// DuplicateTask copies a task with its sub tasks into listID and profileID. The
+// copy is not in My Day and has no blockers or attachments. It returns
+// sql.ErrNoRows when the task does not exist.
+func DuplicateTask(tx *sql.Tx, id int, name string, listID *int, profileID *int, options models.Duplicate, keepPosition bool) (int, error) {
+	query := `
+	INSERT INTO tasks
+		(name, completed, completed_on, is_important, priority, due_date, metadata, start_date, recurrence_pattern, recurrence_interval, list_id, profile_id, position)
+	SELECT
+		COALESCE(NULLIF($3, ''), name),
+		completed AND NOT $4,
+		CASE WHEN $4 THEN '' ELSE completed_on END,
+		is_important,
+		priority,
+		` + shiftDate("due_date") + `,
+		metadata,
+		` + shiftDate("start_date") + `,
+		recurrence_pattern,
+		recurrence_interval,
+		$5,
+		$6,
+		CASE WHEN $7 THEN position ELSE '' END
+	FROM tasks
+	WHERE id = $1 AND deleted_at IS NULL
+	RETURNING id
+	`
+
+	var taskID int
+
+	err := tx.QueryRow(query, id, options.ShiftDueDays, name, options.ResetCompletion, listID, profileID, keepPosition).Scan(&taskID)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return taskID, duplicateSubTasks(tx, id, taskID, options)
+}
+
+func duplicateSubTasks(tx *sql.Tx, from int, to int, options models.Duplicate) error {
+	subTasks, err := GetSubTasksByTaskIDs(tx, []int{from})
+
+	if err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO sub_tasks (name, task_id, completed, parent_id, due_date, is_important)
+	SELECT name, $3, completed AND NOT $4, $5, ` + shiftDate("due_date") + `, is_important
+	FROM sub_tasks
+	WHERE id = $1
+	RETURNING id
+	`
+
+	copies := map[int]int{}
+	pending := subTasks[from]
+
+	// Parents are copied before their children. Sub tasks below a deleted parent
+	// are left behind, as they are not shown either.
+	for len(pending) > 0 {
+		var waiting []models.SubTask
+
+		for _, subTask := range pending {
+			var parentID *int
+
+			if subTask.ParentID != nil {
+				parentCopy, found := copies[*subTask.ParentID]
+
+				if !found {
+					waiting = append(waiting, subTask)
+					continue
+				}
+
+				parentID = &parentCopy
+			}
+
+			var copyID int
+
+			if err := tx.QueryRow(query, subTask.ID, options.ShiftDueDays, to, options.ResetCompletion, parentID).Scan(&copyID); err != nil {
+				return err
+			}
+
+			copies[subTask.ID] = copyID
+		}
+
+		if len(waiting) == len(pending) {
+			break
+		}
+
+		pending = waiting
+	}
+
+	return nil
+}
+
+// DuplicateList copies a list with its tasks, keeping their order. It returns
+// sql.ErrNoRows when the list does not exist.
+func DuplicateList(tx *sql.Tx, id int, options models.Duplicate) (int, error) {
+	var list models.List
+
+	err := tx.QueryRow("SELECT name, profile_id, group_id, auto_complete_parent FROM lists WHERE id = $1 AND deleted_at IS NULL", id).
+		Scan(&list.Name, &list.ProfileID, &list.GroupID, &list.AutoCompleteParent)
+
+	if err != nil {
+		return 0, err
+	}
+
+	list.Name = list.Name + " (copy)"
+
+	if options.Name != "" {
+		list.Name = options.Name
+	}
+
+	if options.ProfileID != nil && !SameID(options.ProfileID, list.ProfileID) {
+		// Groups belong to a profile.
+		list.ProfileID = options.ProfileID
+		list.GroupID = nil
+	}
+
+	listID, err := InsertList(tx, list)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec("UPDATE lists SET auto_complete_parent = $2 WHERE id = $1", listID, list.AutoCompleteParent); err != nil {
+		return 0, err
+	}
+
+	rows, err := tx.Query("SELECT id FROM tasks WHERE list_id = $1 AND deleted_at IS NULL ORDER BY id ASC", id)
+
+	if err != nil {
+		return 0, err
+	}
+
+	var taskIDs []int
+
+	for rows.Next() {
+		var taskID int
+
+		if err := rows.Scan(&taskID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+
+		taskIDs = append(taskIDs, taskID)
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, taskID := range taskIDs {
+		if _, err := DuplicateTask(tx, taskID, "", &listID, list.ProfileID, options, true); err != nil {
+			return 0, err
+		}
+	}
+
+	return listID, nil
+}
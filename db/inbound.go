@@ -0,0 +1,13 @@
+package db
+
+import "database/sql"
+
+// GetInboundAddressProfile returns the profile of the gateway address with the
+// token, or sql.ErrNoRows when there is no such address.
+func GetInboundAddressProfile(db *sql.DB, token string) (*int, error) {
+	var profileID *int
+
+	err := db.QueryRow("SELECT profile_id FROM inbound_addresses WHERE token = $1", token).Scan(&profileID)
+
+	return profileID, err
+}
@@ -0,0 +1,119 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"todo-server/models"
+
+	"github.com/lib/pq"
+)
+
+// AddURLs records the URLs found in tasks and returns the ones that were not
+// known yet, which still have to be fetched.
+func AddURLs(db *sql.DB, urls []string) ([]string, error) {
+	query := `
+	INSERT INTO url_titles (url, title)
+	SELECT DISTINCT unnest($1::TEXT[]), ''
+	ON CONFLICT (url) DO NOTHING
+	RETURNING url
+	`
+
+	rows, err := db.Query(query, pq.Array(urls))
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var added []string
+
+	for rows.Next() {
+		var url string
+
+		if err := rows.Scan(&url); err != nil {
+			return nil, err
+		}
+
+		added = append(added, url)
+	}
+
+	return added, rows.Err()
+}
+
+func SaveLinkPreview(db *sql.DB, preview models.LinkPreview, isValid bool) error {
+	query := `
+	INSERT INTO url_titles (url, title, description, image_url, favicon_url, is_valid)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	ON CONFLICT (url) DO UPDATE SET
+		title = EXCLUDED.title,
+		description = EXCLUDED.description,
+		image_url = EXCLUDED.image_url,
+		favicon_url = EXCLUDED.favicon_url,
+		is_valid = EXCLUDED.is_valid,
+		updated_at = CURRENT_TIMESTAMP
+	`
+
+	_, err := db.Exec(query, preview.URL, preview.Title, preview.Description, preview.ImageURL, preview.FaviconURL, isValid)
+
+	return err
+}
+
+// GetLinkPreviews returns the fetched previews of the URLs by URL. Pending and
+// invalid URLs are left out.
+func GetLinkPreviews(db *sql.DB, urls []string) (map[string]models.LinkPreview, error) {
+	query := `
+	SELECT url, title, COALESCE(description, ''), COALESCE(image_url, ''), COALESCE(favicon_url, '')
+	FROM url_titles
+	WHERE url = ANY($1) AND is_valid AND COALESCE(title, '') != ''
+	`
+
+	rows, err := db.Query(query, pq.Array(urls))
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	previews := map[string]models.LinkPreview{}
+
+	for rows.Next() {
+		var preview models.LinkPreview
+
+		if err := rows.Scan(&preview.URL, &preview.Title, &preview.Description, &preview.ImageURL, &preview.FaviconURL); err != nil {
+			return nil, err
+		}
+
+		previews[preview.URL] = preview
+	}
+
+	return previews, rows.Err()
+}
+
+// GetTaskTexts returns the name and metadata of the tasks whose column (id or
+// list_id) is id.
+func GetTaskTexts(db Querier, column string, id int) ([][]string, error) {
+	if column != "id" && column != "list_id" {
+		return nil, fmt.Errorf("tasks can't be found by %q", column)
+	}
+
+	rows, err := db.Query(fmt.Sprintf("SELECT name, COALESCE(metadata, '') FROM tasks WHERE %s = $1 AND deleted_at IS NULL", column), id)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var texts [][]string
+
+	for rows.Next() {
+		var name, metadata string
+
+		if err := rows.Scan(&name, &metadata); err != nil {
+			return nil, err
+		}
+
+		texts = append(texts, []string{name, metadata})
+	}
+
+	return texts, rows.Err()
+}
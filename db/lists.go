@@ -0,0 +1,132 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"todo-server/models"
+
+	"github.com/lib/pq"
+)
+
+var ErrInvalidGroup = errors.New("the list group does not exist in the profile of the list")
+var ErrInvalidOrder = errors.New("the ids are not distinct rows of a single profile and group")
+
+// positionChecks are the extra conditions on the rows of a reorder. Lists are
+// ordered within their group and lists in the trash keep their position.
+var positionChecks = map[string]struct{ where, having string }{
+	"lists":       {where: "AND deleted_at IS NULL", having: "AND COUNT(DISTINCT COALESCE(group_id, 0)) <= 1"},
+	"list_groups": {},
+}
+
+// SetPositions stores the order of the given rows of table (lists or list_groups)
+// as their position, starting at 0. The rows have to belong to the same profile,
+// and lists to the same group and not be in the trash, or else ErrInvalidOrder
+// is returned.
+func SetPositions(tx *sql.Tx, table string, ids []int) error {
+	checks, ok := positionChecks[table]
+
+	if !ok {
+		return fmt.Errorf("positions are not supported for %q", table)
+	}
+
+	var valid bool
+
+	check := fmt.Sprintf(`
+	SELECT COUNT(*) = cardinality($1::INT[]) AND COUNT(DISTINCT COALESCE(profile_id, 0)) <= 1 %s
+	FROM %s
+	WHERE id = ANY($1::INT[]) %s
+	`, checks.having, table, checks.where)
+
+	if err := tx.QueryRow(check, pq.Array(ids)).Scan(&valid); err != nil {
+		return err
+	}
+
+	if !valid {
+		return ErrInvalidOrder
+	}
+
+	query := fmt.Sprintf(`
+	UPDATE %s t
+	SET position = o.position - 1
+	FROM unnest($1::INT[]) WITH ORDINALITY AS o(id, position)
+	WHERE t.id = o.id
+	`, table)
+
+	_, err := tx.Exec(query, pq.Array(ids))
+
+	return err
+}
+
+// GroupListIDs returns the ids of the lists in a group (or the ungrouped lists when
+// groupID is nil) of a profile in their current order.
+func GroupListIDs(tx *sql.Tx, groupID *int, profileID *int) ([]int, error) {
+	query := `
+	SELECT id FROM lists
+	WHERE group_id IS NOT DISTINCT FROM $1 AND profile_id IS NOT DISTINCT FROM $2 AND deleted_at IS NULL
+	ORDER BY position ASC, id ASC
+	`
+
+	rows, err := tx.Query(query, groupID, profileID)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+
+	for rows.Next() {
+		var id int
+
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// InsertList adds the list at the end of its group. It returns ErrInvalidGroup
+// when the group is not one of the list's profile.
+func InsertList(db Querier, list models.List) (int, error) {
+	query := `
+		INSERT INTO lists (name, profile_id, group_id, position) 
+		SELECT
+			$1,
+			$2,
+			$3,
+			(SELECT COALESCE(MAX(position) + 1, 0) FROM lists WHERE group_id IS NOT DISTINCT FROM $3 AND profile_id IS NOT DISTINCT FROM $2)
+		WHERE $3::INT IS NULL OR EXISTS (SELECT 1 FROM list_groups WHERE id = $3 AND profile_id IS NOT DISTINCT FROM $2)
+		RETURNING id;
+	`
+
+	var listID int
+
+	err := db.QueryRow(query, list.Name, list.ProfileID, list.GroupID).Scan(&listID)
+
+	if err == sql.ErrNoRows {
+		return 0, ErrInvalidGroup
+	}
+
+	return listID, err
+}
+
+// FindListByName looks a list of the profile up by a name as it is typed in a
+// tag: spaces and case do not matter. It returns sql.ErrNoRows when there is none.
+func FindListByName(db Querier, profileID *int, name string) (int, error) {
+	query := `
+	SELECT id FROM lists
+	WHERE deleted_at IS NULL AND profile_id IS NOT DISTINCT FROM $1 AND LOWER(REPLACE(name, ' ', '')) = LOWER(REPLACE($2, ' ', ''))
+	ORDER BY id ASC
+	LIMIT 1
+	`
+
+	var id int
+
+	err := db.QueryRow(query, profileID, name).Scan(&id)
+
+	return id, err
+}
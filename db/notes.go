@@ -0,0 +1,91 @@
+package db
+
+import (
+	"database/sql"
+	"todo-server/models"
+
+	"github.com/lib/pq"
+)
+
+// GetNote returns sql.ErrNoRows when the task does not exist or is in the trash.
+func GetNote(db *sql.DB, taskID int) (*models.Note, error) {
+	query := `
+	SELECT t.id, COALESCE(n.content, ''), COALESCE(n.version, 0), n.updated_at
+	FROM tasks t
+	LEFT JOIN task_notes n ON n.task_id = t.id
+	WHERE t.id = $1 AND t.deleted_at IS NULL
+	`
+
+	var note models.Note
+
+	err := db.QueryRow(query, taskID).Scan(&note.TaskID, &note.Content, &note.Version, &note.UpdatedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &note, nil
+}
+
+// SaveNote stores a new version of the note when the current version is one of
+// versions, or always when versions is nil. It returns sql.ErrNoRows when the
+// note was not saved, because the task is gone or the version did not match.
+func SaveNote(db Querier, taskID int, content string, versions []int64) (*models.Note, error) {
+	query := `
+	WITH saved AS (
+		INSERT INTO task_notes (task_id, content, version)
+		SELECT id, $2, 1 FROM tasks
+		WHERE id = $1 AND deleted_at IS NULL
+			AND ($3::BIGINT[] IS NULL OR 0 = ANY($3) OR EXISTS (SELECT 1 FROM task_notes WHERE task_id = $1))
+		ON CONFLICT (task_id) DO UPDATE
+		SET content = EXCLUDED.content, version = task_notes.version + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE $3::BIGINT[] IS NULL OR task_notes.version = ANY($3)
+		RETURNING task_id, content, version, updated_at
+	), versions AS (
+		INSERT INTO task_note_versions (task_id, version, content, created_at)
+		SELECT task_id, version, content, updated_at FROM saved
+	)
+	SELECT task_id, content, version, updated_at FROM saved
+	`
+
+	var note models.Note
+
+	err := db.QueryRow(query, taskID, content, pq.Array(versions)).Scan(&note.TaskID, &note.Content, &note.Version, &note.UpdatedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &note, nil
+}
+
+// GetNoteVersions returns the saved versions of a note, the latest first.
+func GetNoteVersions(db *sql.DB, taskID int) ([]models.NoteVersion, error) {
+	query := `
+	SELECT version, content, created_at
+	FROM task_note_versions
+	WHERE task_id = $1
+	ORDER BY version DESC
+	`
+
+	rows, err := db.Query(query, taskID)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := []models.NoteVersion{}
+
+	for rows.Next() {
+		var version models.NoteVersion
+
+		if err := rows.Scan(&version.Version, &version.Content, &version.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		versions = append(versions, version)
+	}
+
+	return versions, rows.Err()
+}
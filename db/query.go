@@ -14,7 +14,8 @@ func SaveOrUpdateURLTitle(db *sql.DB, title, url string, isValid bool) error {
     ON CONFLICT (url)
     DO UPDATE SET 
         title = EXCLUDED.title,
-        is_valid = EXCLUDED.is_valid;
+        is_valid = EXCLUDED.is_valid,
+        updated_at = CURRENT_TIMESTAMP;
     `
 
 	err := db.QueryRow(query, title, url, isValid).Err()
@@ -26,7 +27,7 @@ func SaveOrUpdateURLTitle(db *sql.DB, title, url string, isValid bool) error {
 }
 
 func GetURLTitle(db *sql.DB, url string) (*models.URLTitle, error) {
-	query := "SELECT title, is_valid, url FROM url_titles WHERE url = $1"
+	query := "SELECT COALESCE(title, ''), is_valid, url FROM url_titles WHERE url = $1"
 
 	var urlTitle models.URLTitle
 	row := db.QueryRow(query, url)
@@ -46,7 +47,7 @@ func GetURLTitle(db *sql.DB, url string) (*models.URLTitle, error) {
 }
 
 func GetAllURLTitles(db *sql.DB) ([]models.URLTitle, error) {
-	query := "SELECT title, is_valid, url FROM url_titles"
+	query := "SELECT COALESCE(title, ''), is_valid, url FROM url_titles"
 
 	rows, err := db.Query(query)
 
@@ -77,7 +78,70 @@ func GetAllURLTitles(db *sql.DB) ([]models.URLTitle, error) {
 	return urlTitles, nil
 }
 
-func ToggleTaskAndHandleRecurrence(db *sql.DB, taskID int) error {
+// GetURLTitlesToSync returns the URLs that were never fetched and the ones last
+// checked more than maxAgeDays ago, the oldest first.
+func GetURLTitlesToSync(db *sql.DB, maxAgeDays int) ([]models.URLTitle, error) {
+	query := `
+	SELECT COALESCE(title, ''), is_valid, url
+	FROM url_titles
+	WHERE (is_valid AND COALESCE(title, '') = '') OR updated_at < CURRENT_TIMESTAMP - $1 * INTERVAL '1 day'
+	ORDER BY updated_at
+	`
+
+	rows, err := db.Query(query, maxAgeDays)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var urlTitles []models.URLTitle
+
+	for rows.Next() {
+		var urlTitle models.URLTitle
+
+		if err := rows.Scan(&urlTitle.Title, &urlTitle.IsValid, &urlTitle.URL); err != nil {
+			return nil, err
+		}
+
+		urlTitles = append(urlTitles, urlTitle)
+	}
+
+	return urlTitles, rows.Err()
+}
+
+// TouchURLTitle marks the title of the URL as checked without changing it.
+func TouchURLTitle(db *sql.DB, url string) error {
+	_, err := db.Exec("UPDATE url_titles SET updated_at = CURRENT_TIMESTAMP WHERE url = $1", url)
+
+	return err
+}
+
+// Execer is satisfied by both *sql.DB and *sql.Tx.
+type Execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// Querier is satisfied by both *sql.DB and *sql.Tx.
+type Querier interface {
+	Execer
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// SameID reports whether two nullable IDs, like profile_id or group_id, are
+// equal. Two nulls are equal.
+func SameID(a *int, b *int) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	return *a == *b
+}
+
+// ToggleTaskAndHandleRecurrence returns sql.ErrNoRows when the task does not
+// exist or is in the trash.
+func ToggleTaskAndHandleRecurrence(db Querier, taskID int) error {
 	query := `
 	WITH updated_task AS (
     UPDATE tasks 
@@ -86,9 +150,9 @@ func ToggleTaskAndHandleRecurrence(db *sql.DB, taskID int) error {
             WHEN completed = FALSE THEN TO_CHAR(CURRENT_TIMESTAMP, 'YYYY-MM-DD HH24:MI:SS')
             ELSE '' 
         END 
-    WHERE id = $1
+    WHERE id = $1 AND deleted_at IS NULL
     RETURNING id, name, completed, recurrence_pattern, recurrence_interval, start_date, due_date
-)
+), next_task AS (
 
 INSERT INTO tasks (name, start_date, due_date, recurrence_pattern, recurrence_interval)
 SELECT 
@@ -164,14 +228,23 @@ SELECT
 FROM updated_task ut
 WHERE ut.completed = TRUE 
 AND ut.recurrence_pattern IS NOT NULL 
-AND ut.recurrence_interval IS NOT NULL;
+AND ut.recurrence_interval IS NOT NULL
+)
+
+SELECT COUNT(*) FROM updated_task;
 	`
 
-	_, err := db.Exec(query, taskID)
+	var toggled int
+
+	err := db.QueryRow(query, taskID).Scan(&toggled)
 
 	if err != nil {
 		return fmt.Errorf("failed to execute task update and recurrence handling: %v", err)
 	}
 
+	if toggled == 0 {
+		return sql.ErrNoRows
+	}
+
 	return nil
 }
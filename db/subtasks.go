@@ -0,0 +1,183 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+)
+
+var ErrInvalidParent = errors.New("the parent sub task does not belong to the task")
+var ErrTaskHasData = errors.New("the task has dependencies, a note or attachments")
+
+// descendantsQuery selects the ids of every sub task below $1.
+const descendantsQuery = `
+	WITH RECURSIVE tree(id) AS (
+		SELECT id FROM sub_tasks WHERE parent_id = $1
+		UNION ALL
+		SELECT st.id FROM sub_tasks st JOIN tree ON st.parent_id = tree.id
+	)
+	SELECT id FROM tree
+`
+
+// PromoteSubTask turns a sub task into a task in the same list as its task. Its
+// children come along as the sub tasks of the new task. The task is a new row
+// with a new id and the sub task is removed, leaving a tombstone for clients.
+// Nothing else refers to a sub task, so nothing is lost. It returns
+// sql.ErrNoRows when the sub task does not exist.
+func PromoteSubTask(tx *sql.Tx, id int) (int, error) {
+	query := `
+	INSERT INTO tasks (name, completed, completed_on, is_important, priority, due_date, list_id, profile_id)
+	SELECT
+		st.name,
+		st.completed,
+		CASE WHEN st.completed THEN TO_CHAR(CURRENT_TIMESTAMP, 'YYYY-MM-DD HH24:MI:SS') ELSE '' END,
+		st.is_important,
+		CASE WHEN st.is_important THEN 'high' ELSE 'none' END::task_priority_enum,
+		st.due_date,
+		t.list_id,
+		t.profile_id
+	FROM sub_tasks st
+	JOIN tasks t ON t.id = st.task_id
+	WHERE st.id = $1 AND st.deleted_at IS NULL
+	RETURNING id
+	`
+
+	var taskID int
+
+	if err := tx.QueryRow(query, id).Scan(&taskID); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec("UPDATE sub_tasks SET task_id = $2 WHERE id IN ("+descendantsQuery+")", id, taskID); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec("UPDATE sub_tasks SET parent_id = NULL WHERE parent_id = $1", id); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM sub_tasks WHERE id = $1", id); err != nil {
+		return 0, err
+	}
+
+	return taskID, nil
+}
+
+// DemoteTask turns a task into a sub task of taskID, below parentID when it is
+// given. The sub tasks of the demoted task move along under the new sub task,
+// which is a new row with a new id; the task is removed and leaves a tombstone.
+// Sub tasks can't have dependencies, notes or attachments, so a task with any of
+// them is not demoted and ErrTaskHasData is returned. It returns sql.ErrNoRows
+// when either task does not exist.
+func DemoteTask(tx *sql.Tx, id int, taskID int, parentID *int) (int, error) {
+	var exists bool
+
+	if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM tasks WHERE id = $1 AND deleted_at IS NULL)", taskID).Scan(&exists); err != nil {
+		return 0, err
+	}
+
+	if !exists {
+		return 0, sql.ErrNoRows
+	}
+
+	query := `
+	SELECT
+		EXISTS (SELECT 1 FROM task_dependencies WHERE task_id = $1 OR blocker_id = $1) OR
+		EXISTS (SELECT 1 FROM task_notes WHERE task_id = $1) OR
+		EXISTS (SELECT 1 FROM attachments WHERE task_id = $1)
+	FROM tasks
+	WHERE id = $1 AND deleted_at IS NULL
+	FOR UPDATE
+	`
+
+	var hasData bool
+
+	if err := tx.QueryRow(query, id).Scan(&hasData); err != nil {
+		return 0, err
+	}
+
+	if hasData {
+		return 0, ErrTaskHasData
+	}
+
+	if parentID != nil {
+		if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM sub_tasks WHERE id = $1 AND task_id = $2 AND deleted_at IS NULL)", *parentID, taskID).Scan(&exists); err != nil {
+			return 0, err
+		}
+
+		if !exists {
+			return 0, ErrInvalidParent
+		}
+	}
+
+	query = `
+	INSERT INTO sub_tasks (name, task_id, completed, parent_id, due_date, is_important)
+	SELECT name, $2, completed, $3, due_date, is_important
+	FROM tasks
+	WHERE id = $1 AND deleted_at IS NULL
+	RETURNING id
+	`
+
+	var subTaskID int
+
+	if err := tx.QueryRow(query, id, taskID, parentID).Scan(&subTaskID); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec("UPDATE sub_tasks SET parent_id = $2 WHERE task_id = $1 AND parent_id IS NULL", id, subTaskID); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec("UPDATE sub_tasks SET task_id = $2 WHERE task_id = $1", id, taskID); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM tasks WHERE id = $1", id); err != nil {
+		return 0, err
+	}
+
+	return subTaskID, nil
+}
+
+// ToggleSubTask flips a sub task. When the list (or else the profile) of its task
+// has auto_complete_parent on, the task is completed with its last sub task and
+// reopened when one of them is reopened. It returns sql.ErrNoRows when the sub
+// task does not exist.
+func ToggleSubTask(tx *sql.Tx, id int) (completed bool, parentCompleted bool, err error) {
+	var taskID int
+
+	err = tx.QueryRow("UPDATE sub_tasks SET completed = NOT completed WHERE id = $1 AND deleted_at IS NULL RETURNING task_id, completed", id).
+		Scan(&taskID, &completed)
+
+	if err != nil {
+		return false, false, err
+	}
+
+	query := `
+	SELECT
+		t.completed,
+		COALESCE(l.auto_complete_parent, p.auto_complete_parent, false),
+		NOT EXISTS (SELECT 1 FROM sub_tasks st WHERE st.task_id = t.id AND st.completed = false AND st.deleted_at IS NULL)
+	FROM tasks t
+	LEFT JOIN lists l ON l.id = t.list_id
+	LEFT JOIN profiles p ON p.id = t.profile_id
+	WHERE t.id = $1
+	FOR UPDATE OF t
+	`
+
+	var autoComplete, allDone bool
+
+	if err := tx.QueryRow(query, taskID).Scan(&parentCompleted, &autoComplete, &allDone); err != nil {
+		return false, false, err
+	}
+
+	// Completing a sub task never reopens a task that was completed by hand.
+	if autoComplete && ((completed && allDone && !parentCompleted) || (!completed && parentCompleted)) {
+		if err := ToggleTaskAndHandleRecurrence(tx, taskID); err != nil {
+			return false, false, err
+		}
+
+		parentCompleted = !parentCompleted
+	}
+
+	return completed, parentCompleted, nil
+}
@@ -0,0 +1,109 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"todo-server/models"
+)
+
+// Changes returns what was written by transactions from since on, since being
+// the token of the previous sync. Soft deleted rows and tombstones of removed
+// rows are both reported as deleted. Rows that were already seen may be sent
+// again; the token never skips a transaction that had not committed yet.
+func Changes(db *sql.DB, since int64) (*models.SyncResponse, error) {
+	// One snapshot for all tables, so a row can't show up without its parent.
+	tx, err := db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	response := models.SyncResponse{}
+
+	// The first statement takes the snapshot. Every transaction older than its
+	// xmin has finished and is visible in it, the next sync starts from there.
+	if err := tx.QueryRow("SELECT pg_snapshot_xmin(pg_current_snapshot())::text").Scan(&response.Token); err != nil {
+		return nil, err
+	}
+
+	tables := map[string]*models.SyncChanges{
+		"profiles":  &response.Profiles,
+		"lists":     &response.Lists,
+		"tasks":     &response.Tasks,
+		"sub_tasks": &response.SubTasks,
+	}
+
+	for table, changes := range tables {
+		*changes = models.SyncChanges{Created: []json.RawMessage{}, Updated: []json.RawMessage{}, Deleted: []int{}}
+
+		query := fmt.Sprintf(`
+		SELECT id, to_jsonb(x) - 'revision_xid' - 'created_xid', created_xid >= $1::xid8, deleted_at IS NOT NULL
+		FROM %s x
+		WHERE revision_xid >= $1::xid8
+		ORDER BY revision ASC
+		`, table)
+
+		if err := scanChanges(tx, query, since, changes); err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := tx.Query("SELECT table_name, row_id FROM tombstones WHERE xid >= $1::xid8 ORDER BY revision ASC", since)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var table string
+		var id int
+
+		if err := rows.Scan(&table, &id); err != nil {
+			return nil, err
+		}
+
+		if changes, found := tables[table]; found {
+			changes.Deleted = append(changes.Deleted, id)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+func scanChanges(tx *sql.Tx, query string, since int64, changes *models.SyncChanges) error {
+	rows, err := tx.Query(query, since)
+
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var row json.RawMessage
+		var created, deleted bool
+
+		if err := rows.Scan(&id, &row, &created, &deleted); err != nil {
+			return err
+		}
+
+		switch {
+		case deleted:
+			changes.Deleted = append(changes.Deleted, id)
+		case created:
+			changes.Created = append(changes.Created, row)
+		default:
+			changes.Updated = append(changes.Updated, row)
+		}
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,193 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+	"todo-server/models"
+
+	"github.com/lib/pq"
+)
+
+// ScanTasks reads the rows returned by the query built with query.GetTasksQuery.
+func ScanTasks(rows *sql.Rows) ([]models.Task, error) {
+	var tasks []models.Task
+
+	for rows.Next() {
+		var task models.Task
+		if err := rows.Scan(&task.ID, &task.Name, &task.Completed, &task.CompletedOn, &task.CreatedAt, &task.MarkedToday, &task.IsImportant, &task.Priority, &task.DueDate, &task.Metadata, &task.ListID, &task.ProfileID, &task.Position, &task.MyDayPosition, &task.Blocked, &task.RecurrencePattern, &task.InCompleteSubTaskCount, &task.SubTaskCount); err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+
+	return tasks, rows.Err()
+}
+
+// GetSubTasksByTaskIDs returns the sub tasks of the given tasks keyed by task id.
+func GetSubTasksByTaskIDs(db Querier, taskIDs []int) (map[int][]models.SubTask, error) {
+	query := `
+	SELECT id, name, completed, created_at, task_id, parent_id, due_date, is_important
+	FROM sub_tasks
+	WHERE task_id = ANY($1) AND deleted_at IS NULL
+	ORDER BY created_at ASC, id ASC
+	`
+
+	rows, err := db.Query(query, pq.Array(taskIDs))
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subTasks := map[int][]models.SubTask{}
+
+	for rows.Next() {
+		var subTask models.SubTask
+
+		if err := rows.Scan(&subTask.ID, &subTask.Name, &subTask.Completed, &subTask.CreatedAt, &subTask.TaskID, &subTask.ParentID, &subTask.DueDate, &subTask.IsImportant); err != nil {
+			return nil, err
+		}
+
+		subTasks[subTask.TaskID] = append(subTasks[subTask.TaskID], subTask)
+	}
+
+	return subTasks, rows.Err()
+}
+
+// PositionColumn returns the column that holds the manual order of a view.
+func PositionColumn(view string) string {
+	if view == "my-day" {
+		return "my_day_position"
+	}
+
+	return "position"
+}
+
+// TaskOrder returns the tasks shown together with the given task in a view ("list"
+// for the task's list or "my-day") in their current order, along with their positions.
+func TaskOrder(tx *sql.Tx, view string, taskID int) ([]int, []string, error) {
+	column := PositionColumn(view)
+
+	container := "t.list_id IS NOT DISTINCT FROM moved.list_id"
+
+	args := []interface{}{taskID}
+
+	if view == "my-day" {
+		container = "((t.marked_today != '' AND DATE(t.marked_today) = $2) OR (t.due_date != '' AND DATE(t.due_date) = $2))"
+		args = append(args, time.Now().Format("2006-01-02"))
+	}
+
+	query := fmt.Sprintf(`
+	SELECT t.id, t.%s
+	FROM tasks t, tasks moved
+	WHERE moved.id = $1
+		AND t.profile_id IS NOT DISTINCT FROM moved.profile_id
+		AND t.deleted_at IS NULL
+		AND %s
+	ORDER BY NULLIF(t.%s, '') COLLATE "C" ASC NULLS FIRST, t.created_at DESC
+	`, column, container, column)
+
+	rows, err := tx.Query(query, args...)
+
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	var positions []string
+
+	for rows.Next() {
+		var id int
+		var position string
+
+		if err := rows.Scan(&id, &position); err != nil {
+			return nil, nil, err
+		}
+
+		ids = append(ids, id)
+		positions = append(positions, position)
+	}
+
+	return ids, positions, rows.Err()
+}
+
+func SetTaskPositions(tx *sql.Tx, view string, ids []int, positions []string) error {
+	query := fmt.Sprintf(`
+	UPDATE tasks t
+	SET %s = o.position
+	FROM unnest($1::INT[], $2::TEXT[]) AS o(id, position)
+	WHERE t.id = o.id
+	`, PositionColumn(view))
+
+	_, err := tx.Exec(query, pq.Array(ids), pq.Array(positions))
+
+	return err
+}
+
+func InsertTask(db Querier, task models.Task) (int, error) {
+	// is_important is what older clients send. A priority wins when both are given.
+	if task.Priority == "" {
+		task.Priority = "none"
+
+		if task.IsImportant {
+			task.Priority = "high"
+		}
+	}
+
+	task.IsImportant = task.Priority == "high" || task.Priority == "urgent"
+
+	query := `
+	INSERT INTO tasks 
+		(name, completed, completed_on, marked_today, is_important, priority, due_date, metadata, list_id, profile_id, start_date, recurrence_pattern, recurrence_interval)
+	VALUES 
+		($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NULLIF($12, '')::recurrence_pattern_enum, NULLIF($13, 0))
+	RETURNING id;
+`
+
+	// A recurring task starts on its due date.
+	if task.RecurrencePattern != "" && task.StartDate == "" {
+		task.StartDate = task.DueDate
+	}
+
+	var taskID int
+
+	err := db.QueryRow(
+		query,
+		task.Name,
+		task.Completed,
+		task.CompletedOn,
+		task.MarkedToday,
+		task.IsImportant,
+		task.Priority,
+		task.DueDate,
+		task.Metadata,
+		task.ListID,
+		task.ProfileID,
+		task.StartDate,
+		task.RecurrencePattern,
+		task.RecurrenceInterval,
+	).Scan(&taskID)
+
+	return taskID, err
+}
+
+// InsertSubTask returns sql.ErrNoRows when the task does not exist or is in the
+// trash, or when the parent is not a sub task of the same task. A nested sub task
+// keeps the task_id of the top level task.
+func InsertSubTask(db Querier, subTask models.SubTask) (int, error) {
+	query := `
+	INSERT INTO sub_tasks (name, task_id, completed, parent_id, due_date, is_important)
+	SELECT $1, $2, $3, $4, $5, $6
+	WHERE EXISTS (SELECT 1 FROM tasks WHERE id = $2 AND deleted_at IS NULL)
+		AND ($4::INT IS NULL OR EXISTS (SELECT 1 FROM sub_tasks WHERE id = $4 AND task_id = $2 AND deleted_at IS NULL))
+	RETURNING id;
+`
+
+	var subTaskID int
+
+	err := db.QueryRow(query, subTask.Name, subTask.TaskID, subTask.Completed, subTask.ParentID, subTask.DueDate, subTask.IsImportant).Scan(&subTaskID)
+
+	return subTaskID, err
+}
@@ -0,0 +1,157 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+	"todo-server/models"
+)
+
+const templateColumns = "id, name, task_name, metadata, priority, list_id, profile_id, due_offset_days, sub_tasks, created_at"
+
+func scanTemplate(row interface{ Scan(...any) error }) (models.TaskTemplate, error) {
+	var template models.TaskTemplate
+	var subTasks []byte
+
+	err := row.Scan(&template.ID, &template.Name, &template.TaskName, &template.Metadata, &template.Priority,
+		&template.ListID, &template.ProfileID, &template.DueOffsetDays, &subTasks, &template.CreatedAt)
+
+	if err != nil {
+		return template, err
+	}
+
+	err = json.Unmarshal(subTasks, &template.SubTasks)
+
+	return template, err
+}
+
+// GetTemplate returns sql.ErrNoRows when the template does not exist.
+func GetTemplate(db Querier, id int) (*models.TaskTemplate, error) {
+	template, err := scanTemplate(db.QueryRow("SELECT "+templateColumns+" FROM task_templates WHERE id = $1", id))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &template, nil
+}
+
+func GetTemplates(db *sql.DB, profileID *int) ([]models.TaskTemplate, error) {
+	rows, err := db.Query("SELECT "+templateColumns+" FROM task_templates WHERE $1::INT IS NULL OR profile_id = $1 ORDER BY name ASC", profileID)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := []models.TaskTemplate{}
+
+	for rows.Next() {
+		template, err := scanTemplate(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		templates = append(templates, template)
+	}
+
+	return templates, rows.Err()
+}
+
+// SaveTemplate inserts the template when it has no ID and replaces it otherwise.
+// It returns sql.ErrNoRows when the template to replace does not exist.
+func SaveTemplate(db Querier, template models.TaskTemplate) (int, error) {
+	if template.Priority == "" {
+		template.Priority = "none"
+	}
+
+	if template.SubTasks == nil {
+		template.SubTasks = []models.TemplateSubTask{}
+	}
+
+	subTasks, err := json.Marshal(template.SubTasks)
+
+	if err != nil {
+		return 0, err
+	}
+
+	query := `
+	INSERT INTO task_templates (name, task_name, metadata, priority, list_id, profile_id, due_offset_days, sub_tasks)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	RETURNING id;
+	`
+
+	args := []interface{}{template.Name, template.TaskName, template.Metadata, template.Priority, template.ListID, template.ProfileID, template.DueOffsetDays, subTasks}
+
+	if template.ID != 0 {
+		query = `
+		UPDATE task_templates
+		SET name = $1, task_name = $2, metadata = $3, priority = $4, list_id = $5, profile_id = $6, due_offset_days = $7, sub_tasks = $8
+		WHERE id = $9
+		RETURNING id;
+		`
+
+		args = append(args, template.ID)
+	}
+
+	var id int
+
+	err = db.QueryRow(query, args...).Scan(&id)
+
+	return id, err
+}
+
+func dueDate(start time.Time, offset *int) string {
+	if offset == nil {
+		return ""
+	}
+
+	return start.AddDate(0, 0, *offset).Format("2006-01-02")
+}
+
+// InstantiateTemplate creates the task of the template with all of its sub tasks.
+func InstantiateTemplate(tx *sql.Tx, template models.TaskTemplate, start time.Time) (int, error) {
+	taskID, err := InsertTask(tx, models.Task{
+		Name:      template.TaskName,
+		Metadata:  template.Metadata,
+		Priority:  template.Priority,
+		DueDate:   dueDate(start, template.DueOffsetDays),
+		ListID:    template.ListID,
+		ProfileID: template.ProfileID,
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	var insert func(subTasks []models.TemplateSubTask, parentID *int) error
+
+	insert = func(subTasks []models.TemplateSubTask, parentID *int) error {
+		for _, subTask := range subTasks {
+			id, err := InsertSubTask(tx, models.SubTask{
+				Name:        subTask.Name,
+				TaskID:      taskID,
+				ParentID:    parentID,
+				IsImportant: subTask.IsImportant,
+				DueDate:     dueDate(start, subTask.DueOffsetDays),
+			})
+
+			if err != nil {
+				return err
+			}
+
+			if err := insert(subTask.SubTasks, &id); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if err := insert(template.SubTasks, nil); err != nil {
+		return 0, err
+	}
+
+	return taskID, nil
+}
@@ -0,0 +1,253 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Deleting a list or a profile moves its tasks (and a profile's lists) to the
+// trash with the same deleted_at. Restoring it brings back exactly the rows that
+// went with it, not the ones that were deleted on their own before.
+
+// SoftDeleteTask moves a task to the trash. Its sub tasks stay attached to it.
+func SoftDeleteTask(tx *sql.Tx, id int) (bool, error) {
+	return softDelete(tx, "tasks", id)
+}
+
+// SoftDeleteSubTask moves a sub task and the sub tasks below it to the trash.
+func SoftDeleteSubTask(tx *sql.Tx, id int) (bool, error) {
+	ok, err := softDelete(tx, "sub_tasks", id)
+
+	if err != nil || !ok {
+		return ok, err
+	}
+
+	_, err = tx.Exec("UPDATE sub_tasks SET deleted_at = NOW() WHERE deleted_at IS NULL AND id IN ("+descendantsQuery+")", id)
+
+	return true, err
+}
+
+// SoftDeleteList moves a list and its tasks to the trash. The tasks keep their
+// list_id, so restoring them puts them back into the list.
+func SoftDeleteList(tx *sql.Tx, id int) (bool, error) {
+	ok, err := softDelete(tx, "lists", id)
+
+	if err != nil || !ok {
+		return ok, err
+	}
+
+	_, err = tx.Exec("UPDATE tasks SET deleted_at = NOW() WHERE list_id = $1 AND deleted_at IS NULL", id)
+
+	return true, err
+}
+
+// MoveListTasks moves the tasks of a list that are not in the trash to another
+// list and returns how many were moved.
+func MoveListTasks(tx *sql.Tx, from int, to int) (int64, error) {
+	result, err := tx.Exec("UPDATE tasks SET list_id = $2 WHERE list_id = $1 AND deleted_at IS NULL", from, to)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// SoftDeleteProfile moves a profile with all of its lists and tasks to the trash.
+func SoftDeleteProfile(tx *sql.Tx, id int) (bool, error) {
+	ok, err := softDelete(tx, "profiles", id)
+
+	if err != nil || !ok {
+		return ok, err
+	}
+
+	for _, table := range []string{"lists", "tasks"} {
+		query := fmt.Sprintf("UPDATE %s SET deleted_at = NOW() WHERE profile_id = $1 AND deleted_at IS NULL", table)
+
+		if _, err := tx.Exec(query, id); err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// NOW() is the start of the transaction, so everything deleted in one call shares
+// the same deleted_at.
+func softDelete(tx *sql.Tx, table string, id int) (bool, error) {
+	query := fmt.Sprintf("UPDATE %s SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL", table)
+
+	result, err := tx.Exec(query, id)
+
+	if err != nil {
+		return false, err
+	}
+
+	rf, _ := result.RowsAffected()
+
+	return rf == 1, nil
+}
+
+// RestoreTask takes a task out of the trash. If its list or profile were deleted
+// too they are restored as well, otherwise the task would stay hidden.
+func RestoreTask(tx *sql.Tx, id int) (bool, error) {
+	var listID, profileID *int
+
+	err := tx.QueryRow("UPDATE tasks SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL RETURNING list_id, profile_id", id).
+		Scan(&listID, &profileID)
+
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, restoreParents(tx, listID, profileID)
+}
+
+// RestoreSubTask takes a sub task and the sub tasks deleted along with it out of
+// the trash. Its parents are restored too, up to the task.
+func RestoreSubTask(tx *sql.Tx, id int) (bool, error) {
+	deletedAt, err := deletedAt(tx, "sub_tasks", id)
+
+	if err != nil || deletedAt == nil {
+		return false, err
+	}
+
+	if _, err := tx.Exec("UPDATE sub_tasks SET deleted_at = NULL WHERE deleted_at = $2 AND id IN ("+descendantsQuery+")", id, deletedAt); err != nil {
+		return false, err
+	}
+
+	ancestors := `
+	WITH RECURSIVE chain(id, parent_id) AS (
+		SELECT id, parent_id FROM sub_tasks WHERE id = $1
+		UNION ALL
+		SELECT st.id, st.parent_id FROM sub_tasks st JOIN chain c ON st.id = c.parent_id
+	)
+	UPDATE sub_tasks SET deleted_at = NULL
+	WHERE id IN (SELECT id FROM chain) AND deleted_at IS NOT NULL
+	`
+
+	if _, err := tx.Exec(ancestors, id); err != nil {
+		return false, err
+	}
+
+	var taskID int
+
+	if err := tx.QueryRow("SELECT task_id FROM sub_tasks WHERE id = $1", id).Scan(&taskID); err != nil {
+		return false, err
+	}
+
+	if _, err := RestoreTask(tx, taskID); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// RestoreList takes a list and the tasks deleted along with it out of the trash.
+func RestoreList(tx *sql.Tx, id int) (bool, error) {
+	deletedAt, err := deletedAt(tx, "lists", id)
+
+	if err != nil || deletedAt == nil {
+		return false, err
+	}
+
+	var profileID *int
+
+	if err := tx.QueryRow("UPDATE lists SET deleted_at = NULL WHERE id = $1 RETURNING profile_id", id).Scan(&profileID); err != nil {
+		return false, err
+	}
+
+	if _, err := tx.Exec("UPDATE tasks SET deleted_at = NULL WHERE list_id = $1 AND deleted_at = $2", id, deletedAt); err != nil {
+		return false, err
+	}
+
+	return true, restoreParents(tx, nil, profileID)
+}
+
+// RestoreProfile takes a profile and the lists and tasks deleted along with it
+// out of the trash.
+func RestoreProfile(tx *sql.Tx, id int) (bool, error) {
+	deletedAt, err := deletedAt(tx, "profiles", id)
+
+	if err != nil || deletedAt == nil {
+		return false, err
+	}
+
+	if _, err := tx.Exec("UPDATE profiles SET deleted_at = NULL WHERE id = $1", id); err != nil {
+		return false, err
+	}
+
+	for _, table := range []string{"lists", "tasks"} {
+		query := fmt.Sprintf("UPDATE %s SET deleted_at = NULL WHERE profile_id = $1 AND deleted_at = $2", table)
+
+		if _, err := tx.Exec(query, id, deletedAt); err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+func deletedAt(tx *sql.Tx, table string, id int) (*time.Time, error) {
+	var deletedAt *time.Time
+
+	query := fmt.Sprintf("SELECT deleted_at FROM %s WHERE id = $1 FOR UPDATE", table)
+
+	err := tx.QueryRow(query, id).Scan(&deletedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return deletedAt, err
+}
+
+func restoreParents(tx *sql.Tx, listID *int, profileID *int) error {
+	if listID != nil {
+		if _, err := tx.Exec("UPDATE lists SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL", *listID); err != nil {
+			return err
+		}
+	}
+
+	if profileID != nil {
+		if _, err := tx.Exec("UPDATE profiles SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL", *profileID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PurgeTrash permanently removes everything that has been in the trash since
+// before the given time and returns the number of removed rows.
+func PurgeTrash(db *sql.DB, before time.Time) (int64, error) {
+	tx, err := db.Begin()
+
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var total int64
+
+	// Children first, so nothing purged is still referenced by a row that stays.
+	for _, table := range []string{"sub_tasks", "tasks", "lists", "profiles"} {
+		query := fmt.Sprintf("DELETE FROM %s WHERE deleted_at < $1", table)
+
+		result, err := tx.Exec(query, before)
+
+		if err != nil {
+			return 0, err
+		}
+
+		rf, _ := result.RowsAffected()
+		total += rf
+	}
+
+	return total, tx.Commit()
+}
@@ -0,0 +1,105 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// WebhookJob is a claimed delivery together with where it goes.
+type WebhookJob struct {
+	ID       int
+	Event    string
+	Payload  json.RawMessage
+	Attempts int
+	URL      string
+	Secret   string
+}
+
+// ClaimWebhookDeliveries takes up to limit deliveries that are due and counts the
+// attempt. Claimed deliveries are held back for a few minutes, so other server
+// instances (or an overlapping run) leave them alone while they are being sent.
+func ClaimWebhookDeliveries(db *sql.DB, limit int) ([]WebhookJob, error) {
+	query := `
+	UPDATE webhook_deliveries d
+	SET next_attempt_at = CURRENT_TIMESTAMP + INTERVAL '5 minutes', attempts = d.attempts + 1
+	FROM webhooks w
+	WHERE w.id = d.webhook_id AND d.id IN (
+		SELECT dd.id
+		FROM webhook_deliveries dd
+		JOIN webhooks ww ON ww.id = dd.webhook_id AND ww.active
+		WHERE dd.status = 'pending' AND dd.next_attempt_at <= CURRENT_TIMESTAMP
+		ORDER BY dd.next_attempt_at ASC
+		LIMIT $1
+		FOR UPDATE OF dd SKIP LOCKED
+	)
+	RETURNING d.id, d.event, d.payload, d.attempts, w.url, w.secret
+	`
+
+	rows, err := db.Query(query, limit)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []WebhookJob
+
+	for rows.Next() {
+		var job WebhookJob
+
+		if err := rows.Scan(&job.ID, &job.Event, &job.Payload, &job.Attempts, &job.URL, &job.Secret); err != nil {
+			return nil, err
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	return jobs, rows.Err()
+}
+
+// FinishWebhookDelivery records an attempt. A failed attempt is retried after
+// retryIn, or given up when retryIn is 0.
+func FinishWebhookDelivery(db *sql.DB, id int, responseStatus int, deliveryErr error, retryIn time.Duration) error {
+	var status *int
+
+	if responseStatus != 0 {
+		status = &responseStatus
+	}
+
+	if deliveryErr == nil {
+		_, err := db.Exec("UPDATE webhook_deliveries SET status = 'delivered', response_status = $2, error = '', delivered_at = CURRENT_TIMESTAMP WHERE id = $1", id, status)
+
+		return err
+	}
+
+	if retryIn == 0 {
+		_, err := db.Exec("UPDATE webhook_deliveries SET status = 'failed', response_status = $2, error = $3 WHERE id = $1", id, status, deliveryErr.Error())
+
+		return err
+	}
+
+	_, err := db.Exec("UPDATE webhook_deliveries SET response_status = $2, error = $3, next_attempt_at = CURRENT_TIMESTAMP + $4 * INTERVAL '1 second' WHERE id = $1", id, status, deliveryErr.Error(), retryIn.Seconds())
+
+	return err
+}
+
+// EnqueueOverdueWebhooks queues task.overdue for the open tasks that were due
+// yesterday, so each task is announced once when it becomes overdue.
+func EnqueueOverdueWebhooks(db *sql.DB) (int64, error) {
+	query := `
+	INSERT INTO webhook_deliveries (webhook_id, event, payload)
+	SELECT w.id, 'task.overdue', to_jsonb(t)
+	FROM tasks t
+	JOIN webhooks w ON w.active AND 'task.overdue' = ANY(w.events) AND (w.all_profiles OR w.profile_id IS NOT DISTINCT FROM t.profile_id)
+	WHERE t.due_date = TO_CHAR(CURRENT_DATE - 1, 'YYYY-MM-DD') AND t.completed = false AND t.deleted_at IS NULL
+	`
+
+	result, err := db.Exec(query)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,210 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"todo-server/models"
+)
+
+const (
+	FormatCSV      = "csv"
+	FormatJSON     = "json"
+	FormatMarkdown = "md"
+	FormatICS      = "ics"
+)
+
+// Writer streams tasks in one of the export formats. Begin is called once before
+// the first task and End once after the last one.
+type Writer interface {
+	Begin(title string) error
+	WriteTask(task models.Task) error
+	End() error
+	ContentType() string
+	Extension() string
+}
+
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	case FormatJSON, "":
+		return &jsonWriter{w: w}, nil
+	case FormatMarkdown:
+		return &markdownWriter{w: w}, nil
+	case FormatICS:
+		return NewICSWriter(w, ComponentTodo, nil), nil
+	}
+
+	return nil, fmt.Errorf("unsupported export format %q", format)
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvWriter) Begin(title string) error {
+	return c.w.Write([]string{"type", "id", "task_id", "name", "completed", "completed_on", "created_at", "due_date", "is_important", "marked_today", "metadata", "recurrence_pattern", "list_id"})
+}
+
+func (c *csvWriter) WriteTask(task models.Task) error {
+	listID := ""
+
+	if task.ListID != nil {
+		listID = strconv.Itoa(*task.ListID)
+	}
+
+	err := c.w.Write([]string{
+		"task",
+		strconv.Itoa(task.ID),
+		"",
+		task.Name,
+		strconv.FormatBool(task.Completed),
+		task.CompletedOn,
+		task.CreatedAt,
+		task.DueDate,
+		strconv.FormatBool(task.IsImportant),
+		task.MarkedToday,
+		task.Metadata,
+		task.RecurrencePattern,
+		listID,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	for _, subTask := range task.SubTasks {
+		err := c.w.Write([]string{
+			"sub_task",
+			strconv.Itoa(subTask.ID),
+			strconv.Itoa(task.ID),
+			subTask.Name,
+			strconv.FormatBool(subTask.Completed),
+			"",
+			subTask.CreatedAt.Format("2006-01-02 15:04:05"),
+			"", "", "", "", "", "",
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	c.w.Flush()
+
+	return c.w.Error()
+}
+
+func (c *csvWriter) End() error {
+	c.w.Flush()
+
+	return c.w.Error()
+}
+
+func (c *csvWriter) ContentType() string { return "text/csv; charset=utf-8" }
+func (c *csvWriter) Extension() string   { return "csv" }
+
+// jsonWriter writes a JSON array one task at a time instead of encoding the
+// whole slice at the end.
+type jsonWriter struct {
+	w     io.Writer
+	count int
+}
+
+func (j *jsonWriter) Begin(title string) error {
+	_, err := io.WriteString(j.w, "[")
+
+	return err
+}
+
+func (j *jsonWriter) WriteTask(task models.Task) error {
+	if j.count > 0 {
+		if _, err := io.WriteString(j.w, ","); err != nil {
+			return err
+		}
+	}
+
+	j.count++
+
+	data, err := json.Marshal(task)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = j.w.Write(data)
+
+	return err
+}
+
+func (j *jsonWriter) End() error {
+	_, err := io.WriteString(j.w, "]\n")
+
+	return err
+}
+
+func (j *jsonWriter) ContentType() string { return "application/json" }
+func (j *jsonWriter) Extension() string   { return "json" }
+
+type markdownWriter struct {
+	w io.Writer
+}
+
+func (m *markdownWriter) Begin(title string) error {
+	_, err := fmt.Fprintf(m.w, "# %s\n\n", title)
+
+	return err
+}
+
+func (m *markdownWriter) WriteTask(task models.Task) error {
+	line := fmt.Sprintf("- [%s] %s", checkbox(task.Completed), markdownEscape(task.Name))
+
+	if task.IsImportant {
+		line += " ⭐"
+	}
+
+	if task.DueDate != "" {
+		line += fmt.Sprintf(" (due %s)", task.DueDate)
+	}
+
+	if _, err := fmt.Fprintln(m.w, line); err != nil {
+		return err
+	}
+
+	if task.Metadata != "" {
+		if _, err := fmt.Fprintf(m.w, "  > %s\n", markdownEscape(task.Metadata)); err != nil {
+			return err
+		}
+	}
+
+	for _, subTask := range task.SubTasks {
+		if _, err := fmt.Fprintf(m.w, "  - [%s] %s\n", checkbox(subTask.Completed), markdownEscape(subTask.Name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *markdownWriter) End() error { return nil }
+
+func (m *markdownWriter) ContentType() string { return "text/markdown; charset=utf-8" }
+func (m *markdownWriter) Extension() string   { return "md" }
+
+func checkbox(completed bool) string {
+	if completed {
+		return "x"
+	}
+
+	return " "
+}
+
+func markdownEscape(s string) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+
+	return strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`, "*", `\*`, "_", `\_`).Replace(s)
+}
@@ -0,0 +1,132 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"todo-server/models"
+)
+
+func exportTasks(t *testing.T, format string, tasks []models.Task) string {
+	var buf bytes.Buffer
+
+	writer, err := NewWriter(format, &buf)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writer.Begin("Home"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, task := range tasks {
+		if err := writer.WriteTask(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := writer.End(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.String()
+}
+
+var tasks = []models.Task{
+	{ID: 1, Name: "Pay rent", DueDate: "2024-08-01", IsImportant: true, SubTasks: []models.SubTask{
+		{ID: 10, Name: "Transfer money", Completed: true},
+	}},
+	{ID: 2, Name: "Read [book]", Completed: true},
+}
+
+func TestMarkdownExport(t *testing.T) {
+	expected := "# Home\n\n" +
+		"- [ ] Pay rent ⭐ (due 2024-08-01)\n" +
+		"  - [x] Transfer money\n" +
+		"- [x] Read \\[book\\]\n"
+
+	if result := exportTasks(t, FormatMarkdown, tasks); result != expected {
+		t.Fatalf("expected %q, got %q", expected, result)
+	}
+}
+
+func TestICSExportSkipsUndatedTasks(t *testing.T) {
+	result := exportTasks(t, FormatICS, tasks)
+
+	if strings.Count(result, "BEGIN:VTODO") != 1 {
+		t.Fatalf("expected exactly one VTODO, got %q", result)
+	}
+
+	for _, line := range []string{"UID:task-1@todo-server", "DUE;VALUE=DATE:20240801", "PRIORITY:1", "DESCRIPTION:[x] Transfer money"} {
+		if !strings.Contains(result, line+"\r\n") {
+			t.Fatalf("expected %q in %q", line, result)
+		}
+	}
+}
+
+func TestFoldLine(t *testing.T) {
+	line := "SUMMARY:" + strings.Repeat("é", 60)
+
+	for _, part := range strings.Split(foldLine(line), "\r\n") {
+		if len(part) > 75 {
+			t.Fatalf("folded line is %d octets long", len(part))
+		}
+	}
+}
+
+func TestUnsupportedFormat(t *testing.T) {
+	if _, err := NewWriter("pdf", &bytes.Buffer{}); err == nil {
+		t.Fatal("expected an error for an unsupported format")
+	}
+}
+
+func TestICSRecurrenceRule(t *testing.T) {
+	var buf bytes.Buffer
+
+	writer := NewICSWriter(&buf, ComponentEvent, nil)
+
+	writer.Begin("Home")
+	writer.WriteTask(models.Task{ID: 3, Name: "Water plants", DueDate: "2024-08-02", RecurrencePattern: "weekly", RecurrenceInterval: 2})
+	writer.WriteTask(models.Task{ID: 4, Name: "Old plants", DueDate: "2024-07-19", RecurrencePattern: "weekly", Completed: true})
+	writer.End()
+
+	result := buf.String()
+
+	for _, line := range []string{"BEGIN:VEVENT", "DTSTART;VALUE=DATE:20240802", "DTEND;VALUE=DATE:20240803", "RRULE:FREQ=WEEKLY;INTERVAL=2"} {
+		if !strings.Contains(result, line+"\r\n") {
+			t.Fatalf("expected %q in %q", line, result)
+		}
+	}
+
+	if strings.Count(result, "RRULE:") != 1 {
+		t.Fatalf("expected only the open occurrence to recur, got %q", result)
+	}
+}
+
+func TestParseTodoStatus(t *testing.T) {
+	data := []byte("BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nUID:task-1@todo-server\r\nSTATUS:COMPL\r\n ETED\r\nEND:VTODO\r\nEND:VCALENDAR\r\n")
+
+	status, err := ParseTodoStatus(data)
+
+	if err != nil || status != "COMPLETED" {
+		t.Fatalf("expected COMPLETED, got %q (%v)", status, err)
+	}
+}
+
+func TestICSTaskURL(t *testing.T) {
+	var buf bytes.Buffer
+
+	writer := NewICSWriter(&buf, ComponentTodo, func(id int) string {
+		return fmt.Sprintf("https://todo.example/calendar/secret/task-%d.ics", id)
+	})
+
+	writer.Begin("Home")
+	writer.WriteTask(tasks[0])
+	writer.End()
+
+	if result := buf.String(); !strings.Contains(result, "URL:https://todo.example/calendar/secret/task-1.ics\r\n") {
+		t.Fatalf("expected the URL of the task in %q", result)
+	}
+}
@@ -0,0 +1,201 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"todo-server/models"
+)
+
+const (
+	ComponentTodo  = "VTODO"
+	ComponentEvent = "VEVENT"
+)
+
+// icsWriter writes tasks with a due date as VTODO or all day VEVENT components
+// (RFC 5545). Tasks without a due date are skipped since calendars can't place them.
+type icsWriter struct {
+	w         io.Writer
+	component string
+	taskURL   func(id int) string
+	err       error
+}
+
+// NewICSWriter is used by the calendar feeds. Most calendar apps only show
+// events, so those feeds can ask for VEVENT instead of VTODO. When taskURL is
+// given every VTODO gets its URL, where clients PUT it back to tick it off.
+func NewICSWriter(w io.Writer, component string, taskURL func(id int) string) Writer {
+	if component != ComponentEvent {
+		component = ComponentTodo
+	}
+
+	return &icsWriter{w: w, component: component, taskURL: taskURL}
+}
+
+func (c *icsWriter) Begin(title string) error {
+	c.line("BEGIN:VCALENDAR")
+	c.line("VERSION:2.0")
+	c.line("PRODID:-//MKTodo//todo-server//EN")
+	c.line("CALSCALE:GREGORIAN")
+	c.line("X-WR-CALNAME:" + escapeText(title))
+
+	return c.err
+}
+
+func (c *icsWriter) WriteTask(task models.Task) error {
+	due, err := time.Parse("2006-01-02", task.DueDate)
+
+	if err != nil {
+		return nil
+	}
+
+	c.line("BEGIN:" + c.component)
+	c.line(fmt.Sprintf("UID:task-%d@todo-server", task.ID))
+	c.line("DTSTAMP:" + time.Now().UTC().Format("20060102T150405Z"))
+
+	if c.component == ComponentEvent {
+		summary := task.Name
+
+		if task.Completed {
+			summary = "✓ " + summary
+		}
+
+		c.line("SUMMARY:" + escapeText(summary))
+		c.line("DTSTART;VALUE=DATE:" + due.Format("20060102"))
+		c.line("DTEND;VALUE=DATE:" + due.AddDate(0, 0, 1).Format("20060102"))
+		c.line("TRANSP:TRANSPARENT")
+	} else {
+		c.line("SUMMARY:" + escapeText(task.Name))
+		c.line("DUE;VALUE=DATE:" + due.Format("20060102"))
+
+		if task.Completed {
+			c.line("STATUS:COMPLETED")
+
+			if completedOn, err := time.Parse("2006-01-02 15:04:05", task.CompletedOn); err == nil {
+				c.line("COMPLETED:" + completedOn.UTC().Format("20060102T150405Z"))
+			}
+		} else {
+			c.line("STATUS:NEEDS-ACTION")
+		}
+
+		if c.taskURL != nil {
+			c.line("URL:" + c.taskURL(task.ID))
+		}
+	}
+
+	// Completing a recurring task creates the next occurrence as a new task, so only
+	// the open occurrence carries the rule. Otherwise the calendar shows it twice.
+	if rrule := recurrenceRule(task); rrule != "" && !task.Completed {
+		c.line("RRULE:" + rrule)
+	}
+
+	if task.IsImportant {
+		c.line("PRIORITY:1")
+	}
+
+	if description := taskDescription(task); description != "" {
+		c.line("DESCRIPTION:" + escapeText(description))
+	}
+
+	c.line("END:" + c.component)
+
+	return c.err
+}
+
+func (c *icsWriter) End() error {
+	c.line("END:VCALENDAR")
+
+	return c.err
+}
+
+func (c *icsWriter) ContentType() string { return "text/calendar; charset=utf-8" }
+func (c *icsWriter) Extension() string   { return "ics" }
+
+func recurrenceRule(task models.Task) string {
+	freq := map[string]string{"daily": "DAILY", "weekly": "WEEKLY", "monthly": "MONTHLY", "yearly": "YEARLY"}[task.RecurrencePattern]
+
+	if freq == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("FREQ=%s;INTERVAL=%d", freq, max(task.RecurrenceInterval, 1))
+}
+
+// ParseTodoStatus returns the STATUS of the first VTODO in an iCalendar object.
+// Calendar clients PUT the whole object back when a task is ticked off.
+func ParseTodoStatus(data []byte) (string, error) {
+	// Unfold continuation lines first (RFC 5545 section 3.1).
+	content := strings.NewReplacer("\r\n ", "", "\r\n\t", "", "\n ", "", "\n\t", "").Replace(string(data))
+
+	inTodo := false
+
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimRight(line, "\r")
+
+		switch {
+		case line == "BEGIN:VTODO":
+			inTodo = true
+		case line == "END:VTODO":
+			inTodo = false
+		case inTodo && strings.HasPrefix(line, "STATUS"):
+			if i := strings.Index(line, ":"); i >= 0 {
+				return strings.ToUpper(strings.TrimSpace(line[i+1:])), nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("no VTODO with a STATUS found")
+}
+
+func (c *icsWriter) line(content string) {
+	if c.err != nil {
+		return
+	}
+
+	_, c.err = io.WriteString(c.w, foldLine(content)+"\r\n")
+}
+
+func taskDescription(task models.Task) string {
+	var lines []string
+
+	if task.Metadata != "" {
+		lines = append(lines, task.Metadata)
+	}
+
+	for _, subTask := range task.SubTasks {
+		lines = append(lines, fmt.Sprintf("[%s] %s", checkbox(subTask.Completed), subTask.Name))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func escapeText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// foldLine splits lines longer than 75 octets as required by RFC 5545 without
+// breaking multi-byte characters.
+func foldLine(line string) string {
+	if len(line) <= 75 {
+		return line
+	}
+
+	var b strings.Builder
+
+	width := 0
+
+	for _, r := range line {
+		size := len(string(r))
+
+		if width+size > 75 {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+
+		b.WriteRune(r)
+		width += size
+	}
+
+	return b.String()
+}
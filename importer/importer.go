@@ -0,0 +1,202 @@
+package importer
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"todo-server/db"
+	"todo-server/models"
+)
+
+const (
+	SourceTodoist    = "todoist"
+	SourceMSToDo     = "mstodo"
+	SourceTodoTxt    = "todotxt"
+	maxMetadataChars = 255
+)
+
+// Parse reads an export of another todo app. listName is used for formats that
+// don't carry the name of the list themselves (Todoist CSV is one file per project).
+func Parse(source string, data []byte, listName string) ([]models.ImportedList, error) {
+	switch source {
+	case SourceTodoist:
+		if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+			return ParseTodoistJSON(data)
+		}
+
+		if listName == "" {
+			listName = "Todoist"
+		}
+
+		return ParseTodoistCSV(data, listName)
+	case SourceMSToDo:
+		return ParseMSToDo(data)
+	case SourceTodoTxt:
+		return ParseTodoTxt(data)
+	}
+
+	return nil, fmt.Errorf("unsupported import source %q", source)
+}
+
+// Import creates the parsed lists, tasks and sub tasks under the profile. Lists are
+// matched by name with the existing lists of the profile. When dryRun is set nothing
+// is written and the summary reports what would have been created.
+func Import(DB *sql.DB, profileID *int, lists []models.ImportedList, dryRun bool) (*models.ImportSummary, error) {
+	tx, err := DB.Begin()
+
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	summary := models.ImportSummary{DryRun: dryRun}
+
+	for _, list := range lists {
+		if list.Name != "" {
+			var listID int
+
+			err := tx.QueryRow(
+				"SELECT id FROM lists WHERE name = $1 AND profile_id IS NOT DISTINCT FROM $2 AND deleted_at IS NULL ORDER BY id LIMIT 1",
+				list.Name, profileID,
+			).Scan(&listID)
+
+			switch {
+			case err == nil:
+				list.ListID = &listID
+				list.Existing = true
+			case err != sql.ErrNoRows:
+				return nil, err
+			case dryRun:
+				summary.ListsCreated++
+			default:
+				if err := tx.QueryRow("INSERT INTO lists (name, profile_id) VALUES ($1, $2) RETURNING id", list.Name, profileID).Scan(&listID); err != nil {
+					return nil, fmt.Errorf("creating list %q failed: %v", list.Name, err)
+				}
+
+				list.ListID = &listID
+				summary.ListsCreated++
+			}
+		}
+
+		for _, task := range list.Tasks {
+			summary.TasksCreated++
+			summary.SubTasksCreated += len(task.SubTasks)
+
+			if dryRun {
+				continue
+			}
+
+			if err := insertTask(tx, task, list.ListID, profileID); err != nil {
+				return nil, fmt.Errorf("creating task %q failed: %v", task.Name, err)
+			}
+		}
+
+		summary.Lists = append(summary.Lists, list)
+	}
+
+	if dryRun {
+		return &summary, nil
+	}
+
+	return &summary, tx.Commit()
+}
+
+func insertTask(tx *sql.Tx, task models.ImportedTask, listID *int, profileID *int) error {
+	if task.RecurrencePattern == "" {
+		task.RecurrenceInterval = 0
+	} else if task.DueDate == "" {
+		// The next occurrence is calculated from the start date so recurring tasks need one.
+		task.DueDate = time.Now().Format("2006-01-02")
+	}
+
+	taskID, err := db.InsertTask(tx, models.Task{
+		Name:               task.Name,
+		Completed:          task.Completed,
+		CompletedOn:        task.CompletedOn,
+		IsImportant:        task.IsImportant,
+		DueDate:            task.DueDate,
+		StartDate:          task.DueDate,
+		Metadata:           truncate(task.Metadata, maxMetadataChars),
+		RecurrencePattern:  task.RecurrencePattern,
+		RecurrenceInterval: task.RecurrenceInterval,
+		ListID:             listID,
+		ProfileID:          profileID,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	for _, subTask := range task.SubTasks {
+		if _, err := db.InsertSubTask(tx, models.SubTask{Name: subTask.Name, TaskID: taskID, Completed: subTask.Completed}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addToList appends the task to the list with the given name, keeping the lists
+// in the order they were first seen.
+func addToList(lists []models.ImportedList, name string, task models.ImportedTask) []models.ImportedList {
+	for i := range lists {
+		if lists[i].Name == name {
+			lists[i].Tasks = append(lists[i].Tasks, task)
+			return lists
+		}
+	}
+
+	return append(lists, models.ImportedList{Name: name, Tasks: []models.ImportedTask{task}})
+}
+
+var everyRegex = regexp.MustCompile(`(?i)\bevery\s+(other\s+|\d+\s+)?(day|week|month|year)s?\b`)
+
+// parseRecurrence understands the common English recurrence phrases used by
+// Todoist ("every day", "every 2 weeks", "every other month") and returns the
+// matching recurrence_pattern_enum value.
+func parseRecurrence(s string) (string, int, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "daily":
+		return "daily", 1, true
+	case "weekly":
+		return "weekly", 1, true
+	case "monthly":
+		return "monthly", 1, true
+	case "yearly", "annually":
+		return "yearly", 1, true
+	}
+
+	matches := everyRegex.FindStringSubmatch(s)
+
+	if matches == nil {
+		return "", 0, false
+	}
+
+	interval := 1
+
+	switch count := strings.TrimSpace(strings.ToLower(matches[1])); count {
+	case "":
+	case "other":
+		interval = 2
+	default:
+		interval, _ = strconv.Atoi(count)
+	}
+
+	pattern := map[string]string{"day": "daily", "week": "weekly", "month": "monthly", "year": "yearly"}[strings.ToLower(matches[2])]
+
+	return pattern, interval, true
+}
+
+func truncate(s string, max int) string {
+	runes := []rune(s)
+
+	if len(runes) <= max {
+		return s
+	}
+
+	return string(runes[:max])
+}
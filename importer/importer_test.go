@@ -0,0 +1,149 @@
+package importer
+
+import (
+	"testing"
+)
+
+func TestParseTodoTxt(t *testing.T) {
+	data := []byte(`
+(A) 2024-07-30 Call mom @phone +Family due:2024-08-05
+x 2024-08-01 2024-07-28 Pay rent +Home rec:1m pri:A
+Buy milk
+`)
+
+	lists, err := ParseTodoTxt(data)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(lists) != 3 || lists[0].Name != "Family" || lists[1].Name != "Home" || lists[2].Name != "" {
+		t.Fatalf("unexpected lists %+v", lists)
+	}
+
+	call := lists[0].Tasks[0]
+
+	if call.Name != "Call mom @phone" || !call.IsImportant || call.DueDate != "2024-08-05" || call.Completed {
+		t.Fatalf("unexpected task %+v", call)
+	}
+
+	rent := lists[1].Tasks[0]
+
+	if rent.Name != "Pay rent" || !rent.Completed || rent.CompletedOn != "2024-08-01 00:00:00" || !rent.IsImportant {
+		t.Fatalf("unexpected task %+v", rent)
+	}
+
+	if rent.RecurrencePattern != "monthly" || rent.RecurrenceInterval != 1 {
+		t.Fatalf("unexpected recurrence %q %d", rent.RecurrencePattern, rent.RecurrenceInterval)
+	}
+}
+
+func TestParseTodoistCSV(t *testing.T) {
+	data := []byte("TYPE,CONTENT,DESCRIPTION,PRIORITY,INDENT,AUTHOR,RESPONSIBLE,DATE,DATE_LANG,TIMEZONE\n" +
+		"task,Release 1.0,Ship it,1,1,,,every 2 weeks,en,\n" +
+		"task,Write changelog,,4,2,,,,en,\n" +
+		"section,Later,,,,,,,,\n" +
+		"task,Clean up,,4,1,,,2024-08-10,en,\n")
+
+	lists, err := ParseTodoistCSV(data, "Work")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(lists) != 1 || lists[0].Name != "Work" || len(lists[0].Tasks) != 2 {
+		t.Fatalf("unexpected lists %+v", lists)
+	}
+
+	release := lists[0].Tasks[0]
+
+	if !release.IsImportant || release.Metadata != "Ship it" || release.RecurrencePattern != "weekly" || release.RecurrenceInterval != 2 {
+		t.Fatalf("unexpected task %+v", release)
+	}
+
+	if len(release.SubTasks) != 1 || release.SubTasks[0].Name != "Write changelog" {
+		t.Fatalf("unexpected sub tasks %+v", release.SubTasks)
+	}
+
+	if cleanUp := lists[0].Tasks[1]; cleanUp.IsImportant || cleanUp.DueDate != "2024-08-10" {
+		t.Fatalf("unexpected task %+v", cleanUp)
+	}
+}
+
+func TestParseTodoistJSON(t *testing.T) {
+	data := []byte(`{
+		"projects": [{"id": "1", "name": "Inbox", "inbox_project": true}, {"id": "2", "name": "Home"}],
+		"items": [
+			{"id": "10", "content": "Pay rent", "project_id": "2", "priority": 4, "due": {"date": "2024-08-01", "string": "every month", "is_recurring": true}},
+			{"id": "11", "content": "Transfer money", "project_id": "2", "parent_id": "10", "checked": true},
+			{"id": "12", "content": "Check bank", "project_id": "2", "parent_id": "11"},
+			{"id": "13", "content": "Read", "project_id": "1", "priority": 1}
+		]
+	}`)
+
+	lists, err := ParseTodoistJSON(data)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(lists) != 2 || lists[0].Name != "Home" || lists[1].Name != "" {
+		t.Fatalf("unexpected lists %+v", lists)
+	}
+
+	rent := lists[0].Tasks[0]
+
+	if !rent.IsImportant || rent.DueDate != "2024-08-01" || rent.RecurrencePattern != "monthly" {
+		t.Fatalf("unexpected task %+v", rent)
+	}
+
+	if len(rent.SubTasks) != 2 || !rent.SubTasks[0].Completed || rent.SubTasks[1].Name != "Check bank" {
+		t.Fatalf("unexpected sub tasks %+v", rent.SubTasks)
+	}
+}
+
+func TestParseMSToDo(t *testing.T) {
+	data := []byte(`{"lists": [
+		{"displayName": "Tasks", "wellknownListName": "defaultList", "tasks": [
+			{"title": "Water plants", "status": "notStarted", "importance": "normal",
+			 "recurrence": {"pattern": {"type": "absoluteMonthly", "interval": 1}}}
+		]},
+		{"displayName": "Groceries", "wellknownListName": "none", "tasks": [
+			{"title": "Weekly shop", "status": "completed", "importance": "high",
+			 "body": {"content": "Use the coupon"},
+			 "dueDateTime": {"dateTime": "2024-08-03T00:00:00.0000000", "timeZone": "UTC"},
+			 "completedDateTime": {"dateTime": "2024-08-03T10:15:00.0000000", "timeZone": "UTC"},
+			 "checklistItems": [{"displayName": "Eggs", "isChecked": true}]}
+		]}
+	]}`)
+
+	lists, err := ParseMSToDo(data)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(lists) != 2 || lists[0].Name != "" || lists[1].Name != "Groceries" {
+		t.Fatalf("unexpected lists %+v", lists)
+	}
+
+	if plants := lists[0].Tasks[0]; plants.RecurrencePattern != "monthly" || plants.RecurrenceInterval != 1 {
+		t.Fatalf("unexpected task %+v", plants)
+	}
+
+	shop := lists[1].Tasks[0]
+
+	if !shop.Completed || !shop.IsImportant || shop.DueDate != "2024-08-03" || shop.CompletedOn != "2024-08-03 10:15:00" || shop.Metadata != "Use the coupon" {
+		t.Fatalf("unexpected task %+v", shop)
+	}
+
+	if len(shop.SubTasks) != 1 || !shop.SubTasks[0].Completed {
+		t.Fatalf("unexpected sub tasks %+v", shop.SubTasks)
+	}
+}
+
+func TestParseUnsupportedSource(t *testing.T) {
+	if _, err := Parse("wunderlist", nil, ""); err == nil {
+		t.Fatal("expected an error for an unsupported source")
+	}
+}
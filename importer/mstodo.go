@@ -0,0 +1,133 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"time"
+	"todo-server/models"
+)
+
+type msToDoList struct {
+	DisplayName       string `json:"displayName"`
+	WellknownListName string `json:"wellknownListName"`
+	Tasks             []struct {
+		Title      string `json:"title"`
+		Status     string `json:"status"`
+		Importance string `json:"importance"`
+		Body       *struct {
+			Content string `json:"content"`
+		} `json:"body"`
+		DueDateTime *struct {
+			DateTime string `json:"dateTime"`
+		} `json:"dueDateTime"`
+		CompletedDateTime *struct {
+			DateTime string `json:"dateTime"`
+		} `json:"completedDateTime"`
+		Recurrence *struct {
+			Pattern struct {
+				Type     string `json:"type"`
+				Interval int    `json:"interval"`
+			} `json:"pattern"`
+		} `json:"recurrence"`
+		ChecklistItems []struct {
+			DisplayName string `json:"displayName"`
+			IsChecked   bool   `json:"isChecked"`
+		} `json:"checklistItems"`
+	} `json:"tasks"`
+}
+
+// ParseMSToDo reads Microsoft To Do lists in the Microsoft Graph todoTaskList
+// shape, either as {"lists": [...]}, {"value": [...]} or a bare array, with the
+// tasks of each list nested under "tasks". The default "Tasks" list maps to the
+// inbox and checklist items become sub tasks.
+func ParseMSToDo(data []byte) ([]models.ImportedList, error) {
+	var msLists []msToDoList
+
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(data, &msLists); err != nil {
+			return nil, err
+		}
+	} else {
+		var wrapper struct {
+			Lists []msToDoList `json:"lists"`
+			Value []msToDoList `json:"value"`
+		}
+
+		if err := json.Unmarshal(data, &wrapper); err != nil {
+			return nil, err
+		}
+
+		msLists = append(wrapper.Lists, wrapper.Value...)
+	}
+
+	var lists []models.ImportedList
+
+	for _, msList := range msLists {
+		name := msList.DisplayName
+
+		if msList.WellknownListName == "defaultList" {
+			name = ""
+		}
+
+		list := models.ImportedList{Name: name}
+
+		for _, msTask := range msList.Tasks {
+			if strings.TrimSpace(msTask.Title) == "" {
+				continue
+			}
+
+			task := models.ImportedTask{
+				Name:        msTask.Title,
+				Completed:   msTask.Status == "completed",
+				IsImportant: msTask.Importance == "high",
+			}
+
+			if msTask.Body != nil {
+				task.Metadata = strings.TrimSpace(msTask.Body.Content)
+			}
+
+			if msTask.DueDateTime != nil && len(msTask.DueDateTime.DateTime) >= 10 {
+				task.DueDate = msTask.DueDateTime.DateTime[:10]
+			}
+
+			if task.Completed {
+				task.CompletedOn = time.Now().Format("2006-01-02 15:04:05")
+
+				if msTask.CompletedDateTime != nil && len(msTask.CompletedDateTime.DateTime) >= 19 {
+					task.CompletedOn = strings.Replace(msTask.CompletedDateTime.DateTime[:19], "T", " ", 1)
+				}
+			}
+
+			if msTask.Recurrence != nil {
+				task.RecurrencePattern = msToDoRecurrence(msTask.Recurrence.Pattern.Type)
+				task.RecurrenceInterval = max(msTask.Recurrence.Pattern.Interval, 1)
+			}
+
+			for _, item := range msTask.ChecklistItems {
+				task.SubTasks = append(task.SubTasks, models.ImportedSubTask{Name: item.DisplayName, Completed: item.IsChecked})
+			}
+
+			list.Tasks = append(list.Tasks, task)
+		}
+
+		lists = append(lists, list)
+	}
+
+	return lists, nil
+}
+
+func msToDoRecurrence(patternType string) string {
+	switch patternType {
+	case "daily":
+		return "daily"
+	case "weekly":
+		return "weekly"
+	case "absoluteMonthly", "relativeMonthly":
+		return "monthly"
+	case "absoluteYearly", "relativeYearly":
+		return "yearly"
+	}
+
+	return ""
+}
@@ -0,0 +1,254 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"todo-server/models"
+)
+
+// ParseTodoistCSV reads a project exported from Todoist as CSV. Rows with INDENT 1
+// are tasks and deeper rows become sub tasks of the task above them. Todoist
+// writes the p1 priority as PRIORITY 1, which is mapped to important.
+func ParseTodoistCSV(data []byte, listName string) ([]models.ImportedList, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+
+	records, err := reader.ReadAll()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	columns := map[string]int{}
+
+	for i, column := range records[0] {
+		columns[strings.ToUpper(strings.TrimSpace(column))] = i
+	}
+
+	for _, required := range []string{"TYPE", "CONTENT"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("todoist csv is missing the %s column", required)
+		}
+	}
+
+	get := func(record []string, column string) string {
+		if i, ok := columns[column]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+
+		return ""
+	}
+
+	list := models.ImportedList{Name: listName}
+
+	for _, record := range records[1:] {
+		if get(record, "TYPE") != "task" {
+			continue
+		}
+
+		content := get(record, "CONTENT")
+
+		if content == "" {
+			continue
+		}
+
+		indent, _ := strconv.Atoi(get(record, "INDENT"))
+
+		if indent > 1 && len(list.Tasks) > 0 {
+			parent := &list.Tasks[len(list.Tasks)-1]
+			parent.SubTasks = append(parent.SubTasks, models.ImportedSubTask{Name: content})
+
+			continue
+		}
+
+		task := models.ImportedTask{
+			Name:        content,
+			Metadata:    get(record, "DESCRIPTION"),
+			IsImportant: get(record, "PRIORITY") == "1",
+		}
+
+		setTodoistDue(&task, get(record, "DATE"), "")
+
+		list.Tasks = append(list.Tasks, task)
+	}
+
+	return []models.ImportedList{list}, nil
+}
+
+type todoistBackup struct {
+	Projects []struct {
+		ID           json.RawMessage `json:"id"`
+		Name         string          `json:"name"`
+		InboxProject bool            `json:"inbox_project"`
+		IsInbox      bool            `json:"is_inbox_project"`
+	} `json:"projects"`
+	Items []struct {
+		ID          json.RawMessage `json:"id"`
+		Content     string          `json:"content"`
+		Description string          `json:"description"`
+		ProjectID   json.RawMessage `json:"project_id"`
+		ParentID    json.RawMessage `json:"parent_id"`
+		Checked     json.RawMessage `json:"checked"`
+		Completed   bool            `json:"is_completed"`
+		CompletedAt string          `json:"completed_at"`
+		Priority    int             `json:"priority"`
+		Due         *struct {
+			Date        string `json:"date"`
+			String      string `json:"string"`
+			IsRecurring bool   `json:"is_recurring"`
+		} `json:"due"`
+	} `json:"items"`
+}
+
+// ParseTodoistJSON reads a Todoist backup in the Sync API shape ({"projects": [...],
+// "items": [...]}). The API numbers priorities the other way around from the UI,
+// so priority 4 is p1 and is mapped to important. Nested items at any depth become
+// sub tasks of their top level task.
+func ParseTodoistJSON(data []byte) ([]models.ImportedList, error) {
+	var backup todoistBackup
+
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return nil, err
+	}
+
+	projects := map[string]string{}
+
+	for _, project := range backup.Projects {
+		name := project.Name
+
+		if project.InboxProject || project.IsInbox {
+			name = ""
+		}
+
+		projects[rawID(project.ID)] = name
+	}
+
+	parents := map[string]string{}
+
+	for _, item := range backup.Items {
+		if parentID := rawID(item.ParentID); parentID != "" {
+			parents[rawID(item.ID)] = parentID
+		}
+	}
+
+	root := func(id string) string {
+		for seen := 0; parents[id] != "" && seen < len(parents); seen++ {
+			id = parents[id]
+		}
+
+		return id
+	}
+
+	type position struct {
+		list string
+		task int
+	}
+
+	var lists []models.ImportedList
+
+	positions := map[string]position{}
+
+	for _, item := range backup.Items {
+		if rawID(item.ParentID) != "" {
+			continue
+		}
+
+		task := models.ImportedTask{
+			Name:        item.Content,
+			Metadata:    item.Description,
+			IsImportant: item.Priority == 4,
+			Completed:   item.Completed || isTruthy(item.Checked),
+		}
+
+		if task.Completed {
+			task.CompletedOn = todoistCompletedOn(item.CompletedAt)
+		}
+
+		if item.Due != nil {
+			setTodoistDue(&task, item.Due.Date, item.Due.String)
+		}
+
+		list := projects[rawID(item.ProjectID)]
+		lists = addToList(lists, list, task)
+
+		for i := range lists {
+			if lists[i].Name == list {
+				positions[rawID(item.ID)] = position{list: list, task: len(lists[i].Tasks) - 1}
+			}
+		}
+	}
+
+	for _, item := range backup.Items {
+		if rawID(item.ParentID) == "" {
+			continue
+		}
+
+		at, ok := positions[root(rawID(item.ID))]
+
+		if !ok {
+			continue
+		}
+
+		for i := range lists {
+			if lists[i].Name == at.list {
+				task := &lists[i].Tasks[at.task]
+				task.SubTasks = append(task.SubTasks, models.ImportedSubTask{Name: item.Content, Completed: item.Completed || isTruthy(item.Checked)})
+			}
+		}
+	}
+
+	return lists, nil
+}
+
+// setTodoistDue handles both plain dates and recurring due strings like
+// "every 2 weeks". The date of a recurring task is its next occurrence.
+func setTodoistDue(task *models.ImportedTask, date string, dueString string) {
+	if len(date) >= 10 {
+		if _, err := time.Parse("2006-01-02", date[:10]); err == nil {
+			task.DueDate = date[:10]
+		}
+	}
+
+	for _, s := range []string{dueString, date} {
+		if pattern, interval, ok := parseRecurrence(s); ok {
+			task.RecurrencePattern = pattern
+			task.RecurrenceInterval = interval
+			return
+		}
+	}
+}
+
+func todoistCompletedOn(completedAt string) string {
+	if completed, err := time.Parse(time.RFC3339Nano, completedAt); err == nil {
+		return completed.Format("2006-01-02 15:04:05")
+	}
+
+	return time.Now().Format("2006-01-02 15:04:05")
+}
+
+// rawID normalises ids which are numbers in older backups and strings in newer ones.
+func rawID(raw json.RawMessage) string {
+	id := strings.Trim(string(raw), `"`)
+
+	if id == "null" {
+		return ""
+	}
+
+	return id
+}
+
+// isTruthy reads the checked flag which older backups store as 0/1.
+func isTruthy(raw json.RawMessage) bool {
+	value := strings.Trim(string(raw), `"`)
+
+	return value == "true" || value == "1"
+}
@@ -0,0 +1,100 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"todo-server/models"
+)
+
+var (
+	todoTxtDate     = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	todoTxtPriority = regexp.MustCompile(`^\([A-Z]\)$`)
+	todoTxtRec      = regexp.MustCompile(`^\+?(\d+)([dwmy])$`)
+)
+
+// ParseTodoTxt reads the todo.txt format (https://github.com/todotxt/todo.txt).
+// The first +project of a task becomes its list, priority (A) marks it as
+// important and the due: and rec: extensions map to the due date and recurrence.
+func ParseTodoTxt(data []byte) ([]models.ImportedList, error) {
+	var lists []models.ImportedList
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" {
+			continue
+		}
+
+		task, list := parseTodoTxtLine(line)
+
+		if task.Name == "" {
+			continue
+		}
+
+		lists = addToList(lists, list, task)
+	}
+
+	return lists, scanner.Err()
+}
+
+func parseTodoTxtLine(line string) (models.ImportedTask, string) {
+	var task models.ImportedTask
+	var list string
+
+	fields := strings.Fields(line)
+
+	if len(fields) > 0 && fields[0] == "x" {
+		task.Completed = true
+		fields = fields[1:]
+
+		if len(fields) > 0 && todoTxtDate.MatchString(fields[0]) {
+			task.CompletedOn = fields[0] + " 00:00:00"
+			fields = fields[1:]
+		}
+	}
+
+	if len(fields) > 0 && todoTxtPriority.MatchString(fields[0]) {
+		task.IsImportant = fields[0] == "(A)"
+		fields = fields[1:]
+	}
+
+	// Creation date. There is no way to keep it since created_at is set by the database.
+	if len(fields) > 0 && todoTxtDate.MatchString(fields[0]) {
+		fields = fields[1:]
+	}
+
+	var name []string
+
+	for _, field := range fields {
+		switch {
+		case strings.HasPrefix(field, "+") && len(field) > 1:
+			if list == "" {
+				list = field[1:]
+			}
+		case strings.HasPrefix(field, "due:"):
+			if _, err := time.Parse("2006-01-02", field[4:]); err == nil {
+				task.DueDate = field[4:]
+			}
+		case strings.HasPrefix(field, "pri:") && len(field) == 5:
+			// Completed tasks keep their priority in the pri: extension.
+			task.IsImportant = field[4:] == "A"
+		case strings.HasPrefix(field, "rec:"):
+			if matches := todoTxtRec.FindStringSubmatch(field[4:]); matches != nil {
+				task.RecurrenceInterval, _ = strconv.Atoi(matches[1])
+				task.RecurrencePattern = map[string]string{"d": "daily", "w": "weekly", "m": "monthly", "y": "yearly"}[matches[2]]
+			}
+		default:
+			name = append(name, field)
+		}
+	}
+
+	task.Name = strings.Join(name, " ")
+
+	return task, list
+}
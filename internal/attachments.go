@@ -0,0 +1,70 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// AttachmentsDir is where attachment files are kept, ATTACHMENTS_DIR or
+// ./attachments.
+func AttachmentsDir() string {
+	if dir := os.Getenv("ATTACHMENTS_DIR"); dir != "" {
+		return dir
+	}
+
+	return "attachments"
+}
+
+// AttachmentURL is the download link of an attachment. It is relative unless
+// PUBLIC_URL is set.
+func AttachmentURL(id int) string {
+	return fmt.Sprintf("%s/api/v1/attachment/%d", strings.TrimSuffix(os.Getenv("PUBLIC_URL"), "/"), id)
+}
+
+// MaxAttachmentSize is the largest upload accepted, ATTACHMENT_MAX_SIZE_MB or
+// 25 MB.
+func MaxAttachmentSize() int64 {
+	if mb, err := strconv.ParseInt(os.Getenv("ATTACHMENT_MAX_SIZE_MB"), 10, 64); err == nil && mb > 0 {
+		return mb << 20
+	}
+
+	return 25 << 20
+}
+
+// SaveAttachmentFile stores the content under a random key, keeping the extension
+// of the file name.
+func SaveAttachmentFile(fileName string, contentType string, data []byte) (string, error) {
+	token, err := GenerateToken()
+
+	if err != nil {
+		return "", err
+	}
+
+	key := token + strings.ToLower(filepath.Ext(filepath.Base(fileName)))
+
+	if err := AttachmentStorage().Put(key, data, contentType); err != nil {
+		return "", err
+	}
+
+	return key, nil
+}
+
+// OpenAttachmentFile returns the content of an attachment. With local storage it
+// is an io.ReadSeeker as well.
+func OpenAttachmentFile(key string) (io.ReadCloser, error) {
+	return AttachmentStorage().Get(key)
+}
+
+// RemoveAttachmentFiles is used to clean up files that did not end up attached.
+func RemoveAttachmentFiles(keys []string) {
+	for _, key := range keys {
+		if err := AttachmentStorage().Delete(key); err != nil {
+			log.Println("Failed to remove attachment file", key, err)
+		}
+	}
+}
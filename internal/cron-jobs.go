@@ -2,20 +2,19 @@ package internal
 
 import (
 	"bytes"
-	"context"
 	"database/sql"
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"text/tabwriter"
 	"time"
 	"todo-server/backup"
 	"todo-server/db"
+	"todo-server/internal/query"
 	templates "todo-server/internal/templates/today-tasks"
 	"todo-server/models"
-	"todo-server/utils"
 
-	"github.com/chromedp/chromedp"
 	"github.com/robfig/cron/v3"
 )
 
@@ -29,75 +28,196 @@ const (
 	magenta = "\033[35m"
 )
 
+// SyncURLTitle fetches the titles that are still missing and re-validates the
+// ones checked longer than URL_TITLE_MAX_AGE_DAYS (30 by default) ago.
 func SyncURLTitle(dc *sql.DB) {
-	urlTitles, err := db.GetAllURLTitles(dc)
+	days := 30
 
-	log.Println("Syncing URL Titles...")
+	if value, err := strconv.Atoi(os.Getenv("URL_TITLE_MAX_AGE_DAYS")); err == nil && value > 0 {
+		days = value
+	}
+
+	start := time.Now()
+
+	urlTitles, err := db.GetURLTitlesToSync(dc, days)
+
+	if err != nil {
+		log.Println("Failed to sync url titles because", err)
+		return
+	}
 
-	if urlTitles == nil {
+	if len(urlTitles) == 0 {
 		log.Printf("No urls found")
 
 		return
 	}
 
+	log.Printf("Syncing %d URL Titles...\n", len(urlTitles))
+
+	known := map[string]models.URLTitle{}
+	urls := make([]string, 0, len(urlTitles))
+
+	for _, urlTitle := range urlTitles {
+		known[urlTitle.URL] = urlTitle
+		urls = append(urls, urlTitle.URL)
+	}
+
+	titlePool.Run(urls, func(pageURL string, preview models.LinkPreview, err error) {
+		if err != nil {
+			log.Printf("Failed to fetch title of %s%s%s. Got %s\n", blue, pageURL, reset, err.Error())
+
+			// A page that had a title keeps it, it may only be down for a moment.
+			if old := known[pageURL]; old.IsValid && old.Title != "" {
+				err = db.TouchURLTitle(dc, pageURL)
+			} else {
+				err = db.SaveLinkPreview(dc, preview, false)
+			}
+		} else {
+			log.Printf("%sSaving title%s: %s`%s`%s for URL: %s%s%s\n", magenta, reset, green, preview.Title, reset, blue, pageURL, reset)
+
+			err = db.SaveLinkPreview(dc, preview, true)
+		}
+
+		if err != nil {
+			log.Printf("%sFailed to save title of %s%s: %s\n", red, pageURL, reset, err.Error())
+		}
+	})
+
+	log.Printf("Syncing completed in %s.\n", time.Since(start).Round(time.Millisecond))
+}
+
+// PurgeTrash permanently removes items that have been in the trash for longer
+// than TRASH_RETENTION_DAYS (30 by default).
+func PurgeTrash(dc *sql.DB) {
+	days := 30
+
+	if value, err := strconv.Atoi(os.Getenv("TRASH_RETENTION_DAYS")); err == nil && value > 0 {
+		days = value
+	}
+
+	purged, err := db.PurgeTrash(dc, time.Now().AddDate(0, 0, -days))
+
 	if err != nil {
-		log.Println("Failed to sync url titles because", err)
+		log.Println("Failed to purge trash", err)
+
 		return
 	}
 
-	chromePath := os.Getenv("CHROME_PATH")
-
-	utils.Assert(chromePath != "", "Chrome Path ENV value is not set")
+	log.Printf("Purged %d items from the trash\n", purged)
+}
 
-	opts := append(chromedp.DefaultExecAllocatorOptions[:],
-		chromedp.ExecPath(chromePath),
-	)
+// DeliverWebhooks sends the webhook deliveries that are due, retrying failed ones
+// with backoff until MaxWebhookAttempts.
+func DeliverWebhooks(dc *sql.DB) {
+	for {
+		jobs, err := db.ClaimWebhookDeliveries(dc, 20)
 
-	allocCtx, cancel := chromedp.NewExecAllocator(context.Background(), opts...)
-	defer cancel()
+		if err != nil {
+			log.Println("Failed to fetch webhook deliveries", err)
 
-	ctx, cancel := chromedp.NewContext(allocCtx)
-	defer cancel()
+			return
+		}
 
-	for _, urlTitle := range urlTitles {
-		if urlTitle.IsValid && urlTitle.Title != "" {
-			continue
+		if len(jobs) == 0 {
+			return
 		}
 
-		var pageTitle string
+		for _, job := range jobs {
+			status, deliveryErr := DeliverWebhook(job.URL, job.Secret, job.ID, job.Event, job.Payload)
 
-		log.Printf("Syncing %s", urlTitle.URL)
+			var retryIn time.Duration
 
-		err := chromedp.Run(ctx,
-			chromedp.Navigate(urlTitle.URL),
-			chromedp.WaitReady("body"),
-			chromedp.Evaluate(`document.title`, &pageTitle),
-		)
+			if deliveryErr != nil && job.Attempts < MaxWebhookAttempts {
+				retryIn = WebhookBackoff(job.Attempts)
+			}
 
-		if err != nil && pageTitle == "" {
-			log.Printf("Failed to fetch title. Got %s%s%s\n", reset, err.Error(), reset)
-			continue
+			if err := db.FinishWebhookDelivery(dc, job.ID, status, deliveryErr, retryIn); err != nil {
+				log.Println("Failed to save webhook delivery", err)
+			}
 		}
+	}
+}
+
+// EnqueueOverdueWebhooks announces the tasks that became overdue today.
+func EnqueueOverdueWebhooks(dc *sql.DB) {
+	queued, err := db.EnqueueOverdueWebhooks(dc)
+
+	if err != nil {
+		log.Println("Failed to queue overdue webhooks", err)
 
-		if urlTitle.Title == pageTitle {
-			log.Printf("`%s` and `%s` are same\n", urlTitle.Title, pageTitle)
-			continue
+		return
+	}
+
+	log.Printf("Queued %d overdue task webhooks\n", queued)
+}
+
+// RemoveDeletedAttachmentFiles removes the stored files of deleted attachments,
+// including the ones that went away with a purged task.
+func RemoveDeletedAttachmentFiles(dc *sql.DB) {
+	for {
+		keys, err := db.GetAttachmentDeletions(dc, 100)
+
+		if err != nil {
+			log.Println("Failed to fetch deleted attachments", err)
+
+			return
 		}
 
-		if pageTitle == "" {
-			log.Printf("%s%s%s URL title not available. Got empty", red, urlTitle.URL, reset)
-			continue
+		if len(keys) == 0 {
+			return
 		}
 
-		log.Printf("%sSaving new title%s: %s`%s`%s for URL: %s%s%s\n", magenta, reset, green, pageTitle, reset, blue, urlTitle.URL, reset)
+		for _, key := range keys {
+			if err := AttachmentStorage().Delete(key); err != nil {
+				log.Println("Failed to remove attachment file", key, err)
+
+				return
+			}
 
-		_ = db.SaveOrUpdateURLTitle(dc, pageTitle, urlTitle.URL, true)
+			if err := db.FinishAttachmentDeletion(dc, key); err != nil {
+				log.Println("Failed to remove attachment file", key, err)
 
+				return
+			}
+		}
 	}
+}
+
+// StartBackgroundJobs schedules the jobs the server needs to work as documented.
+// Unlike SetupCronJobs it needs no email credentials, so it is always started.
+func StartBackgroundJobs(dc *sql.DB) {
+	istLocation, _ := time.LoadLocation("Asia/Kolkata")
+
+	c := cron.New(cron.WithSeconds(), cron.WithLocation(istLocation), cron.WithChain(cron.SkipIfStillRunning(cron.DiscardLogger)))
 
-	// TODO: log time it took to complete this action.
-	log.Println("Syncing completed.")
+	// Every 15 seconds
+	c.AddFunc("*/15 * * * * *", func() {
+		DeliverWebhooks(dc)
+	})
+
+	// Every day just after midnight, when yesterday's open tasks became overdue.
+	c.AddFunc("0 5 0 * * *", func() {
+		EnqueueOverdueWebhooks(dc)
+	})
 
+	// Every hour, also picks up the attachments of the purged tasks.
+	c.AddFunc("0 30 * * * *", func() {
+		RemoveDeletedAttachmentFiles(dc)
+	})
+
+	// Every day at midnight
+	c.AddFunc("0 0 0 * * *", func() {
+		SyncURLTitle(dc)
+	})
+
+	// Every day morning 4:00 AM, after the 3:00 AM backup has picked up the trashed rows.
+	c.AddFunc("0 0 4 * * *", func() {
+		PurgeTrash(dc)
+	})
+
+	c.Start()
+
+	log.Println("Background jobs have been set up successfully.", time.Now())
 }
 
 func SetupCronJobs(db *sql.DB, emailAuth models.EmailAuth) {
@@ -118,10 +238,6 @@ func SetupCronJobs(db *sql.DB, emailAuth models.EmailAuth) {
 		log.Println("Deleted logs successfully")
 	})
 
-	// c.AddFunc("0 0 0 * * *", func() {
-	// 	SyncURLTitle(db)
-	// })
-
 	// Every day morning 3:00 AM
 	c.AddFunc("0 0 3 * * *", func() {
 		backup.BackupTasks(db, emailAuth)
@@ -131,7 +247,7 @@ func SetupCronJobs(db *sql.DB, emailAuth models.EmailAuth) {
 	c.AddFunc("0 0 7 * * *", func() {
 		today := time.Now().Format("2006-01-02")
 
-		query := "select name, due_date from tasks where due_date = $1 and completed = false ORDER BY created_at DESC"
+		query := "select name, due_date, priority from tasks t where due_date = $1 and completed = false and deleted_at is null and not " + query.BlockedCondition + " ORDER BY priority DESC, created_at DESC"
 
 		rows, err := db.Query(query, today)
 
@@ -144,7 +260,7 @@ func SetupCronJobs(db *sql.DB, emailAuth models.EmailAuth) {
 
 		for rows.Next() {
 			var task models.Task
-			if err := rows.Scan(&task.Name, &task.DueDate); err != nil {
+			if err := rows.Scan(&task.Name, &task.DueDate, &task.Priority); err != nil {
 				log.Println("Failed to set task data")
 				return
 			}
@@ -179,7 +295,7 @@ func SetupCronJobs(db *sql.DB, emailAuth models.EmailAuth) {
 	// Everyday night 10:00 PM
 	c.AddFunc("0 0 22 * * *", func() {
 		// TODO: fix this completed on date issue. Probably have to default the value to empty string instead of null. Have to change the table schema
-		query := "SELECT name FROM tasks WHERE completed = true AND DATE(NULLIF(completed_on, '')) = CURRENT_DATE;"
+		query := "SELECT name FROM tasks WHERE completed = true AND DATE(NULLIF(completed_on, '')) = CURRENT_DATE AND deleted_at IS NULL;"
 
 		rows, err := db.Query(query)
 
@@ -201,7 +317,7 @@ func SetupCronJobs(db *sql.DB, emailAuth models.EmailAuth) {
 		}
 		defer rows.Close()
 
-		totalTasksQuery := "select count(*) from tasks"
+		totalTasksQuery := "select count(*) from tasks where deleted_at is null"
 
 		var totalTasks int
 		count_err := db.QueryRow(totalTasksQuery).Scan(&totalTasks)
@@ -210,7 +326,7 @@ func SetupCronJobs(db *sql.DB, emailAuth models.EmailAuth) {
 			log.Println("Failed to run count query", count_err.Error())
 		}
 
-		totalCompletedTasksQuery := "select count(*) from tasks where completed = true;"
+		totalCompletedTasksQuery := "select count(*) from tasks where completed = true and deleted_at is null;"
 
 		var totalCompletedTasks int
 		curr_err := db.QueryRow(totalCompletedTasksQuery).Scan(&totalCompletedTasks)
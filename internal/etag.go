@@ -0,0 +1,34 @@
+package internal
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ETag is the entity tag of a row at the given revision.
+func ETag(revision int64) string {
+	return `"` + strconv.FormatInt(revision, 10) + `"`
+}
+
+// IfMatch reads the revisions out of an If-Match header. It returns nil when the
+// header is missing or "*", which means any revision is fine. Tags that are not
+// ours are dropped, so a header without any valid tag matches nothing.
+func IfMatch(header string) []int64 {
+	header = strings.TrimSpace(header)
+
+	if header == "" || header == "*" {
+		return nil
+	}
+
+	revisions := []int64{}
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+
+		if revision, err := strconv.ParseInt(strings.Trim(tag, `"`), 10, 64); err == nil {
+			revisions = append(revisions, revision)
+		}
+	}
+
+	return revisions
+}
@@ -0,0 +1,27 @@
+package internal
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestIfMatch(t *testing.T) {
+	tests := []struct {
+		Header    string
+		Revisions []int64
+	}{
+		{"", nil},
+		{"*", nil},
+		{`"12"`, []int64{12}},
+		{`W/"12", "15"`, []int64{12, 15}},
+		{`"abc"`, []int64{}},
+	}
+
+	for _, test := range tests {
+		revisions := IfMatch(test.Header)
+
+		if (revisions == nil) != (test.Revisions == nil) || !slices.Equal(revisions, test.Revisions) {
+			t.Fatalf("%q: expected %v, got %v", test.Header, test.Revisions, revisions)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package internal
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+	"todo-server/models"
+
+	"github.com/lib/pq"
+)
+
+// eventBuffer is how many events a slow client may fall behind before it is told
+// to resync instead.
+const eventBuffer = 64
+
+type subscriber struct {
+	profileID *int
+	all       bool
+	events    chan models.Event
+	behind    bool
+}
+
+// wants reports whether the subscriber gets the event. Like everywhere else, a
+// nil profile means the rows without a profile.
+func (s *subscriber) wants(event models.Event) bool {
+	if s.all || event.Action == "resync" {
+		return true
+	}
+
+	if s.profileID == nil || event.ProfileID == nil {
+		return s.profileID == nil && event.ProfileID == nil
+	}
+
+	return *s.profileID == *event.ProfileID
+}
+
+// Broker fans the change events of the database out to the connected clients.
+type Broker struct {
+	mu          sync.Mutex
+	subscribers map[*subscriber]bool
+}
+
+func NewBroker() *Broker {
+	return &Broker{subscribers: map[*subscriber]bool{}}
+}
+
+// Subscribe returns the events of the profile, or of the rows without a profile
+// when profileID is nil, until unsubscribe is called.
+func (b *Broker) Subscribe(profileID *int) (events <-chan models.Event, unsubscribe func()) {
+	return b.subscribe(&subscriber{profileID: profileID})
+}
+
+// SubscribeAll returns the events of every profile until unsubscribe is called.
+func (b *Broker) SubscribeAll() (events <-chan models.Event, unsubscribe func()) {
+	return b.subscribe(&subscriber{all: true})
+}
+
+func (b *Broker) subscribe(s *subscriber) (<-chan models.Event, func()) {
+	s.events = make(chan models.Event, eventBuffer)
+
+	b.mu.Lock()
+	b.subscribers[s] = true
+	b.mu.Unlock()
+
+	return s.events, func() {
+		b.mu.Lock()
+		delete(b.subscribers, s)
+		b.mu.Unlock()
+	}
+}
+
+// Publish never blocks. A client whose buffer is full misses the event and gets
+// a resync event as soon as it has room again.
+func (b *Broker) Publish(event models.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for s := range b.subscribers {
+		if !s.wants(event) {
+			continue
+		}
+
+		if s.behind {
+			select {
+			case s.events <- models.Event{Action: "resync"}:
+				s.behind = false
+			default:
+				continue
+			}
+		}
+
+		select {
+		case s.events <- event:
+		default:
+			s.behind = true
+		}
+	}
+}
+
+// ListenForChanges publishes the notifications of the changes channel. The
+// listener reconnects by itself; since notifications sent in the meantime are
+// lost, clients are told to resync after every reconnect.
+func (b *Broker) ListenForChanges(connStr string) {
+	listener := pq.NewListener(connStr, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("Listening for changes failed: %v", err)
+		}
+	})
+
+	if err := listener.Listen("changes"); err != nil {
+		log.Printf("Listening for changes failed: %v", err)
+	}
+
+	for {
+		select {
+		case notification := <-listener.Notify:
+			if notification == nil {
+				b.Publish(models.Event{Action: "resync"})
+				continue
+			}
+
+			var event models.Event
+
+			if err := json.Unmarshal([]byte(notification.Extra), &event); err != nil {
+				log.Printf("Invalid change notification %q: %v", notification.Extra, err)
+				continue
+			}
+
+			b.Publish(event)
+		case <-time.After(90 * time.Second):
+			go listener.Ping()
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package internal
+
+import (
+	"testing"
+	"todo-server/models"
+)
+
+func TestBroker(t *testing.T) {
+	one, two := 1, 2
+
+	broker := NewBroker()
+
+	all, unsubscribeAll := broker.SubscribeAll()
+	defer unsubscribeAll()
+
+	profile, unsubscribe := broker.Subscribe(&one)
+
+	noProfile, unsubscribeNoProfile := broker.Subscribe(nil)
+	defer unsubscribeNoProfile()
+
+	broker.Publish(models.Event{Table: "tasks", Action: "created", ID: 10, ProfileID: &one})
+	broker.Publish(models.Event{Table: "tasks", Action: "created", ID: 11, ProfileID: &two})
+	broker.Publish(models.Event{Table: "tasks", Action: "created", ID: 12})
+
+	if len(all) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(all))
+	}
+
+	if len(profile) != 1 || (<-profile).ID != 10 {
+		t.Fatal("expected only the event of profile 1")
+	}
+
+	if len(noProfile) != 1 || (<-noProfile).ID != 12 {
+		t.Fatal("expected only the event without a profile")
+	}
+
+	unsubscribe()
+	broker.Publish(models.Event{Table: "tasks", Action: "created", ID: 13, ProfileID: &one})
+
+	if len(profile) != 0 {
+		t.Fatal("expected no events after unsubscribing")
+	}
+
+	for len(all) > 0 {
+		<-all
+	}
+
+	// A full buffer drops events and asks for a resync once there is room.
+	for i := 0; i < eventBuffer+5; i++ {
+		broker.Publish(models.Event{Table: "tasks", Action: "updated", ID: i})
+	}
+
+	for len(all) > 0 {
+		<-all
+	}
+
+	broker.Publish(models.Event{Table: "tasks", Action: "updated", ID: 99})
+
+	if event := <-all; event.Action != "resync" {
+		t.Fatalf("expected resync, got %+v", event)
+	}
+
+	if event := <-all; event.ID != 99 {
+		t.Fatalf("expected the event after the resync, got %+v", event)
+	}
+}
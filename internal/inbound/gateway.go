@@ -0,0 +1,218 @@
+package inbound
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"todo-server/db"
+	"todo-server/internal"
+	"todo-server/models"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// maxMetadata is the size of the metadata column.
+const maxMetadata = 255
+
+var tokenAddress = regexp.MustCompile(`^[^@+]+\+([0-9a-f]{64})@`)
+
+// Setup starts the email gateway when INBOUND_SMTP_ADDR is set. Mail is only
+// taken for the secret addresses tasks+<token>@INBOUND_SMTP_DOMAIN created
+// through the API, each of which belongs to a profile. INBOUND_EMAIL_SENDERS
+// optionally limits the senders as well.
+func Setup(dc *sql.DB) {
+	addr := os.Getenv("INBOUND_SMTP_ADDR")
+
+	if addr == "" {
+		return
+	}
+
+	senders := map[string]bool{}
+
+	for _, sender := range strings.Split(os.Getenv("INBOUND_EMAIL_SENDERS"), ",") {
+		if sender = strings.ToLower(strings.TrimSpace(sender)); sender != "" {
+			senders[sender] = true
+		}
+	}
+
+	server := Server{
+		Domain:  Domain(),
+		MaxSize: 25 << 20,
+		AllowSender: func(from string) bool {
+			return len(senders) == 0 || senders[strings.ToLower(from)]
+		},
+		AllowRecipient: func(to string) bool {
+			_, err := recipientProfile(dc, []string{to})
+
+			return err == nil
+		},
+		Handler: TaskHandler(dc),
+	}
+
+	log.Printf("Email gateway listening on %s", addr)
+
+	if err := server.ListenAndServe(addr); err != nil {
+		log.Printf("Email gateway stopped: %v", err)
+	}
+}
+
+// Domain is the mail domain of the gateway, INBOUND_SMTP_DOMAIN or localhost.
+func Domain() string {
+	if domain := os.Getenv("INBOUND_SMTP_DOMAIN"); domain != "" {
+		return domain
+	}
+
+	return "localhost"
+}
+
+// recipientProfile returns the profile of the first recipient that is a known
+// gateway address.
+func recipientProfile(dc *sql.DB, recipients []string) (*int, error) {
+	for _, to := range recipients {
+		match := tokenAddress.FindStringSubmatch(strings.ToLower(to))
+
+		if match == nil {
+			continue
+		}
+
+		profileID, err := db.GetInboundAddressProfile(dc, match[1])
+
+		if err == sql.ErrNoRows {
+			continue
+		}
+
+		return profileID, err
+	}
+
+	return nil, errors.New("no known gateway address among the recipients")
+}
+
+func truncate(text string, size int) string {
+	if runes := []rune(text); len(runes) > size {
+		return string(runes[:size])
+	}
+
+	return text
+}
+
+// TaskHandler creates a task from each mail. The subject is the name, with #list
+// and !important tokens applied, and the body is the metadata. Attachments are
+// stored and linked from sub tasks, except those over the attachment size limit.
+func TaskHandler(dc *sql.DB) func(Envelope) error {
+	return func(envelope Envelope) error {
+		profileID, err := recipientProfile(dc, envelope.To)
+
+		if err != nil {
+			return err
+		}
+
+		mail, err := ParseMail(envelope.Data)
+
+		if err != nil {
+			return err
+		}
+
+		name, list, important := ParseSubject(mail.Subject)
+
+		if len([]rune(name)) < 3 {
+			name = "Email from " + envelope.From
+		}
+
+		task := models.Task{
+			Name:        truncate(name, 1000),
+			Metadata:    truncate(mail.Body, maxMetadata),
+			IsImportant: important,
+			ProfileID:   profileID,
+		}
+
+		var attachments []Attachment
+		var keys []string
+
+		for _, attachment := range mail.Attachments {
+			// Same limits as uploads, the claimed type of the sender is not trusted.
+			if int64(len(attachment.Data)) > internal.MaxAttachmentSize() {
+				log.Println("Skipping attachment over the size limit", attachment.FileName, len(attachment.Data))
+
+				continue
+			}
+
+			attachment.ContentType = mimetype.Detect(attachment.Data).String()
+
+			key, err := internal.SaveAttachmentFile(attachment.FileName, attachment.ContentType, attachment.Data)
+
+			if err != nil {
+				internal.RemoveAttachmentFiles(keys)
+
+				return err
+			}
+
+			attachments = append(attachments, attachment)
+			keys = append(keys, key)
+		}
+
+		if err := createTask(dc, task, list, attachments, keys); err != nil {
+			internal.RemoveAttachmentFiles(keys)
+
+			return err
+		}
+
+		return nil
+	}
+}
+
+// createTask puts the task in the list named by the #list token, or in the inbox
+// when the profile has no such list.
+func createTask(dc *sql.DB, task models.Task, list string, attachments []Attachment, keys []string) error {
+	tx, err := dc.Begin()
+
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if list != "" {
+		listID, err := db.FindListByName(tx, task.ProfileID, list)
+
+		switch {
+		case err == nil:
+			task.ListID = &listID
+		case !errors.Is(err, sql.ErrNoRows):
+			return err
+		}
+	}
+
+	taskID, err := db.InsertTask(tx, task)
+
+	if err != nil {
+		return err
+	}
+
+	for i, attachment := range attachments {
+		id, err := db.InsertAttachment(tx, models.Attachment{
+			TaskID:      taskID,
+			FileName:    attachment.FileName,
+			ContentType: attachment.ContentType,
+			Size:        int64(len(attachment.Data)),
+			StorageKey:  keys[i],
+		})
+
+		if err != nil {
+			return err
+		}
+
+		if _, err := db.InsertSubTask(tx, models.SubTask{Name: internal.AttachmentURL(id), TaskID: taskID}); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	internal.TrackLinks(dc, internal.TaskURLs(task.Name, task.Metadata))
+
+	return nil
+}
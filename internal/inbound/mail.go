@@ -0,0 +1,161 @@
+package inbound
+
+import (
+	"bytes"
+	"encoding/base64"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"strings"
+)
+
+type Attachment struct {
+	FileName    string
+	ContentType string
+	Data        []byte
+}
+
+// Mail is the part of a received mail that becomes a task.
+type Mail struct {
+	Subject     string
+	Body        string
+	Attachments []Attachment
+}
+
+var decoder = new(mime.WordDecoder)
+
+var htmlTags = regexp.MustCompile(`(?s)<style.*?</style>|<script.*?</script>|<[^>]*>`)
+
+// ParseMail reads the subject, the text body and the attachments of a mail. An
+// HTML body is used with its tags removed when there is no plain text one.
+func ParseMail(data []byte) (*Mail, error) {
+	message, err := mail.ReadMessage(bytes.NewReader(data))
+
+	if err != nil {
+		return nil, err
+	}
+
+	subject, err := decoder.DecodeHeader(message.Header.Get("Subject"))
+
+	if err != nil {
+		subject = message.Header.Get("Subject")
+	}
+
+	parsed := &Mail{Subject: strings.TrimSpace(subject)}
+
+	var htmlBody string
+
+	err = walkPart(message.Header, message.Body, parsed, &htmlBody)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if parsed.Body == "" && htmlBody != "" {
+		parsed.Body = strings.Join(strings.Fields(html.UnescapeString(htmlTags.ReplaceAllString(htmlBody, " "))), " ")
+	}
+
+	parsed.Body = strings.TrimSpace(parsed.Body)
+
+	return parsed, nil
+}
+
+type header interface {
+	Get(key string) string
+}
+
+func walkPart(h header, body io.Reader, parsed *Mail, htmlBody *string) error {
+	mediaType, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+
+		for {
+			part, err := reader.NextPart()
+
+			if err == io.EOF {
+				return nil
+			}
+
+			if err != nil {
+				return err
+			}
+
+			if err := walkPart(part.Header, part, parsed, htmlBody); err != nil {
+				return err
+			}
+		}
+	}
+
+	content, err := io.ReadAll(decodeTransfer(h.Get("Content-Transfer-Encoding"), body))
+
+	if err != nil {
+		return err
+	}
+
+	disposition, dispositionParams, _ := mime.ParseMediaType(h.Get("Content-Disposition"))
+	fileName := dispositionParams["filename"]
+
+	if fileName == "" {
+		fileName = params["name"]
+	}
+
+	if name, err := decoder.DecodeHeader(fileName); err == nil {
+		fileName = name
+	}
+
+	switch {
+	case disposition == "attachment" || fileName != "":
+		if fileName == "" {
+			fileName = "attachment"
+		}
+
+		parsed.Attachments = append(parsed.Attachments, Attachment{FileName: fileName, ContentType: mediaType, Data: content})
+	case mediaType == "text/plain" && parsed.Body == "":
+		parsed.Body = string(content)
+	case mediaType == "text/html" && *htmlBody == "":
+		*htmlBody = string(content)
+	}
+
+	return nil
+}
+
+func decodeTransfer(encoding string, body io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	}
+
+	return body
+}
+
+var replyPrefix = regexp.MustCompile(`(?i)^((re|fw|fwd)\s*:\s*)+`)
+
+// ParseSubject takes the #list and !important tokens out of a subject. Reply and
+// forward prefixes are dropped as well.
+func ParseSubject(subject string) (name string, list string, important bool) {
+	var words []string
+
+	for _, word := range strings.Fields(replyPrefix.ReplaceAllString(strings.TrimSpace(subject), "")) {
+		switch {
+		case len(word) > 1 && word[0] == '#':
+			list = word[1:]
+		case strings.EqualFold(word, "!important"):
+			important = true
+		default:
+			words = append(words, word)
+		}
+	}
+
+	return strings.Join(words, " "), list, important
+}
@@ -0,0 +1,85 @@
+package inbound
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSubject(t *testing.T) {
+	tests := []struct {
+		Subject   string
+		Name      string
+		List      string
+		Important bool
+	}{
+		{"Buy milk", "Buy milk", "", false},
+		{"Fwd: Buy milk #Home !important", "Buy milk", "Home", true},
+		{"RE: Fw: #work Review the #draft", "Review the", "draft", false},
+		{"# !IMPORTANT", "#", "", true},
+	}
+
+	for _, test := range tests {
+		name, list, important := ParseSubject(test.Subject)
+
+		if name != test.Name || list != test.List || important != test.Important {
+			t.Fatalf("%q: got %q %q %v", test.Subject, name, list, important)
+		}
+	}
+}
+
+func TestParseMail(t *testing.T) {
+	message := strings.Join([]string{
+		"From: me@example.com",
+		"Subject: =?UTF-8?B?UGF5IHJlbnQg4oKs?=",
+		"MIME-Version: 1.0",
+		`Content-Type: multipart/mixed; boundary="outer"`,
+		"",
+		"--outer",
+		`Content-Type: multipart/alternative; boundary="inner"`,
+		"",
+		"--inner",
+		"Content-Type: text/plain; charset=utf-8",
+		"Content-Transfer-Encoding: quoted-printable",
+		"",
+		"Due on the 1st=",
+		" of the month",
+		"--inner",
+		"Content-Type: text/html",
+		"",
+		"<p>Due on the 1st</p>",
+		"--inner--",
+		"--outer",
+		"Content-Type: application/pdf",
+		`Content-Disposition: attachment; filename="lease.pdf"`,
+		"Content-Transfer-Encoding: base64",
+		"",
+		"JVBERi0x",
+		"LjQK",
+		"--outer--",
+		"",
+	}, "\r\n")
+
+	mail, err := ParseMail([]byte(message))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mail.Subject != "Pay rent €" {
+		t.Fatalf("unexpected subject %q", mail.Subject)
+	}
+
+	if mail.Body != "Due on the 1st of the month" {
+		t.Fatalf("unexpected body %q", mail.Body)
+	}
+
+	if len(mail.Attachments) != 1 || mail.Attachments[0].FileName != "lease.pdf" || string(mail.Attachments[0].Data) != "%PDF-1.4\n" {
+		t.Fatalf("unexpected attachments %+v", mail.Attachments)
+	}
+
+	html := "Subject: Hi\r\nContent-Type: text/html\r\n\r\n<style>p {}</style><p>Hello&nbsp;<b>there</b></p>"
+
+	if mail, err := ParseMail([]byte(html)); err != nil || mail.Body != "Hello there" {
+		t.Fatalf("unexpected html body %q %v", mail.Body, err)
+	}
+}
@@ -0,0 +1,196 @@
+package inbound
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+const maxRecipients = 100
+
+// Envelope is a mail as it was received.
+type Envelope struct {
+	From string
+	To   []string
+	Data []byte
+}
+
+// Server is a minimal SMTP server that takes mails and hands them to Handler. It
+// does not relay and does not do TLS, so it is meant to sit behind the mail
+// server of the domain or on a private network.
+type Server struct {
+	Domain  string
+	MaxSize int64
+	// AllowSender decides whether mail from the address is taken at all.
+	AllowSender func(from string) bool
+	// AllowRecipient decides whether the address is one of ours. The envelope
+	// sender can be forged, so this is where the gateway checks its secret.
+	AllowRecipient func(to string) bool
+	// Handler gets each mail once it has been received. An error rejects it.
+	Handler func(Envelope) error
+}
+
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+
+	if err != nil {
+		return err
+	}
+
+	return s.Serve(l)
+}
+
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+
+		if err != nil {
+			return err
+		}
+
+		go s.handle(conn)
+	}
+}
+
+// path returns the address of "FROM:<a@b> SIZE=10" style arguments.
+func path(arg string, prefix string) (string, bool) {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", false
+	}
+
+	arg = strings.TrimSpace(arg[len(prefix):])
+	start, end := strings.Index(arg, "<"), strings.Index(arg, ">")
+
+	if start != 0 || end < start {
+		return "", false
+	}
+
+	return arg[start+1 : end], true
+}
+
+func (s *Server) handle(conn net.Conn) {
+	c := textproto.NewConn(conn)
+	defer c.Close()
+
+	reply := func(format string, args ...interface{}) bool {
+		return c.PrintfLine(format, args...) == nil
+	}
+
+	var envelope Envelope
+
+	reply("220 %s ESMTP todo-server", s.Domain)
+
+	for {
+		conn.SetDeadline(time.Now().Add(5 * time.Minute))
+
+		line, err := c.ReadLine()
+
+		if err != nil {
+			return
+		}
+
+		verb, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(verb) {
+		case "HELO":
+			reply("250 %s", s.Domain)
+		case "EHLO":
+			reply("250-%s\r\n250-SIZE %d\r\n250 8BITMIME", s.Domain, s.MaxSize)
+		case "MAIL":
+			from, ok := path(arg, "FROM:")
+
+			if !ok {
+				reply("501 Syntax: MAIL FROM:<address>")
+				continue
+			}
+
+			if s.AllowSender != nil && !s.AllowSender(from) {
+				reply("550 Sender is not allowed")
+				continue
+			}
+
+			envelope = Envelope{From: from}
+			reply("250 OK")
+		case "RCPT":
+			to, ok := path(arg, "TO:")
+
+			switch {
+			case envelope.From == "":
+				reply("503 MAIL first")
+			case !ok:
+				reply("501 Syntax: RCPT TO:<address>")
+			case len(envelope.To) >= maxRecipients:
+				reply("452 Too many recipients")
+			case s.AllowRecipient != nil && !s.AllowRecipient(to):
+				reply("550 No such mailbox")
+			default:
+				envelope.To = append(envelope.To, to)
+				reply("250 OK")
+			}
+		case "DATA":
+			if len(envelope.To) == 0 {
+				reply("503 RCPT first")
+				continue
+			}
+
+			reply("354 End data with <CR><LF>.<CR><LF>")
+
+			data, err := readData(c.DotReader(), s.MaxSize)
+			envelope.Data = data
+
+			switch {
+			case errors.Is(err, errTooLarge):
+				reply("552 Message is too large")
+			case err != nil:
+				return
+			default:
+				if err := s.Handler(envelope); err != nil {
+					log.Printf("Rejected mail from %s: %v", envelope.From, err)
+					reply("554 Mail is rejected")
+				} else {
+					reply("250 OK")
+				}
+			}
+
+			envelope = Envelope{}
+		case "RSET":
+			envelope = Envelope{}
+			reply("250 OK")
+		case "NOOP":
+			reply("250 OK")
+		case "VRFY":
+			reply("252 Cannot verify")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+var errTooLarge = errors.New("message is too large")
+
+// readData reads up to max bytes and drains the rest of a larger message so that
+// the session can go on.
+func readData(r io.Reader, max int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, max+1))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(data)) > max {
+		if _, err := io.Copy(io.Discard, r); err != nil {
+			return nil, err
+		}
+
+		return nil, errTooLarge
+	}
+
+	return data, nil
+}
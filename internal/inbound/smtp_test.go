@@ -0,0 +1,60 @@
+package inbound
+
+import (
+	"net"
+	"net/smtp"
+	"strings"
+	"testing"
+)
+
+func TestServer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	received := make(chan Envelope, 1)
+
+	server := Server{
+		Domain:         "tasks.test",
+		MaxSize:        1024,
+		AllowSender:    func(from string) bool { return from == "me@example.com" },
+		AllowRecipient: func(to string) bool { return to != "nobody@tasks.test" },
+		Handler: func(envelope Envelope) error {
+			received <- envelope
+			return nil
+		},
+	}
+
+	go server.Serve(l)
+
+	message := "Subject: Buy milk #Home\r\n\r\n.leading dot\r\n"
+
+	if err := smtp.SendMail(l.Addr().String(), nil, "me@example.com", []string{"tasks+3@tasks.test"}, []byte(message)); err != nil {
+		t.Fatal(err)
+	}
+
+	envelope := <-received
+
+	if envelope.From != "me@example.com" || len(envelope.To) != 1 || envelope.To[0] != "tasks+3@tasks.test" {
+		t.Fatalf("unexpected envelope %+v", envelope)
+	}
+
+	if !strings.Contains(string(envelope.Data), "\n.leading dot\n") {
+		t.Fatalf("expected the dot to be unstuffed, got %q", envelope.Data)
+	}
+
+	if err := smtp.SendMail(l.Addr().String(), nil, "someone@example.com", []string{"tasks@tasks.test"}, []byte(message)); err == nil {
+		t.Fatal("expected mail from an unknown sender to be rejected")
+	}
+
+	if err := smtp.SendMail(l.Addr().String(), nil, "me@example.com", []string{"nobody@tasks.test"}, []byte(message)); err == nil {
+		t.Fatal("expected mail to an unknown recipient to be rejected")
+	}
+
+	if err := smtp.SendMail(l.Addr().String(), nil, "me@example.com", []string{"tasks@tasks.test"}, []byte(strings.Repeat("x", 2048))); err == nil {
+		t.Fatal("expected a large mail to be rejected")
+	}
+}
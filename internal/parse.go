@@ -2,8 +2,12 @@ package internal
 
 import (
 	"fmt"
+	"html"
+	"net/url"
 	"regexp"
 	"strconv"
+	"strings"
+	"todo-server/models"
 )
 
 func SafeParseSize(str string) int {
@@ -30,14 +34,121 @@ func ParseSize(str string) *int {
 	return &size
 }
 
-func ExtractTitle(input string) (string, error) {
-	re := regexp.MustCompile(`<title>(.*?)</title>`)
+var (
+	titleRe = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	tagRe   = regexp.MustCompile(`(?is)<(meta|link)\s[^>]*>`)
+	attrRe  = regexp.MustCompile(`(?s)([a-zA-Z_:-]+)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+	urlRe   = regexp.MustCompile(`https?://[^\s<>"'()\[\]{}]+`)
+)
 
-	matches := re.FindStringSubmatch(input)
+func ExtractTitle(input string) (string, error) {
+	matches := titleRe.FindStringSubmatch(input)
 
 	if len(matches) > 1 {
-		return matches[1], nil
+		if title := cleanText(matches[1]); title != "" {
+			return title, nil
+		}
 	}
 
 	return "", fmt.Errorf("no title found")
 }
+
+// ExtractLinkPreview reads the title, the Open Graph description and image and
+// the favicon of a page. Relative links are resolved against base, the page URL
+// after redirects.
+func ExtractLinkPreview(input string, base *url.URL) models.LinkPreview {
+	meta := map[string]string{}
+
+	var favicon string
+
+	for _, tag := range tagRe.FindAllStringSubmatch(input, -1) {
+		attrs := map[string]string{}
+
+		for _, attr := range attrRe.FindAllStringSubmatch(tag[0], -1) {
+			attrs[strings.ToLower(attr[1])] = attr[2] + attr[3] + attr[4]
+		}
+
+		if strings.EqualFold(tag[1], "meta") {
+			name := strings.ToLower(attrs["property"])
+
+			if name == "" {
+				name = strings.ToLower(attrs["name"])
+			}
+
+			if _, found := meta[name]; name != "" && !found {
+				meta[name] = cleanText(attrs["content"])
+			}
+
+			continue
+		}
+
+		for _, rel := range strings.Fields(strings.ToLower(attrs["rel"])) {
+			if rel == "icon" && favicon == "" {
+				favicon = attrs["href"]
+			}
+		}
+	}
+
+	title, err := ExtractTitle(input)
+
+	if err != nil {
+		title = meta["og:title"]
+	}
+
+	description := meta["og:description"]
+
+	if description == "" {
+		description = meta["description"]
+	}
+
+	if favicon == "" {
+		favicon = "/favicon.ico"
+	}
+
+	return models.LinkPreview{
+		URL:         base.String(),
+		Title:       title,
+		Description: description,
+		ImageURL:    resolveURL(base, meta["og:image"]),
+		FaviconURL:  resolveURL(base, html.UnescapeString(favicon)),
+	}
+}
+
+// ExtractURLs returns the distinct http(s) URLs in the text, without trailing
+// punctuation.
+func ExtractURLs(input string) []string {
+	var urls []string
+
+	seen := map[string]bool{}
+
+	for _, match := range urlRe.FindAllString(input, -1) {
+		match = strings.TrimRight(match, ".,;:!?*_~")
+
+		if u, err := url.Parse(match); err != nil || u.Host == "" || seen[match] {
+			continue
+		}
+
+		seen[match] = true
+		urls = append(urls, match)
+	}
+
+	return urls
+}
+
+func cleanText(input string) string {
+	return strings.Join(strings.Fields(html.UnescapeString(input)), " ")
+}
+
+func resolveURL(base *url.URL, ref string) string {
+	if ref == "" {
+		return ""
+	}
+
+	u, err := base.Parse(ref)
+
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+
+	return u.String()
+}
@@ -0,0 +1,157 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"todo-server/chrome"
+	"todo-server/db"
+	"todo-server/models"
+)
+
+// Pages are only read up to here, the head is usually in the first few KB.
+const maxPreviewBody = 1 << 20
+
+// Links come from users, so pages are only fetched from public addresses.
+var previewClient = newPreviewClient(publicAddress)
+
+// FetchLinkPreview fetches the page with a plain GET, no browser involved, so
+// titles set by scripts are missed.
+func FetchLinkPreview(ctx context.Context, pageURL string) (models.LinkPreview, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+
+	if err != nil {
+		return models.LinkPreview{}, err
+	}
+
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; todo-server link preview)")
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	res, err := previewClient.Do(req)
+
+	if err != nil {
+		return models.LinkPreview{}, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 400 {
+		return models.LinkPreview{}, fmt.Errorf("fetching %s failed with %s", pageURL, res.Status)
+	}
+
+	if contentType := res.Header.Get("Content-Type"); contentType != "" && !strings.Contains(contentType, "html") {
+		return models.LinkPreview{}, fmt.Errorf("%s is not an HTML page but %s", pageURL, contentType)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxPreviewBody))
+
+	if err != nil {
+		return models.LinkPreview{}, err
+	}
+
+	preview := ExtractLinkPreview(string(body), res.Request.URL)
+	preview.URL = pageURL
+
+	if preview.Title == "" {
+		return preview, fmt.Errorf("no title found on %s", pageURL)
+	}
+
+	return preview, nil
+}
+
+// fetchPage falls back to Chrome, when it is configured, for pages that only get
+// their title from a script.
+func fetchPage(ctx context.Context, pageURL string) (models.LinkPreview, error) {
+	preview, err := FetchLinkPreview(ctx, pageURL)
+
+	if err == nil || !chrome.Available() || ctx.Err() != nil || errors.Is(err, ErrPrivateAddress) {
+		return preview, err
+	}
+
+	// Chrome connects by itself, so every request of the tab is checked instead.
+	title, chromeErr := chrome.GetTitle(ctx, pageURL, checkPublicHost)
+
+	if chromeErr != nil || title == "" {
+		return preview, err
+	}
+
+	return models.LinkPreview{URL: pageURL, Title: title}, nil
+}
+
+// titlePool is shared by the sync job and the previews of new task links, so the
+// bounds hold for both. TITLE_SYNC_WORKERS sets the number of workers, 4 by
+// default.
+var titlePool = &TitlePool{
+	Workers:      titleSyncWorkers(),
+	Timeout:      30 * time.Second,
+	HostInterval: time.Second,
+	Fetch:        fetchPage,
+}
+
+func titleSyncWorkers() int {
+	if workers, err := strconv.Atoi(os.Getenv("TITLE_SYNC_WORKERS")); err == nil && workers > 0 {
+		return workers
+	}
+
+	return 4
+}
+
+// RefreshLinkPreviews fetches the pages and stores their previews. Pages that
+// cannot be fetched are marked invalid.
+func RefreshLinkPreviews(dc *sql.DB, urls []string) {
+	titlePool.Run(urls, func(pageURL string, preview models.LinkPreview, err error) {
+		if err != nil {
+			log.Println("Failed to fetch link preview", err)
+		}
+
+		if err := db.SaveLinkPreview(dc, preview, err == nil); err != nil {
+			log.Println("Failed to save link preview", pageURL, err)
+		}
+	})
+}
+
+// A task with more links than this only gets previews for the first ones.
+const maxLinksPerTask = 10
+
+// TaskURLs returns the URLs in the texts of one task.
+func TaskURLs(texts ...string) []string {
+	var urls []string
+
+	for _, text := range texts {
+		urls = append(urls, ExtractURLs(text)...)
+	}
+
+	if len(urls) > maxLinksPerTask {
+		urls = urls[:maxLinksPerTask]
+	}
+
+	return urls
+}
+
+// TrackLinks records the URLs of created or updated tasks and fetches the
+// previews of new ones in the background. Every path that writes the name or
+// metadata of a task calls it after committing; failures are only logged.
+func TrackLinks(dc *sql.DB, urls []string) {
+	if len(urls) == 0 {
+		return
+	}
+
+	added, err := db.AddURLs(dc, urls)
+
+	if err != nil {
+		log.Println("Failed to save task links", err)
+
+		return
+	}
+
+	if len(added) > 0 {
+		go RefreshLinkPreviews(dc, added)
+	}
+}
@@ -0,0 +1,146 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"reflect"
+	"testing"
+	"todo-server/models"
+)
+
+const previewPage = `<!doctype html>
+<html>
+<head>
+	<TITLE>
+		Fish &amp; Chips
+	</TITLE>
+	<meta property="og:title" content="Not used, there is a title">
+	<meta content='The best in town' property='og:description'>
+	<meta name="description" content="Not used either">
+	<meta property="og:image" content="/images/cover.png?size=large&amp;q=80">
+	<link rel="shortcut icon" href="static/icon.svg">
+</head>
+<body></body>
+</html>`
+
+func TestFetchLinkPreview(t *testing.T) {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/menu/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, previewPage)
+	})
+
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/menu/", http.StatusMovedPermanently)
+	})
+
+	mux.HandleFunc("/plain", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, `<meta property="og:title" content="Only OG">`)
+	})
+
+	mux.HandleFunc("/file.pdf", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		io.WriteString(w, "%PDF-1.4")
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	defaultClient := previewClient
+	defer func() { previewClient = defaultClient }()
+
+	// The test server listens on loopback, which the default client refuses.
+	previewClient = newPreviewClient(func(netip.Addr) bool { return true })
+
+	preview, err := FetchLinkPreview(context.Background(), server.URL+"/old")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := models.LinkPreview{
+		URL:         server.URL + "/old",
+		Title:       "Fish & Chips",
+		Description: "The best in town",
+		ImageURL:    server.URL + "/images/cover.png?size=large&q=80",
+		FaviconURL:  server.URL + "/menu/static/icon.svg",
+	}
+
+	if preview != expected {
+		t.Fatalf("expected %+v, got %+v", expected, preview)
+	}
+
+	preview, err = FetchLinkPreview(context.Background(), server.URL+"/plain")
+
+	if err != nil || preview.Title != "Only OG" || preview.FaviconURL != server.URL+"/favicon.ico" {
+		t.Fatalf("unexpected preview %+v %v", preview, err)
+	}
+
+	if _, err := FetchLinkPreview(context.Background(), server.URL+"/file.pdf"); err == nil {
+		t.Fatal("expected an error for a PDF")
+	}
+
+	if _, err := FetchLinkPreview(context.Background(), server.URL+"/missing"); err == nil {
+		t.Fatal("expected an error for a 404")
+	}
+}
+
+func TestPreviewPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, previewPage)
+	}))
+	defer server.Close()
+
+	if _, err := FetchLinkPreview(context.Background(), server.URL); !errors.Is(err, ErrPrivateAddress) {
+		t.Fatalf("expected %v, got %v", ErrPrivateAddress, err)
+	}
+
+	tests := map[string]bool{
+		"93.184.216.34":        true,
+		"2606:4700::1111":      true,
+		"127.0.0.1":            false,
+		"10.1.2.3":             false,
+		"172.16.0.1":           false,
+		"192.168.1.1":          false,
+		"169.254.169.254":      false,
+		"100.64.0.1":           false,
+		"0.0.0.0":              false,
+		"::1":                  false,
+		"fd00::1":              false,
+		"fe80::1":              false,
+		"::ffff:127.0.0.1":     false,
+		"64:ff9b::a00:1":       false,
+		"::ffff:93.184.216.34": true,
+	}
+
+	for addr, public := range tests {
+		if publicAddress(netip.MustParseAddr(addr)) != public {
+			t.Errorf("expected %s public to be %v", addr, public)
+		}
+	}
+}
+
+func TestExtractURLs(t *testing.T) {
+	tests := []struct {
+		Input string
+		URLs  []string
+	}{
+		{"Read https://go.dev/doc/effective_go.", []string{"https://go.dev/doc/effective_go"}},
+		{"[docs](https://example.com/a?b=c#d), http://example.org and https://example.com/a?b=c#d", []string{"https://example.com/a?b=c#d", "http://example.org"}},
+		{"ftp://example.com and https:// alone", nil},
+		{"no links here", nil},
+	}
+
+	for _, test := range tests {
+		if urls := ExtractURLs(test.Input); !reflect.DeepEqual(urls, test.URLs) {
+			t.Errorf("%q: expected %v, got %v", test.Input, test.URLs, urls)
+		}
+	}
+}
@@ -6,6 +6,14 @@ import (
 	"time"
 )
 
+// BlockedCondition is true for a task (aliased t) that has a blocker which is
+// neither completed nor in the trash.
+const BlockedCondition = `EXISTS (
+	SELECT 1 FROM task_dependencies d
+	JOIN tasks b ON b.id = d.blocker_id
+	WHERE d.task_id = t.id AND b.completed = false AND b.deleted_at IS NULL
+)`
+
 func GetTasksQuery(
 	filter string,
 	searchTerm string,
@@ -14,6 +22,8 @@ func GetTasksQuery(
 	listID *int,
 	showAllTasks string,
 	profileId *int,
+	sortBy string,
+	priority string,
 ) (string, []interface{}) {
 	var query string
 	var args []interface{}
@@ -37,26 +47,36 @@ func GetTasksQuery(
 			t.created_at,
 			t.marked_today,
 			t.is_important,
+			t.priority,
 			t.due_date,
 			t.metadata,
 			t.list_id AS list_id, 
 			t.profile_id AS profile_id,
+			t.position,
+			t.my_day_position,
+			` + BlockedCondition + ` AS blocked,
 			COALESCE(t.recurrence_pattern::TEXT, '') AS recurrence_pattern, 
 			COALESCE(COUNT(CASE WHEN st.completed = false THEN 1 END), 0) AS incomplete_subtask_count,
 			COALESCE(COUNT(st.id), 0) AS subtask_count
 		FROM 
 			tasks t
 		LEFT JOIN 
-			sub_tasks st ON st.task_id = t.id
+			sub_tasks st ON st.task_id = t.id AND st.deleted_at IS NULL
 	`
 
+	// The select clause has a WHERE of its own in the blocked subquery.
+	hasWhere := func() bool {
+		return strings.Contains(query[len(selectClause):], "WHERE")
+	}
+
 	switch filter {
 	case "":
 		query = selectClause
 	case "my-day":
 		today := time.Now().Format("2006-01-02")
 		query = selectClause + `
-		WHERE ((marked_today != '' AND DATE(marked_today) = $1) OR (due_date != '' AND DATE(due_date) = $1)) `
+		WHERE ((marked_today != '' AND DATE(marked_today) = $1) OR (due_date != '' AND DATE(due_date) = $1))
+		`
 		args = append(args, today)
 	case "important":
 		query = selectClause + `
@@ -67,7 +87,7 @@ func GetTasksQuery(
 	}
 
 	if profileId != nil {
-		if strings.Contains(query, "WHERE") {
+		if hasWhere() {
 			query += " AND"
 		} else {
 			query += " WHERE"
@@ -75,7 +95,7 @@ func GetTasksQuery(
 		query += fmt.Sprintf(" t.profile_id = $%d", len(args)+1)
 		args = append(args, *profileId)
 	} else {
-		if strings.Contains(query, "WHERE") {
+		if hasWhere() {
 			query += " AND"
 		} else {
 			query += " WHERE"
@@ -83,8 +103,10 @@ func GetTasksQuery(
 		query += " t.profile_id IS NULL"
 	}
 
+	query += " AND t.deleted_at IS NULL"
+
 	if showCompleted == "false" {
-		if strings.Contains(query, "WHERE") {
+		if hasWhere() {
 			query += " AND"
 		} else {
 			query += " WHERE"
@@ -92,8 +114,13 @@ func GetTasksQuery(
 		query += completedFilter
 	}
 
+	if priority != "" {
+		query += fmt.Sprintf(" AND t.priority = $%d", len(args)+1)
+		args = append(args, priority)
+	}
+
 	if searchTerm != "" {
-		if strings.Contains(query, "WHERE") {
+		if hasWhere() {
 			query += " AND"
 		} else {
 			query += " WHERE"
@@ -104,7 +131,7 @@ func GetTasksQuery(
 
 	if listID == nil {
 		if filter != "important" && filter != "my-day" && showAllTasks != "true" {
-			if strings.Contains(query, "WHERE") {
+			if hasWhere() {
 				query += " AND"
 			} else {
 				query += " WHERE"
@@ -112,7 +139,7 @@ func GetTasksQuery(
 			query += " t.list_id IS NULL "
 		}
 	} else {
-		if strings.Contains(query, "WHERE") {
+		if hasWhere() {
 			query += " AND"
 		} else {
 			query += " WHERE"
@@ -122,7 +149,17 @@ func GetTasksQuery(
 	}
 
 	query += " GROUP BY t.id "
-	query += " ORDER BY t.created_at DESC"
+
+	switch {
+	case sortBy == "position" && filter == "my-day":
+		query += ` ORDER BY NULLIF(t.my_day_position, '') COLLATE "C" ASC NULLS FIRST, t.created_at DESC`
+	case sortBy == "position":
+		query += ` ORDER BY NULLIF(t.position, '') COLLATE "C" ASC NULLS FIRST, t.created_at DESC`
+	case sortBy == "priority":
+		query += " ORDER BY t.priority DESC, t.created_at DESC"
+	default:
+		query += " ORDER BY t.created_at DESC"
+	}
 
 	if size > 0 {
 		query += fmt.Sprintf(" LIMIT $%d ", len(args)+1)
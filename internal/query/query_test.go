@@ -1,7 +1,7 @@
 package query
 
 import (
-	"log"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -10,46 +10,127 @@ import (
 func TestGetTastsQueryDefault(t *testing.T) {
 	today := time.Now().Format("2006-01-02")
 
+	myDay := "where ((marked_today != '' and date(marked_today) = $1) or (due_date != '' and date(due_date) = $1))"
+	important := "where t.is_important = true"
+	inbox := "t.list_id is null"
+
 	tests := []struct {
-		Inputs []string
-		Query  string
-		Args   []interface{}
+		Inputs    []string
+		Fragments []string
+		Absent    []string
+		Args      []interface{}
 	}{
-		{Inputs: []string{"", "", ""}, Query: "select * from tasks order by created_at desc", Args: []interface{}{}},
-		{Inputs: []string{"", "search", ""}, Query: "select * from tasks where name ilike '%' || $1 || '%' order by created_at desc", Args: []interface{}{"search"}},
+		{Inputs: []string{"", "", ""}, Fragments: []string{"where t.profile_id is null", inbox}, Absent: []string{"and t.completed = false", "ilike"}, Args: nil},
+		{Inputs: []string{"", "search", ""}, Fragments: []string{"t.name ilike '%' || $1 || '%'", inbox}, Args: []interface{}{"search"}},
 
-		{Inputs: []string{"", "", "true"}, Query: "select * from tasks order by created_at desc", Args: []interface{}{}},
-		{Inputs: []string{"", "", "false"}, Query: "select * from tasks where completed = false  order by created_at desc", Args: []interface{}{}},
-		{Inputs: []string{"", "search", "true"}, Query: "select * from tasks where name ilike '%' || $1 || '%' order by created_at desc", Args: []interface{}{"search"}},
-		{Inputs: []string{"", "search", "false"}, Query: "select * from tasks where completed = false  and name ilike '%' || $1 || '%' order by created_at desc", Args: []interface{}{"search"}},
+		{Inputs: []string{"", "", "true"}, Fragments: []string{inbox}, Absent: []string{"and t.completed = false"}, Args: nil},
+		{Inputs: []string{"", "", "false"}, Fragments: []string{"and t.completed = false", inbox}, Args: nil},
+		{Inputs: []string{"", "search", "true"}, Fragments: []string{"t.name ilike '%' || $1 || '%'"}, Absent: []string{"and t.completed = false"}, Args: []interface{}{"search"}},
+		{Inputs: []string{"", "search", "false"}, Fragments: []string{"and t.completed = false", "t.name ilike '%' || $1 || '%'"}, Args: []interface{}{"search"}},
 
-		{Inputs: []string{"my-day", "", "true"}, Query: "select * from tasks where ((marked_today != '' and date(marked_today) = $1) or (due_date != '' and date(due_date) = $1))  order by created_at desc", Args: []interface{}{today}},
-		{Inputs: []string{"my-day", "", ""}, Query: "select * from tasks where ((marked_today != '' and date(marked_today) = $1) or (due_date != '' and date(due_date) = $1))  order by created_at desc", Args: []interface{}{today}},
-		{Inputs: []string{"my-day", "", "false"}, Query: "select * from tasks where ((marked_today != '' and date(marked_today) = $1) or (due_date != '' and date(due_date) = $1))  and completed = false  order by created_at desc", Args: []interface{}{today}},
-		{Inputs: []string{"my-day", "search", "true"}, Query: "select * from tasks where ((marked_today != '' and date(marked_today) = $1) or (due_date != '' and date(due_date) = $1))  and name ilike '%' || $2 || '%' order by created_at desc", Args: []interface{}{today, "search"}},
-		{Inputs: []string{"my-day", "search", ""}, Query: "select * from tasks where ((marked_today != '' and date(marked_today) = $1) or (due_date != '' and date(due_date) = $1))  and name ilike '%' || $2 || '%' order by created_at desc", Args: []interface{}{today, "search"}},
-		{Inputs: []string{"my-day", "search", "false"}, Query: "select * from tasks where ((marked_today != '' and date(marked_today) = $1) or (due_date != '' and date(due_date) = $1))  and completed = false  and name ilike '%' || $2 || '%' order by created_at desc", Args: []interface{}{today, "search"}},
+		{Inputs: []string{"my-day", "", "true"}, Fragments: []string{myDay}, Absent: []string{"and t.completed = false", inbox}, Args: []interface{}{today}},
+		{Inputs: []string{"my-day", "", ""}, Fragments: []string{myDay}, Absent: []string{inbox}, Args: []interface{}{today}},
+		{Inputs: []string{"my-day", "", "false"}, Fragments: []string{myDay, "and t.completed = false"}, Args: []interface{}{today}},
+		{Inputs: []string{"my-day", "search", "true"}, Fragments: []string{myDay, "t.name ilike '%' || $2 || '%'"}, Args: []interface{}{today, "search"}},
+		{Inputs: []string{"my-day", "search", ""}, Fragments: []string{myDay, "t.name ilike '%' || $2 || '%'"}, Args: []interface{}{today, "search"}},
+		{Inputs: []string{"my-day", "search", "false"}, Fragments: []string{myDay, "and t.completed = false", "t.name ilike '%' || $2 || '%'"}, Args: []interface{}{today, "search"}},
 
-		{Inputs: []string{"important", "", ""}, Query: "select * from tasks where is_important = true order by created_at desc", Args: []interface{}{}},
-		{Inputs: []string{"important", "", "false"}, Query: "select * from tasks where is_important = true and completed = false  order by created_at desc", Args: []interface{}{}},
-		{Inputs: []string{"important", "", "true"}, Query: "select * from tasks where is_important = true order by created_at desc", Args: []interface{}{}},
-		{Inputs: []string{"important", "search", ""}, Query: "select * from tasks where is_important = true and name ilike '%' || $1 || '%' order by created_at desc", Args: []interface{}{"search"}},
-		{Inputs: []string{"important", "search", "false"}, Query: "select * from tasks where is_important = true and completed = false  and name ilike '%' || $1 || '%' order by created_at desc", Args: []interface{}{"search"}},
-		{Inputs: []string{"important", "search", "true"}, Query: "select * from tasks where is_important = true and name ilike '%' || $1 || '%' order by created_at desc", Args: []interface{}{"search"}},
+		{Inputs: []string{"important", "", ""}, Fragments: []string{important}, Absent: []string{inbox}, Args: nil},
+		{Inputs: []string{"important", "", "false"}, Fragments: []string{important, "and t.completed = false"}, Args: nil},
+		{Inputs: []string{"important", "", "true"}, Fragments: []string{important}, Absent: []string{"and t.completed = false"}, Args: nil},
+		{Inputs: []string{"important", "search", ""}, Fragments: []string{important, "t.name ilike '%' || $1 || '%'"}, Args: []interface{}{"search"}},
+		{Inputs: []string{"important", "search", "false"}, Fragments: []string{important, "and t.completed = false", "t.name ilike '%' || $1 || '%'"}, Args: []interface{}{"search"}},
+		{Inputs: []string{"important", "search", "true"}, Fragments: []string{important, "t.name ilike '%' || $1 || '%'"}, Args: []interface{}{"search"}},
 	}
 
 	for _, curr := range tests {
-		result, args := GetTasksQuery(curr.Inputs[0], curr.Inputs[1], curr.Inputs[2], 0, 0, "")
+		result, args := GetTasksQuery(curr.Inputs[0], curr.Inputs[1], curr.Inputs[2], 0, nil, "", nil, "", "")
+		result = strings.ToLower(result)
+
+		if !reflect.DeepEqual(args, curr.Args) {
+			t.Fatalf("%v: expected args %v, got %v", curr.Inputs, curr.Args, args)
+		}
+
+		for _, fragment := range curr.Fragments {
+			if !strings.Contains(result, fragment) {
+				t.Fatalf("%v: expected %q in %q", curr.Inputs, fragment, result)
+			}
+		}
 
-		for i := range args {
-			if args[i] != curr.Args[i] {
-				log.Fatal(curr.Query, args)
+		for _, fragment := range curr.Absent {
+			if strings.Contains(result, fragment) {
+				t.Fatalf("%v: did not expect %q in %q", curr.Inputs, fragment, result)
 			}
 		}
 
-		if strings.ToLower(result) != curr.Query {
-			log.Fatal(curr.Query, args)
+		if !strings.HasSuffix(result, "order by t.created_at desc") {
+			t.Fatalf("%v: expected the newest tasks first, got %q", curr.Inputs, result)
 		}
 	}
+}
+
+func TestGetTasksQueryListAndProfile(t *testing.T) {
+	listID := 3
+	profileID := 7
+
+	result, args := GetTasksQuery("", "", "", 20, &listID, "", &profileID, "", "")
+
+	for _, fragment := range []string{"t.profile_id = $1", "t.deleted_at IS NULL", "t.list_id = $2", "LIMIT $3"} {
+		if !strings.Contains(result, fragment) {
+			t.Fatalf("expected %q in %q", fragment, result)
+		}
+	}
+
+	if !reflect.DeepEqual(args, []interface{}{profileID, listID, 20}) {
+		t.Fatalf("unexpected args %v", args)
+	}
+}
+
+func TestGetTasksQuerySortByPosition(t *testing.T) {
+	result, _ := GetTasksQuery("", "", "", 0, nil, "", nil, "position", "")
+
+	if !strings.Contains(result, `ORDER BY NULLIF(t.position, '') COLLATE "C" ASC NULLS FIRST, t.created_at DESC`) {
+		t.Fatalf("expected the list order, got %q", result)
+	}
+
+	result, _ = GetTasksQuery("my-day", "", "", 0, nil, "", nil, "position", "")
 
+	if !strings.Contains(result, `ORDER BY NULLIF(t.my_day_position, '') COLLATE "C" ASC NULLS FIRST`) {
+		t.Fatalf("expected the my day order, got %q", result)
+	}
+}
+
+func TestGetTasksQueryPriority(t *testing.T) {
+	result, args := GetTasksQuery("", "search", "", 0, nil, "", nil, "priority", "high")
+
+	if !strings.Contains(result, "t.priority = $1") || !strings.Contains(result, "t.name ILIKE '%' || $2 || '%'") {
+		t.Fatalf("expected the priority filter before the search, got %q", result)
+	}
+
+	if !reflect.DeepEqual(args, []interface{}{"high", "search"}) {
+		t.Fatalf("unexpected args %v", args)
+	}
+
+	if !strings.HasSuffix(result, "ORDER BY t.priority DESC, t.created_at DESC") {
+		t.Fatalf("expected the most urgent tasks first, got %q", result)
+	}
+}
+
+func TestGetTasksQueryBlocked(t *testing.T) {
+	result, _ := GetTasksQuery("", "", "", 0, nil, "", nil, "", "")
+
+	if !strings.Contains(result, BlockedCondition+" AS blocked") {
+		t.Fatalf("expected the blocked flag, got %q", result)
+	}
+
+	if strings.Contains(result, "NOT "+BlockedCondition) {
+		t.Fatalf("did not expect blocked tasks to be hidden, got %q", result)
+	}
+
+	// Blocked tasks stay in my day with their flag, only the email leaves them out.
+	result, _ = GetTasksQuery("my-day", "", "", 0, nil, "", nil, "", "")
+
+	if strings.Contains(result, "NOT "+BlockedCondition) {
+		t.Fatalf("did not expect blocked tasks to be hidden from my day, got %q", result)
+	}
 }
@@ -0,0 +1,483 @@
+// Package quickadd reads the due date, list, priority and recurrence out of the
+// text of a task, e.g. "Pay rent every month on the 1st #Home !important".
+package quickadd
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"todo-server/models"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "sun": time.Sunday,
+	"monday": time.Monday, "mon": time.Monday,
+	"tuesday": time.Tuesday, "tue": time.Tuesday, "tues": time.Tuesday,
+	"wednesday": time.Wednesday, "wed": time.Wednesday,
+	"thursday": time.Thursday, "thu": time.Thursday, "thurs": time.Thursday,
+	"friday": time.Friday, "fri": time.Friday,
+	"saturday": time.Saturday, "sat": time.Saturday,
+}
+
+var months = map[string]time.Month{
+	"january": time.January, "jan": time.January,
+	"february": time.February, "feb": time.February,
+	"march": time.March, "mar": time.March,
+	"april": time.April, "apr": time.April,
+	"may":  time.May,
+	"june": time.June, "jun": time.June,
+	"july": time.July, "jul": time.July,
+	"august": time.August, "aug": time.August,
+	"september": time.September, "sep": time.September, "sept": time.September,
+	"october": time.October, "oct": time.October,
+	"november": time.November, "nov": time.November,
+	"december": time.December, "dec": time.December,
+}
+
+// units maps the words of a period to a recurrence pattern.
+var units = map[string]string{
+	"day": "daily", "days": "daily",
+	"week": "weekly", "weeks": "weekly",
+	"month": "monthly", "months": "monthly",
+	"year": "yearly", "years": "yearly",
+}
+
+var adverbs = map[string]string{
+	"daily": "daily", "weekly": "weekly", "monthly": "monthly", "yearly": "yearly", "annually": "yearly",
+}
+
+var priorities = map[string]string{
+	"!important": "high", "!low": "low", "!medium": "medium", "!high": "high", "!urgent": "urgent",
+}
+
+var datePreps = map[string]bool{"on": true, "by": true, "due": true}
+
+var (
+	ordinal = regexp.MustCompile(`^(\d{1,2})(st|nd|rd|th)?$`)
+	clock   = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?(am|pm)?$`)
+	isoDate = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+)
+
+const trailing = ",.;"
+
+type datePhrase struct {
+	text string
+	date time.Time
+}
+
+type parser struct {
+	now    time.Time
+	words  []string
+	result models.QuickAdd
+	// due is set by date for the phrase that is being read.
+	due *time.Time
+	// anchor is the day a recurrence is on, like "every monday" or "every month
+	// on the 1st". It takes precedence over the other dates.
+	anchor *datePhrase
+	dates  []datePhrase
+}
+
+// Parse takes the recognised phrases out of text. What is left is the name of
+// the task. Dates are relative to now. The day of a recurrence is the due date;
+// otherwise the last date is. Dates that are left out because of that are named
+// in Conflict.
+func Parse(text string, now time.Time) models.QuickAdd {
+	p := parser{now: now, words: strings.Fields(text)}
+
+	var name []string
+
+	for i := 0; i < len(p.words); {
+		if used := p.match(i); used > 0 {
+			i += used
+			continue
+		}
+
+		name = append(name, p.words[i])
+		i++
+	}
+
+	p.result.Name = strings.Join(name, " ")
+
+	due := p.anchor
+
+	if due == nil && len(p.dates) > 0 {
+		due = &p.dates[len(p.dates)-1]
+	}
+
+	if due == nil && p.result.RecurrencePattern != "" {
+		due = &datePhrase{date: p.today()}
+	}
+
+	if due == nil {
+		return p.result
+	}
+
+	p.result.DueDate = due.date.Format("2006-01-02")
+
+	var left []string
+
+	for _, phrase := range p.dates {
+		if !phrase.date.Equal(due.date) {
+			left = append(left, fmt.Sprintf("%q", phrase.text))
+		}
+	}
+
+	if len(left) > 0 {
+		p.result.Conflict = fmt.Sprintf("%s left out, the task is due %s", strings.Join(left, ", "), p.result.DueDate)
+	}
+
+	return p.result
+}
+
+// phrase is the text of the used words from i on, without trailing punctuation.
+func (p *parser) phrase(i int, used int) string {
+	return strings.TrimRight(strings.Join(p.words[i:i+used], " "), trailing)
+}
+
+// addDate records the date that date just read from the words at i.
+func (p *parser) addDate(i int, used int) int {
+	p.dates = append(p.dates, datePhrase{text: p.phrase(i, used), date: *p.due})
+	p.due = nil
+
+	return used
+}
+
+func (p *parser) today() time.Time {
+	return time.Date(p.now.Year(), p.now.Month(), p.now.Day(), 0, 0, 0, 0, p.now.Location())
+}
+
+// word is the lower cased word at i without trailing punctuation, or "".
+func (p *parser) word(i int) string {
+	if i >= len(p.words) {
+		return ""
+	}
+
+	return strings.TrimRight(strings.ToLower(p.words[i]), trailing)
+}
+
+func (p *parser) match(i int) int {
+	word := p.word(i)
+
+	if len(word) > 1 && word[0] == '#' {
+		p.result.List = strings.TrimRight(p.words[i][1:], trailing)
+		return 1
+	}
+
+	if priority, found := priorities[word]; found {
+		p.result.Priority = priority
+		p.result.IsImportant = priority == "high" || priority == "urgent"
+		return 1
+	}
+
+	if used := p.recurrence(i); used > 0 {
+		return used
+	}
+
+	if used := p.date(i, false); used > 0 {
+		return p.addDate(i, used)
+	}
+
+	if datePreps[word] {
+		if used := p.date(i+1, true); used > 0 {
+			return p.addDate(i, used+1)
+		}
+	}
+
+	if word == "at" {
+		if used := p.time(i+1, true); used > 0 {
+			return used + 1
+		}
+	}
+
+	return p.time(i, false)
+}
+
+// recognised reports whether a phrase starts at i, without taking it.
+func (p *parser) recognised(i int) bool {
+	scratch := *p
+	scratch.dates = append([]datePhrase{}, p.dates...)
+
+	return scratch.match(i) > 0
+}
+
+func (p *parser) recurrence(i int) int {
+	word := p.word(i)
+
+	// "daily" only counts at the end or before another phrase, otherwise "Review
+	// the daily report" would recur.
+	if pattern, found := adverbs[word]; found {
+		if i+1 < len(p.words) && !p.recognised(i+1) {
+			return 0
+		}
+
+		p.result.RecurrencePattern = pattern
+		p.result.RecurrenceInterval = 1
+		return 1 + p.recurrenceDay(i+1)
+	}
+
+	if word != "every" {
+		return 0
+	}
+
+	interval, used := 1, 1
+
+	if next := p.word(i + 1); next == "other" {
+		interval, used = 2, 2
+	} else if n, err := strconv.Atoi(next); err == nil && n > 0 {
+		interval, used = n, 2
+	}
+
+	unit := p.word(i + used)
+
+	if pattern, found := units[unit]; found {
+		p.result.RecurrencePattern = pattern
+		p.result.RecurrenceInterval = interval
+		return used + 1 + p.recurrenceDay(i+used+1)
+	}
+
+	if weekday, found := weekdays[unit]; found {
+		p.result.RecurrencePattern = "weekly"
+		p.result.RecurrenceInterval = interval
+		p.anchor = &datePhrase{text: p.phrase(i, used+1), date: p.nextWeekday(weekday, false)}
+		return used + 1
+	}
+
+	return 0
+}
+
+// recurrenceDay reads the day a recurrence is on, like "on the 1st" right after
+// "every month".
+func (p *parser) recurrenceDay(i int) int {
+	if p.word(i) != "on" {
+		return 0
+	}
+
+	used := p.date(i+1, true)
+
+	if used == 0 {
+		return 0
+	}
+
+	p.anchor = &datePhrase{text: p.phrase(i, used+1), date: *p.due}
+	p.due = nil
+
+	return used + 1
+}
+
+func (p *parser) setDue(due time.Time) {
+	p.due = &due
+}
+
+// nextWeekday is the next such day, today included unless skipToday.
+func (p *parser) nextWeekday(weekday time.Weekday, skipToday bool) time.Time {
+	days := (int(weekday) - int(p.now.Weekday()) + 7) % 7
+
+	if days == 0 && skipToday {
+		days = 7
+	}
+
+	return p.today().AddDate(0, 0, days)
+}
+
+// nextDay is the next date on the day of the month, today included.
+func (p *parser) nextDay(day int) time.Time {
+	today := p.today()
+
+	for months := 0; months < 12; months++ {
+		date := time.Date(today.Year(), today.Month()+time.Month(months), day, 0, 0, 0, 0, today.Location())
+
+		// Short months are skipped rather than rolled over.
+		if date.Day() == day && !date.Before(today) {
+			return date
+		}
+	}
+
+	return today
+}
+
+// nextDate is the next date on the day and month, today included.
+func (p *parser) nextDate(month time.Month, day int) (time.Time, bool) {
+	today := p.today()
+
+	for year := today.Year(); year <= today.Year()+4; year++ {
+		date := time.Date(year, month, day, 0, 0, 0, 0, today.Location())
+
+		if date.Month() != month {
+			if day > 29 {
+				return time.Time{}, false
+			}
+
+			continue
+		}
+
+		if !date.Before(today) {
+			return date, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+func dayOfMonth(word string) (int, bool) {
+	match := ordinal.FindStringSubmatch(word)
+
+	if match == nil {
+		return 0, false
+	}
+
+	day, _ := strconv.Atoi(match[1])
+
+	return day, day >= 1 && day <= 31
+}
+
+// date reads a date. A bare day of the month ("15th") or weekday only counts
+// after "on", "by" or "due", otherwise "Read the 5th chapter" or "Buy sun cream"
+// would get a date.
+func (p *parser) date(i int, afterPrep bool) int {
+	word := p.word(i)
+
+	switch word {
+	case "today", "tonight":
+		p.setDue(p.today())
+		return 1
+	case "tomorrow", "tmrw":
+		p.setDue(p.today().AddDate(0, 0, 1))
+		return 1
+	case "next":
+		if weekday, found := weekdays[p.word(i+1)]; found {
+			p.setDue(p.nextWeekday(weekday, true))
+			return 2
+		}
+
+		switch p.word(i + 1) {
+		case "week":
+			p.setDue(p.today().AddDate(0, 0, 7))
+			return 2
+		case "month":
+			p.setDue(p.today().AddDate(0, 1, 0))
+			return 2
+		}
+
+		return 0
+	case "in":
+		n, err := strconv.Atoi(p.word(i + 1))
+
+		if err != nil || n < 0 {
+			return 0
+		}
+
+		switch units[p.word(i+2)] {
+		case "daily":
+			p.setDue(p.today().AddDate(0, 0, n))
+		case "weekly":
+			p.setDue(p.today().AddDate(0, 0, 7*n))
+		case "monthly":
+			p.setDue(p.today().AddDate(0, n, 0))
+		case "yearly":
+			p.setDue(p.today().AddDate(n, 0, 0))
+		default:
+			return 0
+		}
+
+		return 3
+	case "the":
+		// Only "on the 1st", a bare "the 1st" is more likely part of the name.
+		if day, ok := dayOfMonth(p.word(i + 1)); ok && afterPrep && !isNumber(p.word(i+1)) {
+			p.setDue(p.nextDay(day))
+			return 2
+		}
+
+		return 0
+	}
+
+	if weekday, found := weekdays[word]; found && afterPrep {
+		p.setDue(p.nextWeekday(weekday, false))
+		return 1
+	}
+
+	if isoDate.MatchString(word) {
+		if date, err := time.ParseInLocation("2006-01-02", word, p.now.Location()); err == nil {
+			p.setDue(date)
+			return 1
+		}
+	}
+
+	if month, found := months[word]; found {
+		if day, ok := dayOfMonth(p.word(i + 1)); ok {
+			if date, ok := p.nextDate(month, day); ok {
+				p.setDue(date)
+				return 2
+			}
+		}
+	}
+
+	if day, ok := dayOfMonth(word); ok {
+		if month, found := months[p.word(i+1)]; found {
+			if date, ok := p.nextDate(month, day); ok {
+				p.setDue(date)
+				return 2
+			}
+		}
+
+		if afterPrep && !isNumber(word) {
+			p.setDue(p.nextDay(day))
+			return 1
+		}
+	}
+
+	return 0
+}
+
+func isNumber(word string) bool {
+	_, err := strconv.Atoi(word)
+
+	return err == nil
+}
+
+// time reads a time of day. A bare number only counts after "at".
+func (p *parser) time(i int, afterAt bool) int {
+	word := p.word(i)
+
+	switch word {
+	case "noon":
+		p.result.DueTime = "12:00"
+		return 1
+	case "midnight":
+		p.result.DueTime = "00:00"
+		return 1
+	}
+
+	match := clock.FindStringSubmatch(word)
+
+	if match == nil || (!afterAt && match[2] == "" && match[3] == "") {
+		return 0
+	}
+
+	hour, _ := strconv.Atoi(match[1])
+	minute, _ := strconv.Atoi(match[2])
+
+	if match[3] != "" && (hour < 1 || hour > 12) {
+		return 0
+	}
+
+	switch match[3] {
+	case "am":
+		if hour == 12 {
+			hour = 0
+		}
+	case "pm":
+		if hour < 12 {
+			hour += 12
+		}
+	}
+
+	if hour > 23 || minute > 59 {
+		return 0
+	}
+
+	p.result.DueTime = time.Date(0, 1, 1, hour, minute, 0, 0, time.UTC).Format("15:04")
+
+	return 1
+}
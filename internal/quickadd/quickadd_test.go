@@ -0,0 +1,62 @@
+package quickadd
+
+import (
+	"testing"
+	"time"
+	"todo-server/models"
+)
+
+func TestParse(t *testing.T) {
+	// A Wednesday.
+	now := time.Date(2024, time.May, 15, 18, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		Text   string
+		Parsed models.QuickAdd
+	}{
+		{"Buy milk", models.QuickAdd{Name: "Buy milk"}},
+		{
+			"Pay rent every month on the 1st #Home !important tomorrow 9am",
+			models.QuickAdd{Name: "Pay rent", DueDate: "2024-06-01", DueTime: "09:00", List: "Home", IsImportant: true, Priority: "high", RecurrencePattern: "monthly", RecurrenceInterval: 1, Conflict: `"tomorrow" left out, the task is due 2024-06-01`},
+		},
+		{"Pay rent monthly on the 1st", models.QuickAdd{Name: "Pay rent", DueDate: "2024-06-01", RecurrencePattern: "monthly", RecurrenceInterval: 1}},
+		{"Gym every week on friday", models.QuickAdd{Name: "Gym", DueDate: "2024-05-17", RecurrencePattern: "weekly", RecurrenceInterval: 1}},
+		{"Standup tomorrow every monday", models.QuickAdd{Name: "Standup", DueDate: "2024-05-20", RecurrencePattern: "weekly", RecurrenceInterval: 1, Conflict: `"tomorrow" left out, the task is due 2024-05-20`}},
+		{"Call mom tomorrow, by friday", models.QuickAdd{Name: "Call mom", DueDate: "2024-05-17", Conflict: `"tomorrow" left out, the task is due 2024-05-17`}},
+		{"Call mom on thursday tomorrow", models.QuickAdd{Name: "Call mom", DueDate: "2024-05-16"}},
+		{
+			"Pay rent every month on the 1st",
+			models.QuickAdd{Name: "Pay rent", DueDate: "2024-06-01", RecurrencePattern: "monthly", RecurrenceInterval: 1},
+		},
+		{"Standup every monday at 10:15", models.QuickAdd{Name: "Standup", DueDate: "2024-05-20", DueTime: "10:15", RecurrencePattern: "weekly", RecurrenceInterval: 1}},
+		{"Water plants every 3 days", models.QuickAdd{Name: "Water plants", DueDate: "2024-05-15", RecurrencePattern: "daily", RecurrenceInterval: 3}},
+		{"Backups every other week", models.QuickAdd{Name: "Backups", DueDate: "2024-05-15", RecurrencePattern: "weekly", RecurrenceInterval: 2}},
+		{"Call mom next wednesday !urgent", models.QuickAdd{Name: "Call mom", DueDate: "2024-05-22", IsImportant: true, Priority: "urgent"}},
+		{"Call mom on wednesday", models.QuickAdd{Name: "Call mom", DueDate: "2024-05-15"}},
+		{"Buy sun cream", models.QuickAdd{Name: "Buy sun cream"}},
+		{"Call mom wednesday", models.QuickAdd{Name: "Call mom wednesday"}},
+		{"Review the daily report", models.QuickAdd{Name: "Review the daily report"}},
+		{"Take vitamins daily", models.QuickAdd{Name: "Take vitamins", DueDate: "2024-05-15", RecurrencePattern: "daily", RecurrenceInterval: 1}},
+		{"Water plants weekly #Home", models.QuickAdd{Name: "Water plants", DueDate: "2024-05-15", List: "Home", RecurrencePattern: "weekly", RecurrenceInterval: 1}},
+		{"Renew passport in 2 weeks", models.QuickAdd{Name: "Renew passport", DueDate: "2024-05-29"}},
+		{"Birthday party jan 5th at 7pm", models.QuickAdd{Name: "Birthday party", DueDate: "2025-01-05", DueTime: "19:00"}},
+		{"Submit taxes by 2024-07-31, #Admin", models.QuickAdd{Name: "Submit taxes", DueDate: "2024-07-31", List: "Admin"}},
+		{"Invoice due 31st", models.QuickAdd{Name: "Invoice", DueDate: "2024-05-31"}},
+		{"Read the 5th chapter at home", models.QuickAdd{Name: "Read the 5th chapter at home"}},
+		{"Lunch at noon today !low", models.QuickAdd{Name: "Lunch", DueDate: "2024-05-15", DueTime: "12:00", Priority: "low"}},
+	}
+
+	for _, test := range tests {
+		if parsed := Parse(test.Text, now); parsed != test.Parsed {
+			t.Errorf("%q:\nexpected %+v\ngot      %+v", test.Text, test.Parsed, parsed)
+		}
+	}
+}
+
+func TestNextDaySkipsShortMonths(t *testing.T) {
+	now := time.Date(2024, time.February, 10, 0, 0, 0, 0, time.UTC)
+
+	if parsed := Parse("Report on the 31st", now); parsed.DueDate != "2024-03-31" {
+		t.Fatalf("expected 2024-03-31, got %q", parsed.DueDate)
+	}
+}
@@ -0,0 +1,120 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Ranks are base 62 strings compared byte by byte (COLLATE "C" in Postgres). A
+// new rank can always be generated between two others, so moving a task only
+// updates the moved task.
+const rankDigits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// RankBetween returns a rank that sorts after before and ahead of after. An empty
+// before means the start of the list and an empty after means the end.
+func RankBetween(before string, after string) (string, error) {
+	for _, rank := range []string{before, after} {
+		if strings.HasSuffix(rank, "0") {
+			return "", fmt.Errorf("invalid rank %q", rank)
+		}
+
+		for i := 0; i < len(rank); i++ {
+			if strings.IndexByte(rankDigits, rank[i]) < 0 {
+				return "", fmt.Errorf("invalid rank %q", rank)
+			}
+		}
+	}
+
+	if before != "" && after != "" && before >= after {
+		return "", fmt.Errorf("rank %q is not before %q", before, after)
+	}
+
+	return midpoint(before, after), nil
+}
+
+// RankSequence returns n evenly spaced, increasing ranks. It is used to give
+// positions to tasks that have never been moved.
+func RankSequence(n int) []string {
+	width := 1
+
+	for capacity := len(rankDigits); capacity <= n; capacity *= len(rankDigits) {
+		width++
+	}
+
+	capacity := 1
+
+	for i := 0; i < width; i++ {
+		capacity *= len(rankDigits)
+	}
+
+	step := capacity / (n + 1)
+
+	ranks := make([]string, n)
+
+	for i := range ranks {
+		value := (i + 1) * step
+		digits := make([]byte, width)
+
+		for j := width - 1; j >= 0; j-- {
+			digits[j] = rankDigits[value%len(rankDigits)]
+			value /= len(rankDigits)
+		}
+
+		// Ranks must not end with the smallest digit, otherwise nothing fits in front of them.
+		ranks[i] = string(digits) + "V"
+	}
+
+	return ranks
+}
+
+func midpoint(a string, b string) string {
+	if b != "" {
+		n := 0
+
+		for n < len(b) && rankDigitAt(a, n) == b[n] {
+			n++
+		}
+
+		if n > 0 {
+			return b[:n] + midpoint(rankSuffix(a, n), b[n:])
+		}
+	}
+
+	low := 0
+
+	if a != "" {
+		low = strings.IndexByte(rankDigits, a[0])
+	}
+
+	high := len(rankDigits)
+
+	if b != "" {
+		high = strings.IndexByte(rankDigits, b[0])
+	}
+
+	if high-low > 1 {
+		return string(rankDigits[(low+high)/2])
+	}
+
+	if len(b) > 1 {
+		return b[:1]
+	}
+
+	return string(rankDigits[low]) + midpoint(rankSuffix(a, 1), "")
+}
+
+func rankDigitAt(rank string, i int) byte {
+	if i < len(rank) {
+		return rank[i]
+	}
+
+	return rankDigits[0]
+}
+
+func rankSuffix(rank string, i int) string {
+	if i < len(rank) {
+		return rank[i:]
+	}
+
+	return ""
+}
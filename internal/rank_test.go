@@ -0,0 +1,97 @@
+package internal
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestRankBetween(t *testing.T) {
+	tests := []struct {
+		Before string
+		After  string
+	}{
+		{"", ""},
+		{"", "V"},
+		{"V", ""},
+		{"V", "W"},
+		{"V", "V1"},
+		{"z", ""},
+		{"", "01"},
+		{"a", "a0V"},
+		{"0V", "1"},
+	}
+
+	for _, test := range tests {
+		rank, err := RankBetween(test.Before, test.After)
+
+		if err != nil {
+			t.Fatalf("%q, %q: %v", test.Before, test.After, err)
+		}
+
+		if rank <= test.Before || (test.After != "" && rank >= test.After) {
+			t.Fatalf("%q is not between %q and %q", rank, test.Before, test.After)
+		}
+	}
+}
+
+func TestRankBetweenInvalid(t *testing.T) {
+	for _, test := range [][2]string{{"b", "a"}, {"a", "a"}, {"a0", ""}, {"", "a-b"}} {
+		if _, err := RankBetween(test[0], test[1]); err == nil {
+			t.Fatalf("expected an error for %q, %q", test[0], test[1])
+		}
+	}
+}
+
+func TestRankBetweenRepeatedInserts(t *testing.T) {
+	ranks := []string{}
+	r := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 500; i++ {
+		at := r.Intn(len(ranks) + 1)
+
+		before, after := "", ""
+
+		if at > 0 {
+			before = ranks[at-1]
+		}
+
+		if at < len(ranks) {
+			after = ranks[at]
+		}
+
+		rank, err := RankBetween(before, after)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ranks = append(ranks[:at], append([]string{rank}, ranks[at:]...)...)
+
+		if !sort.StringsAreSorted(ranks) {
+			t.Fatalf("ranks are out of order after inserting %q between %q and %q", rank, before, after)
+		}
+	}
+}
+
+func TestRankSequence(t *testing.T) {
+	for _, n := range []int{0, 1, 61, 62, 5000} {
+		ranks := RankSequence(n)
+
+		if len(ranks) != n || !sort.StringsAreSorted(ranks) {
+			t.Fatalf("expected %d sorted ranks", n)
+		}
+
+		for i := 1; i < len(ranks); i++ {
+			if ranks[i] == ranks[i-1] {
+				t.Fatalf("duplicate rank %q", ranks[i])
+			}
+		}
+
+		if n > 0 {
+			if _, err := RankBetween("", ranks[0]); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
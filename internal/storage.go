@@ -0,0 +1,73 @@
+package internal
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Storage keeps the content of attachments. Get returns an error matching
+// fs.ErrNotExist for unknown keys, and deleting an unknown key is not an error.
+type Storage interface {
+	Put(key string, data []byte, contentType string) error
+	Get(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
+
+// LocalStorage keeps the files in a directory on disk.
+type LocalStorage struct {
+	Dir string
+}
+
+func (s LocalStorage) path(key string) string {
+	return filepath.Join(s.Dir, filepath.Base(key))
+}
+
+func (s LocalStorage) Put(key string, data []byte, contentType string) error {
+	if err := os.MkdirAll(s.Dir, 0o750); err != nil {
+		return err
+	}
+
+	return os.WriteFile(s.path(key), data, 0o640)
+}
+
+// Get returns an *os.File, so callers can seek in it.
+func (s LocalStorage) Get(key string) (io.ReadCloser, error) {
+	return os.Open(s.path(key))
+}
+
+func (s LocalStorage) Delete(key string) error {
+	if err := os.Remove(s.path(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+var (
+	attachmentStorage     Storage
+	attachmentStorageOnce sync.Once
+)
+
+// AttachmentStorage is picked once by ATTACHMENTS_STORAGE, "local" (the default)
+// or "s3". See NewS3StorageFromEnv for the S3 settings.
+func AttachmentStorage() Storage {
+	attachmentStorageOnce.Do(func() {
+		switch os.Getenv("ATTACHMENTS_STORAGE") {
+		case "s3":
+			attachmentStorage = NewS3StorageFromEnv()
+		case "", "local":
+			attachmentStorage = LocalStorage{Dir: AttachmentsDir()}
+		default:
+			log.Printf("Unknown ATTACHMENTS_STORAGE %q, keeping attachments in %s\n", os.Getenv("ATTACHMENTS_STORAGE"), AttachmentsDir())
+
+			attachmentStorage = LocalStorage{Dir: AttachmentsDir()}
+		}
+	})
+
+	return attachmentStorage
+}
@@ -0,0 +1,54 @@
+package internal
+
+import "todo-server/models"
+
+// SubTaskTree nests the flat sub tasks of a task under their parents, keeping the
+// order they were given in. Sub tasks whose parent is missing (it is in the
+// trash) are left out along with their children.
+func SubTaskTree(subTasks []models.SubTask) []models.SubTask {
+	children := map[int][]models.SubTask{}
+
+	var roots []models.SubTask
+
+	for _, subTask := range subTasks {
+		if subTask.ParentID == nil {
+			roots = append(roots, subTask)
+		} else {
+			children[*subTask.ParentID] = append(children[*subTask.ParentID], subTask)
+		}
+	}
+
+	var attach func(nodes []models.SubTask) []models.SubTask
+
+	attach = func(nodes []models.SubTask) []models.SubTask {
+		for i := range nodes {
+			nodes[i].SubTasks = attach(children[nodes[i].ID])
+			nodes[i].Progress = Progress(nodes[i].Completed, nodes[i].SubTasks)
+		}
+
+		return nodes
+	}
+
+	return attach(roots)
+}
+
+// Progress is the percentage done of an item with the given children. A
+// completed item is done regardless of its children, otherwise every child
+// weighs the same and contributes its own progress.
+func Progress(completed bool, subTasks []models.SubTask) int {
+	if completed {
+		return 100
+	}
+
+	if len(subTasks) == 0 {
+		return 0
+	}
+
+	total := 0
+
+	for _, subTask := range subTasks {
+		total += Progress(subTask.Completed, subTask.SubTasks)
+	}
+
+	return total / len(subTasks)
+}
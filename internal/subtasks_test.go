@@ -0,0 +1,61 @@
+package internal
+
+import (
+	"testing"
+	"todo-server/models"
+)
+
+func TestSubTaskTree(t *testing.T) {
+	parent := func(id int) *int { return &id }
+
+	subTasks := []models.SubTask{
+		{ID: 1, Name: "one"},
+		{ID: 2, Name: "two", Completed: true},
+		{ID: 3, Name: "one.a", ParentID: parent(1), Completed: true},
+		{ID: 4, Name: "one.b", ParentID: parent(1)},
+		{ID: 5, Name: "one.b.x", ParentID: parent(4), Completed: true},
+		{ID: 6, Name: "orphan", ParentID: parent(99)},
+	}
+
+	tree := SubTaskTree(subTasks)
+
+	if len(tree) != 2 || tree[0].ID != 1 || tree[1].ID != 2 {
+		t.Fatalf("unexpected roots %+v", tree)
+	}
+
+	one := tree[0]
+
+	if len(one.SubTasks) != 2 || one.SubTasks[1].SubTasks[0].ID != 5 {
+		t.Fatalf("unexpected children %+v", one.SubTasks)
+	}
+
+	// one.b has its only child done, one.a is done, so one is done as well.
+	if one.SubTasks[1].Progress != 100 || one.Progress != 100 {
+		t.Fatalf("expected one to be done, got %d", one.Progress)
+	}
+
+	if progress := Progress(false, tree); progress != 100 {
+		t.Fatalf("expected 100, got %d", progress)
+	}
+}
+
+func TestProgress(t *testing.T) {
+	tests := []struct {
+		Completed bool
+		SubTasks  []models.SubTask
+		Progress  int
+	}{
+		{false, nil, 0},
+		{true, nil, 100},
+		{true, []models.SubTask{{}}, 100},
+		{false, []models.SubTask{{Completed: true}, {}}, 50},
+		{false, []models.SubTask{{Completed: true}, {}, {}}, 33},
+		{false, []models.SubTask{{SubTasks: []models.SubTask{{Completed: true}, {}}}, {}}, 25},
+	}
+
+	for i, test := range tests {
+		if progress := Progress(test.Completed, test.SubTasks); progress != test.Progress {
+			t.Fatalf("%d: expected %d, got %d", i, test.Progress, progress)
+		}
+	}
+}
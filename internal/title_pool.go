@@ -0,0 +1,111 @@
+package internal
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+	"todo-server/models"
+)
+
+// TitlePool fetches pages with at most Workers fetches at a time, also across
+// concurrent runs. Requests to the same host are spaced by HostInterval, and each
+// fetch is cancelled after Timeout.
+type TitlePool struct {
+	Workers      int
+	Timeout      time.Duration
+	HostInterval time.Duration
+	Fetch        func(ctx context.Context, pageURL string) (models.LinkPreview, error)
+
+	mu       sync.Mutex
+	nextSlot map[string]time.Time
+	slots    chan struct{}
+}
+
+// Run fetches all the URLs and returns once they are done. done is called from
+// the workers, so it has to be safe for concurrent use.
+func (p *TitlePool) Run(urls []string, done func(pageURL string, preview models.LinkPreview, err error)) {
+	jobs := make(chan string)
+
+	workers := max(p.Workers, 1)
+
+	p.mu.Lock()
+
+	if p.slots == nil {
+		p.slots = make(chan struct{}, workers)
+	}
+
+	p.mu.Unlock()
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for pageURL := range jobs {
+				time.Sleep(p.reserve(pageURL))
+
+				p.slots <- struct{}{}
+
+				ctx, cancel := context.WithTimeout(context.Background(), p.Timeout)
+				preview, err := p.Fetch(ctx, pageURL)
+				cancel()
+
+				<-p.slots
+
+				preview.URL = pageURL
+
+				done(pageURL, preview, err)
+			}
+		}()
+	}
+
+	for _, pageURL := range urls {
+		jobs <- pageURL
+	}
+
+	close(jobs)
+	wg.Wait()
+}
+
+// reserve takes the next free slot of the host of the URL and returns how long to
+// wait for it.
+func (p *TitlePool) reserve(pageURL string) time.Duration {
+	host := pageURL
+
+	if u, err := url.Parse(pageURL); err == nil {
+		host = u.Hostname()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.nextSlot == nil {
+		p.nextSlot = map[string]time.Time{}
+	}
+
+	now := time.Now()
+
+	// Hosts without pending requests are forgotten, the pool lives as long as the
+	// server.
+	if len(p.nextSlot) > 1000 {
+		for h, slot := range p.nextSlot {
+			if slot.Before(now) {
+				delete(p.nextSlot, h)
+			}
+		}
+	}
+
+	slot := p.nextSlot[host]
+
+	if slot.Before(now) {
+		slot = now
+	}
+
+	p.nextSlot[host] = slot.Add(p.HostInterval)
+
+	return slot.Sub(now)
+}
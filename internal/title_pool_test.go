@@ -0,0 +1,102 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+	"todo-server/models"
+)
+
+func TestTitlePool(t *testing.T) {
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	started := map[string][]time.Time{}
+
+	pool := &TitlePool{
+		Workers:      3,
+		Timeout:      time.Second,
+		HostInterval: 50 * time.Millisecond,
+		Fetch: func(ctx context.Context, pageURL string) (models.LinkPreview, error) {
+			mu.Lock()
+			running++
+			maxRunning = max(maxRunning, running)
+			host := pageURL[:len("https://a.test")]
+			started[host] = append(started[host], time.Now())
+			mu.Unlock()
+
+			time.Sleep(10 * time.Millisecond)
+
+			mu.Lock()
+			running--
+			mu.Unlock()
+
+			return models.LinkPreview{Title: "Page " + pageURL}, nil
+		},
+	}
+
+	var urls []string
+
+	for i := 0; i < 4; i++ {
+		for _, host := range []string{"a", "b", "c"} {
+			urls = append(urls, fmt.Sprintf("https://%s.test/%d", host, i))
+		}
+	}
+
+	var done []string
+
+	pool.Run(urls, func(pageURL string, preview models.LinkPreview, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if err != nil || preview.URL != pageURL || preview.Title != "Page "+pageURL {
+			t.Errorf("unexpected result for %s: %+v %v", pageURL, preview, err)
+		}
+
+		done = append(done, pageURL)
+	})
+
+	if len(done) != len(urls) {
+		t.Fatalf("expected %d results, got %d", len(urls), len(done))
+	}
+
+	if maxRunning > 3 {
+		t.Fatalf("expected at most 3 fetches at a time, got %d", maxRunning)
+	}
+
+	for host, times := range started {
+		sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+
+		for i := 1; i < len(times); i++ {
+			if gap := times[i].Sub(times[i-1]); gap < 45*time.Millisecond {
+				t.Fatalf("requests to %s only %s apart", host, gap)
+			}
+		}
+	}
+}
+
+func TestTitlePoolTimeout(t *testing.T) {
+	pool := &TitlePool{
+		Workers: 1,
+		Timeout: 20 * time.Millisecond,
+		Fetch: func(ctx context.Context, pageURL string) (models.LinkPreview, error) {
+			<-ctx.Done()
+
+			return models.LinkPreview{}, ctx.Err()
+		},
+	}
+
+	start := time.Now()
+
+	pool.Run([]string{"https://a.test/1", "https://b.test/1"}, func(pageURL string, preview models.LinkPreview, err error) {
+		if err != context.DeadlineExceeded {
+			t.Errorf("expected a timeout for %s, got %v", pageURL, err)
+		}
+	})
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("fetches were not cancelled, took %s", elapsed)
+	}
+}
@@ -0,0 +1,35 @@
+package internal
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"os"
+)
+
+// GenerateToken returns a random hex token suitable for secret URLs.
+func GenerateToken() (string, error) {
+	bytes := make([]byte, 32)
+
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(bytes), nil
+}
+
+// PublicBaseURL is the URL the server is reachable at. PUBLIC_URL is used when set,
+// otherwise it is derived from the request.
+func PublicBaseURL(r *http.Request) string {
+	if publicURL := os.Getenv("PUBLIC_URL"); publicURL != "" {
+		return publicURL
+	}
+
+	scheme := "http"
+
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+
+	return scheme + "://" + r.Host
+}
@@ -0,0 +1,81 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// MaxWebhookAttempts is how many times a delivery is tried before it is given up.
+const MaxWebhookAttempts = 8
+
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// SignWebhook is the value of the X-Todo-Signature header: the hex HMAC-SHA256 of
+// the body keyed with the secret of the webhook.
+func SignWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookBackoff is the wait after the given failed attempt: 30 seconds doubling
+// up to 1 hour.
+func WebhookBackoff(attempt int) time.Duration {
+	backoff := 30 * time.Second
+
+	for i := 1; i < attempt && backoff < time.Hour; i++ {
+		backoff *= 2
+	}
+
+	return min(backoff, time.Hour)
+}
+
+// DeliverWebhook posts one event. Anything but a 2xx answer is an error; the
+// status is returned as well when there was an answer.
+func DeliverWebhook(url string, secret string, deliveryID int, event string, data json.RawMessage) (int, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"id":    deliveryID,
+		"event": event,
+		"data":  data,
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "todo-server-webhooks")
+	req.Header.Set("X-Todo-Event", event)
+	req.Header.Set("X-Todo-Delivery", strconv.Itoa(deliveryID))
+	req.Header.Set("X-Todo-Signature", SignWebhook(secret, body))
+
+	res, err := webhookClient.Do(req)
+
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("webhook answered %s", res.Status)
+	}
+
+	return res.StatusCode, nil
+}
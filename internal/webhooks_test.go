@@ -0,0 +1,72 @@
+package internal
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDeliverWebhook(t *testing.T) {
+	var received struct {
+		Event string          `json:"event"`
+		Data  json.RawMessage `json:"data"`
+	}
+
+	fail := false
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		if r.Header.Get("X-Todo-Signature") != SignWebhook("secret", body) {
+			t.Errorf("invalid signature %q", r.Header.Get("X-Todo-Signature"))
+		}
+
+		if r.Header.Get("X-Todo-Delivery") != "7" || r.Header.Get("X-Todo-Event") != "task.completed" {
+			t.Errorf("unexpected headers %v", r.Header)
+		}
+
+		json.Unmarshal(body, &received)
+
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	status, err := DeliverWebhook(server.URL, "secret", 7, "task.completed", json.RawMessage(`{"id":1}`))
+
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("expected delivery to succeed, got %d %v", status, err)
+	}
+
+	if received.Event != "task.completed" || string(received.Data) != `{"id":1}` {
+		t.Fatalf("unexpected body %+v", received)
+	}
+
+	fail = true
+
+	if status, err := DeliverWebhook(server.URL, "secret", 7, "task.completed", nil); err == nil || status != http.StatusServiceUnavailable {
+		t.Fatalf("expected delivery to fail, got %d %v", status, err)
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	tests := []struct {
+		Attempt int
+		Backoff time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{4, 4 * time.Minute},
+		{20, time.Hour},
+	}
+
+	for _, test := range tests {
+		if backoff := WebhookBackoff(test.Attempt); backoff != test.Backoff {
+			t.Fatalf("%d: expected %v, got %v", test.Attempt, test.Backoff, backoff)
+		}
+	}
+}
@@ -1,28 +1,169 @@
 package models
 
 import (
+	"encoding/json"
 	"fmt"
 	"time"
 )
 
 type Task struct {
-	ID                     int       `json:"id"`
-	Name                   string    `json:"name" validate:"required,min=3,max=1000"`
-	Completed              bool      `json:"completed"`
-	CompletedOn            string    `json:"completed_on"`
-	CreatedAt              string    `json:"created_at"`
-	IsImportant            bool      `json:"is_important"`
-	MarkedToday            string    `json:"marked_today"`
-	DueDate                string    `json:"due_date"`
-	Metadata               string    `json:"metadata"`
-	SubTasks               []SubTask `json:"sub_tasks"`
-	InCompleteSubTaskCount int       `json:"incomplete_subtask_count"`
-	SubTaskCount           int       `json:"subtask_count"`
-	StartDate              string    `json:"start_date"`
-	RecurrencePattern      string    `json:"recurrence_pattern"`
-	RecurrenceInterval     int       `json:"recurrence_interval"`
-	ListID                 *int      `json:"list_id"`
-	ProfileID              *int      `json:"profile_id"`
+	ID                     int           `json:"id"`
+	Name                   string        `json:"name" validate:"required,min=3,max=1000"`
+	Completed              bool          `json:"completed"`
+	CompletedOn            string        `json:"completed_on"`
+	CreatedAt              string        `json:"created_at"`
+	IsImportant            bool          `json:"is_important"`
+	Priority               string        `json:"priority" validate:"omitempty,oneof=none low medium high urgent"`
+	MarkedToday            string        `json:"marked_today"`
+	DueDate                string        `json:"due_date"`
+	Metadata               string        `json:"metadata"`
+	SubTasks               []SubTask     `json:"sub_tasks"`
+	InCompleteSubTaskCount int           `json:"incomplete_subtask_count"`
+	SubTaskCount           int           `json:"subtask_count"`
+	Progress               int           `json:"progress"`
+	StartDate              string        `json:"start_date"`
+	RecurrencePattern      string        `json:"recurrence_pattern" validate:"omitempty,oneof=daily weekly monthly yearly"`
+	RecurrenceInterval     int           `json:"recurrence_interval" validate:"min=0"`
+	ListID                 *int          `json:"list_id"`
+	ProfileID              *int          `json:"profile_id"`
+	Position               string        `json:"position"`
+	MyDayPosition          string        `json:"my_day_position"`
+	Blocked                bool          `json:"blocked"`
+	Revision               int64         `json:"revision"`
+	Links                  []LinkPreview `json:"links,omitempty"`
+}
+
+type MoveTask struct {
+	BeforeID *int   `json:"before_id"`
+	AfterID  *int   `json:"after_id"`
+	View     string `json:"view" validate:"omitempty,oneof=list my-day"`
+}
+
+// BulkTasks applies one operation to the tasks given by IDs or, when no IDs are
+// given, to the tasks matching Filter (the same parameters as the tasks listing).
+type BulkTasks struct {
+	IDs       []int       `json:"ids" validate:"max=1000"`
+	Filter    *TaskFilter `json:"filter"`
+	Operation string      `json:"operation" validate:"required,oneof=complete delete move set-due-date mark-important add-to-my-day"`
+	// ListID is the target of move; null moves the tasks to the inbox.
+	ListID  *int   `json:"list_id"`
+	DueDate string `json:"due_date"`
+	// Value is used by complete, mark-important and add-to-my-day. false undoes
+	// them, it defaults to true.
+	Value *bool `json:"value"`
+}
+
+type TaskFilter struct {
+	Filter        string `json:"filter"`
+	Query         string `json:"query"`
+	ShowCompleted string `json:"show_completed"`
+	ListID        *int   `json:"list_id"`
+	ShowAllTasks  string `json:"show_all_tasks"`
+	ProfileID     *int   `json:"profile_id"`
+	Priority      string `json:"priority" validate:"omitempty,oneof=none low medium high urgent"`
+}
+
+type BulkResult struct {
+	ID     int    `json:"id"`
+	Status string `json:"status"`
+}
+
+type BulkResponse struct {
+	Operation string       `json:"operation"`
+	Updated   int          `json:"updated"`
+	Results   []BulkResult `json:"results"`
+}
+
+// BatchRef points at a row either by its ID (a number) or by the client_id of an
+// earlier operation of the same batch (a string).
+type BatchRef struct {
+	ID       *int
+	ClientID string
+}
+
+func (ref *BatchRef) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*ref = BatchRef{}
+		return nil
+	}
+
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &ref.ClientID)
+	}
+
+	return json.Unmarshal(data, &ref.ID)
+}
+
+func (ref BatchRef) IsSet() bool {
+	return ref.ID != nil || ref.ClientID != ""
+}
+
+type BatchOperation struct {
+	Op string `json:"op" validate:"required,oneof=create-list update-list create-task update-task toggle-task delete-task create-sub-task update-sub-task toggle-sub-task delete-sub-task"`
+	// ClientID names the row created by a create operation for later operations.
+	ClientID string   `json:"client_id"`
+	ID       BatchRef `json:"id"`
+	ListID   BatchRef `json:"list_id"`
+	TaskID   BatchRef `json:"task_id"`
+	ParentID BatchRef `json:"parent_id"`
+	// Data is the body the single endpoint of the operation takes.
+	Data json.RawMessage `json:"data"`
+}
+
+type BatchRequest struct {
+	Operations []BatchOperation `json:"operations" validate:"required,min=1,max=500,dive"`
+}
+
+type BatchResult struct {
+	Index int    `json:"index"`
+	Op    string `json:"op"`
+	ID    int    `json:"id"`
+}
+
+type BatchResponse struct {
+	IDs     map[string]int `json:"ids"`
+	Results []BatchResult  `json:"results"`
+}
+
+// SyncChanges are the rows of one table that changed after the sync token. Rows
+// are sent as they are stored, including their revision.
+type SyncChanges struct {
+	Created []json.RawMessage `json:"created"`
+	Updated []json.RawMessage `json:"updated"`
+	Deleted []int             `json:"deleted"`
+}
+
+type SyncResponse struct {
+	// Token is passed as since on the next sync.
+	Token    string      `json:"token"`
+	Profiles SyncChanges `json:"profiles"`
+	Lists    SyncChanges `json:"lists"`
+	Tasks    SyncChanges `json:"tasks"`
+	SubTasks SyncChanges `json:"sub_tasks"`
+}
+
+// Event is pushed to connected clients when a row changes. Action is created,
+// updated or deleted; resync tells the client that events may have been missed
+// and it should catch up through the sync endpoint.
+type Event struct {
+	Table     string `json:"table"`
+	Action    string `json:"action"`
+	ID        int    `json:"id"`
+	ProfileID *int   `json:"profile_id"`
+	Revision  int64  `json:"revision"`
+}
+
+type TaskBlocker struct {
+	BlockerID int `json:"blocker_id" validate:"required"`
+}
+
+type TaskPriority struct {
+	Priority string `json:"priority" validate:"required,oneof=none low medium high urgent"`
+}
+
+type TaskPosition struct {
+	ID       int    `json:"id"`
+	Position string `json:"position"`
 }
 
 type GetListID struct {
@@ -36,11 +177,24 @@ type RecurringTask struct {
 }
 
 type SubTask struct {
-	ID        int       `json:"id"`
-	Name      string    `json:"name" validate:"required,min=3,max=1000"`
-	Completed bool      `json:"completed"`
-	CreatedAt time.Time `json:"created_at"`
-	TaskID    int       `json:"task_id"` // TODO: this is required
+	ID          int       `json:"id"`
+	Name        string    `json:"name" validate:"required,min=3,max=1000"`
+	Completed   bool      `json:"completed"`
+	CreatedAt   time.Time `json:"created_at"`
+	TaskID      int       `json:"task_id"` // TODO: this is required
+	ParentID    *int      `json:"parent_id"`
+	DueDate     string    `json:"due_date"`
+	IsImportant bool      `json:"is_important"`
+	Progress    int       `json:"progress"`
+	SubTasks    []SubTask `json:"sub_tasks,omitempty"`
+	Revision    int64     `json:"revision"`
+}
+
+// Demote turns a task into a sub task of another task, optionally under one of
+// its sub tasks.
+type Demote struct {
+	TaskID   int  `json:"task_id" validate:"required"`
+	ParentID *int `json:"parent_id"`
 }
 
 type Response struct {
@@ -83,6 +237,12 @@ type ErrorResponseV2 struct {
 	Error         string         `json:"error"`
 }
 
+// ConflictResponse is sent with 412 when If-Match does not match the row anymore.
+type ConflictResponse struct {
+	ErrorResponseV2
+	Current json.RawMessage `json:"current"`
+}
+
 type InvalidField struct {
 	ErrorMessage string `json:"error_message"`
 	Field        string `json:"field"`
@@ -95,12 +255,73 @@ type List struct {
 	CreatedAt  string `json:"created_at"`
 	TasksCount int    `json:"tasks_count"`
 	ProfileID  *int   `json:"profile_id"`
+	GroupID    *int   `json:"group_id"`
+	Position   int    `json:"position"`
+	// AutoCompleteParent overrides the profile setting when it is set.
+	AutoCompleteParent *bool `json:"auto_complete_parent"`
+	Revision           int64 `json:"revision"`
 }
 
-type Profile struct {
+type ListGroup struct {
 	ID        int    `json:"id"`
-	Name      string `json:"name" validate:"required,min=3,max=50"`
+	Name      string `json:"name" validate:"required,min=3,max=1000"`
+	Position  int    `json:"position"`
+	ProfileID *int   `json:"profile_id"`
 	CreatedAt string `json:"created_at"`
+	Lists     []List `json:"lists"`
+}
+
+// ListsTree is the lists response when the groups are requested. Lists that are
+// not in any group are returned at the top level.
+type ListsTree struct {
+	Groups []ListGroup `json:"groups"`
+	Lists  []List      `json:"lists"`
+}
+
+type MoveList struct {
+	GroupID  *int `json:"group_id"`
+	Position int  `json:"position" validate:"min=0"`
+}
+
+// DeleteListResponse tells how the tasks of a deleted list were handled.
+type DeleteListResponse struct {
+	Message      string `json:"message"`
+	MovedTasks   int64  `json:"moved_tasks"`
+	DeletedTasks int64  `json:"deleted_tasks"`
+}
+
+type ReorderPayload struct {
+	IDs []int `json:"ids" validate:"required,min=1"`
+}
+
+type Profile struct {
+	ID                 int    `json:"id"`
+	Name               string `json:"name" validate:"required,min=3,max=50"`
+	CreatedAt          string `json:"created_at"`
+	AutoCompleteParent bool   `json:"auto_complete_parent"`
+}
+
+// CompletionRule is the auto complete setting of a list or a profile. A list can
+// clear its own setting with null to follow its profile again.
+type CompletionRule struct {
+	AutoCompleteParent *bool `json:"auto_complete_parent"`
+}
+
+type ToggleSubTaskResponse struct {
+	Message         string `json:"message"`
+	Completed       bool   `json:"completed"`
+	ParentCompleted bool   `json:"parent_completed"`
+}
+
+// TrashItem is a deleted task, sub task, list or profile waiting to be restored
+// or purged.
+type TrashItem struct {
+	Type      string `json:"type"`
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	ListID    *int   `json:"list_id"`
+	ProfileID *int   `json:"profile_id"`
+	DeletedAt string `json:"deleted_at"`
 }
 
 func (e *EmailTemplate) GetMessage() (msg []byte) {
@@ -122,6 +343,16 @@ type URLTitle struct {
 	UpdatedAt string `json:"updated_at"`
 }
 
+// LinkPreview describes a URL found in the name or metadata of a task. Only the
+// URL is set until the page has been fetched.
+type LinkPreview struct {
+	URL         string `json:"url"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	ImageURL    string `json:"image_url"`
+	FaviconURL  string `json:"favicon_url"`
+}
+
 type Log struct {
 	ID        int    `json:"id"`
 	Log       string `json:"log" validate:"required,min=3,max=1000"`
@@ -133,3 +364,190 @@ type Log struct {
 type LogPayload struct {
 	Data []Log `json:"data"`
 }
+
+type ChangeLogEntry struct {
+	ID        int64           `json:"id"`
+	TableName string          `json:"table_name"`
+	Operation string          `json:"operation"`
+	RowID     int             `json:"row_id"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type BackupFile struct {
+	Kind         string                     `json:"kind"`
+	FromChangeID int64                      `json:"from_change_id"`
+	ToChangeID   int64                      `json:"to_change_id"`
+	CreatedAt    time.Time                  `json:"created_at"`
+	Tables       map[string]json.RawMessage `json:"tables,omitempty"`
+	Changes      []ChangeLogEntry           `json:"changes,omitempty"`
+}
+
+type ImportedSubTask struct {
+	Name      string `json:"name"`
+	Completed bool   `json:"completed"`
+}
+
+type ImportedTask struct {
+	Name               string            `json:"name"`
+	Completed          bool              `json:"completed"`
+	CompletedOn        string            `json:"completed_on"`
+	IsImportant        bool              `json:"is_important"`
+	DueDate            string            `json:"due_date"`
+	Metadata           string            `json:"metadata"`
+	RecurrencePattern  string            `json:"recurrence_pattern"`
+	RecurrenceInterval int               `json:"recurrence_interval"`
+	SubTasks           []ImportedSubTask `json:"sub_tasks"`
+}
+
+// ImportedList groups imported tasks by the list they belong to. An empty Name
+// means the tasks go to the inbox (no list).
+type ImportedList struct {
+	Name     string         `json:"name"`
+	ListID   *int           `json:"list_id"`
+	Existing bool           `json:"existing"`
+	Tasks    []ImportedTask `json:"tasks"`
+}
+
+type ImportSummary struct {
+	DryRun          bool           `json:"dry_run"`
+	ListsCreated    int            `json:"lists_created"`
+	TasksCreated    int            `json:"tasks_created"`
+	SubTasksCreated int            `json:"sub_tasks_created"`
+	Lists           []ImportedList `json:"lists"`
+}
+
+type CalendarFeed struct {
+	ID        int    `json:"id"`
+	Token     string `json:"token"`
+	URL       string `json:"url"`
+	ProfileID *int   `json:"profile_id"`
+	ListID    *int   `json:"list_id"`
+	CreatedAt string `json:"created_at"`
+}
+
+// Webhook is a subscription to task and list events. The secret is only
+// returned when the webhook is created. Without a profile it gets the events of
+// the rows without a profile, unless AllProfiles is set.
+type Webhook struct {
+	ID          int      `json:"id"`
+	URL         string   `json:"url" validate:"required,url,startswith=http"`
+	Secret      string   `json:"secret,omitempty"`
+	Events      []string `json:"events" validate:"required,min=1,dive,oneof=task.created task.completed task.deleted task.overdue list.created list.deleted"`
+	ProfileID   *int     `json:"profile_id"`
+	AllProfiles bool     `json:"all_profiles"`
+	Active      *bool    `json:"active"`
+	CreatedAt   string   `json:"created_at"`
+}
+
+type WebhookDelivery struct {
+	ID             int             `json:"id"`
+	WebhookID      int             `json:"webhook_id"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus *int            `json:"response_status"`
+	Error          string          `json:"error"`
+	NextAttemptAt  string          `json:"next_attempt_at"`
+	DeliveredAt    *string         `json:"delivered_at"`
+	CreatedAt      string          `json:"created_at"`
+}
+
+type Attachment struct {
+	ID          int    `json:"id"`
+	TaskID      int    `json:"task_id"`
+	FileName    string `json:"file_name"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	StorageKey  string `json:"-"`
+	URL         string `json:"url"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// QuickAdd is what was read from the text of a quick added task. DueTime is only
+// reported back, tasks keep a date.
+type QuickAdd struct {
+	Name               string `json:"name"`
+	DueDate            string `json:"due_date,omitempty"`
+	DueTime            string `json:"due_time,omitempty"`
+	List               string `json:"list,omitempty"`
+	ListID             *int   `json:"list_id"`
+	IsImportant        bool   `json:"is_important"`
+	Priority           string `json:"priority,omitempty"`
+	RecurrencePattern  string `json:"recurrence_pattern,omitempty"`
+	RecurrenceInterval int    `json:"recurrence_interval,omitempty"`
+	Conflict           string `json:"conflict,omitempty"`
+}
+
+type QuickAddResponse struct {
+	Message string   `json:"message"`
+	ID      int      `json:"id,omitempty"`
+	Parsed  QuickAdd `json:"parsed"`
+}
+
+// TaskTemplate is a task with its sub tasks that can be created again and again.
+// Due dates are kept as a number of days after the day it is instantiated.
+type TaskTemplate struct {
+	ID            int               `json:"id"`
+	Name          string            `json:"name" validate:"required,min=3,max=1000"`
+	TaskName      string            `json:"task_name" validate:"required,min=3,max=1000"`
+	Metadata      string            `json:"metadata" validate:"max=255"`
+	Priority      string            `json:"priority" validate:"omitempty,oneof=none low medium high urgent"`
+	ListID        *int              `json:"list_id"`
+	ProfileID     *int              `json:"profile_id"`
+	DueOffsetDays *int              `json:"due_offset_days" validate:"omitempty,min=0,max=3650"`
+	SubTasks      []TemplateSubTask `json:"sub_tasks" validate:"dive"`
+	CreatedAt     string            `json:"created_at"`
+}
+
+type TemplateSubTask struct {
+	Name          string            `json:"name" validate:"required,min=3,max=1000"`
+	IsImportant   bool              `json:"is_important"`
+	DueOffsetDays *int              `json:"due_offset_days" validate:"omitempty,min=0,max=3650"`
+	SubTasks      []TemplateSubTask `json:"sub_tasks,omitempty" validate:"dive"`
+}
+
+// InstantiateTemplate overrides the list and profile of the template. Due dates
+// count from StartDate, today by default.
+type InstantiateTemplate struct {
+	ListID    *int   `json:"list_id"`
+	ProfileID *int   `json:"profile_id"`
+	StartDate string `json:"start_date"`
+}
+
+// Duplicate tells where a copied task or list goes and how it is changed. A task
+// stays in its list unless ListID is given; a task moved to another profile
+// without a list goes to the inbox of that profile.
+type Duplicate struct {
+	Name            string `json:"name" validate:"omitempty,min=3,max=1000"`
+	ListID          *int   `json:"list_id"`
+	ProfileID       *int   `json:"profile_id"`
+	ResetCompletion bool   `json:"reset_completion"`
+	ShiftDueDays    int    `json:"shift_due_days" validate:"min=-3650,max=3650"`
+}
+
+// Note is the Markdown note of a task. Version counts the saves and is the ETag
+// of the note; a task without a note has an empty one at version 0.
+type Note struct {
+	TaskID    int     `json:"task_id"`
+	Content   string  `json:"content" validate:"max=100000"`
+	Version   int64   `json:"version"`
+	UpdatedAt *string `json:"updated_at"`
+}
+
+type NoteVersion struct {
+	Version   int64  `json:"version"`
+	Content   string `json:"content"`
+	CreatedAt string `json:"created_at"`
+}
+
+// InboundAddress is a secret address of the email gateway. Mail sent to it
+// becomes a task in the profile.
+type InboundAddress struct {
+	ID        int    `json:"id"`
+	Token     string `json:"token"`
+	Address   string `json:"address"`
+	ProfileID *int   `json:"profile_id"`
+	CreatedAt string `json:"created_at"`
+}
@@ -76,4 +76,440 @@ CREATE TABLE profiles (
 );
 
 ALTER TABLE tasks ADD COLUMN profile_id INT REFERENCES profiles(id) ON DELETE SET NULL;
-ALTER TABLE lists ADD COLUMN profile_id INT REFERENCES profiles(id) ON DELETE SET NULL;
\ No newline at end of file
+ALTER TABLE lists ADD COLUMN profile_id INT REFERENCES profiles(id) ON DELETE SET NULL;
+
+CREATE TABLE change_log (
+    id BIGSERIAL PRIMARY KEY,
+    table_name TEXT NOT NULL,
+    operation VARCHAR(10) NOT NULL,
+    row_id INT NOT NULL,
+    data JSONB,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE backups (
+    id SERIAL PRIMARY KEY,
+    kind VARCHAR(10) NOT NULL,
+    last_change_id BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Restores set todo.skip_change_log so that replaying a backup does not journal itself again.
+CREATE OR REPLACE FUNCTION log_change() RETURNS TRIGGER AS $$
+BEGIN
+    IF current_setting('todo.skip_change_log', true) = 'on' THEN
+        RETURN NULL;
+    END IF;
+
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO change_log (table_name, operation, row_id, data)
+        VALUES (TG_TABLE_NAME, TG_OP, OLD.id, NULL);
+    ELSE
+        INSERT INTO change_log (table_name, operation, row_id, data)
+        VALUES (TG_TABLE_NAME, TG_OP, NEW.id, to_jsonb(NEW));
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER tasks_change_log AFTER INSERT OR UPDATE OR DELETE ON tasks
+    FOR EACH ROW EXECUTE FUNCTION log_change();
+CREATE TRIGGER sub_tasks_change_log AFTER INSERT OR UPDATE OR DELETE ON sub_tasks
+    FOR EACH ROW EXECUTE FUNCTION log_change();
+CREATE TRIGGER lists_change_log AFTER INSERT OR UPDATE OR DELETE ON lists
+    FOR EACH ROW EXECUTE FUNCTION log_change();
+CREATE TRIGGER profiles_change_log AFTER INSERT OR UPDATE OR DELETE ON profiles
+    FOR EACH ROW EXECUTE FUNCTION log_change();
+
+
+CREATE TABLE calendar_feeds (
+    id SERIAL PRIMARY KEY,
+    token TEXT NOT NULL UNIQUE,
+    profile_id INT REFERENCES profiles(id) ON DELETE CASCADE,
+    list_id INT REFERENCES lists(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+
+CREATE TABLE list_groups (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    position INT NOT NULL DEFAULT 0,
+    profile_id INT REFERENCES profiles(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE lists ADD COLUMN group_id INT REFERENCES list_groups(id) ON DELETE SET NULL;
+ALTER TABLE lists ADD COLUMN position INT NOT NULL DEFAULT 0;
+
+-- Keep the existing lists in the order they were created.
+UPDATE lists SET position = id;
+
+
+-- Manual ordering. Positions are base 62 ranks compared with COLLATE "C"; tasks that were
+-- never moved have an empty position and are shown first, newest first.
+ALTER TABLE tasks ADD COLUMN position TEXT NOT NULL DEFAULT '';
+ALTER TABLE tasks ADD COLUMN my_day_position TEXT NOT NULL DEFAULT '';
+
+
+-- Soft deletion. Deleted rows stay in the trash until the purge job removes them.
+ALTER TABLE tasks ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE sub_tasks ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE lists ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE profiles ADD COLUMN deleted_at TIMESTAMP;
+
+-- Task priorities. Enum values are ordered, so ORDER BY priority DESC puts urgent first.
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'task_priority_enum') THEN
+        CREATE TYPE task_priority_enum AS ENUM ('none', 'low', 'medium', 'high', 'urgent');
+    END IF;
+END $$;
+
+ALTER TABLE tasks ADD COLUMN priority task_priority_enum NOT NULL DEFAULT 'none';
+UPDATE tasks SET priority = 'high' WHERE is_important = true;
+
+
+-- A task is blocked while any of its blockers is incomplete.
+CREATE TABLE task_dependencies (
+    task_id INT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    blocker_id INT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (task_id, blocker_id),
+    CHECK (task_id <> blocker_id)
+);
+
+CREATE INDEX task_dependencies_blocker_id_idx ON task_dependencies (blocker_id);
+
+
+-- Sub tasks can be nested. task_id stays the top level task on every level.
+ALTER TABLE sub_tasks ADD COLUMN parent_id INT REFERENCES sub_tasks(id) ON DELETE CASCADE;
+ALTER TABLE sub_tasks ADD COLUMN due_date TEXT NOT NULL DEFAULT '';
+ALTER TABLE sub_tasks ADD COLUMN is_important BOOLEAN NOT NULL DEFAULT false;
+
+CREATE INDEX sub_tasks_parent_id_idx ON sub_tasks (parent_id);
+
+
+-- Completing the last sub task completes the task. A list without its own setting
+-- (NULL) follows its profile.
+ALTER TABLE profiles ADD COLUMN auto_complete_parent BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE lists ADD COLUMN auto_complete_parent BOOLEAN;
+
+
+-- Delta sync. Every change takes the next value of revision_seq, which orders the
+-- changes, and records the transaction that made it. Revisions are taken before
+-- commit, so a transaction can commit after a sync saw higher revisions. A sync
+-- token is therefore the oldest transaction still running at that sync, and the
+-- next sync returns every row written by that transaction or a later one.
+CREATE SEQUENCE revision_seq;
+
+ALTER TABLE tasks ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE tasks ADD COLUMN revision BIGINT NOT NULL DEFAULT nextval('revision_seq');
+ALTER TABLE tasks ADD COLUMN created_revision BIGINT;
+UPDATE tasks SET created_revision = revision;
+ALTER TABLE tasks ADD COLUMN revision_xid xid8 NOT NULL DEFAULT pg_current_xact_id();
+ALTER TABLE tasks ADD COLUMN created_xid xid8 NOT NULL DEFAULT pg_current_xact_id();
+
+ALTER TABLE sub_tasks ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE sub_tasks ADD COLUMN revision BIGINT NOT NULL DEFAULT nextval('revision_seq');
+ALTER TABLE sub_tasks ADD COLUMN created_revision BIGINT;
+UPDATE sub_tasks SET created_revision = revision;
+ALTER TABLE sub_tasks ADD COLUMN revision_xid xid8 NOT NULL DEFAULT pg_current_xact_id();
+ALTER TABLE sub_tasks ADD COLUMN created_xid xid8 NOT NULL DEFAULT pg_current_xact_id();
+
+ALTER TABLE lists ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE lists ADD COLUMN revision BIGINT NOT NULL DEFAULT nextval('revision_seq');
+ALTER TABLE lists ADD COLUMN created_revision BIGINT;
+UPDATE lists SET created_revision = revision;
+ALTER TABLE lists ADD COLUMN revision_xid xid8 NOT NULL DEFAULT pg_current_xact_id();
+ALTER TABLE lists ADD COLUMN created_xid xid8 NOT NULL DEFAULT pg_current_xact_id();
+
+ALTER TABLE profiles ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE profiles ADD COLUMN revision BIGINT NOT NULL DEFAULT nextval('revision_seq');
+ALTER TABLE profiles ADD COLUMN created_revision BIGINT;
+UPDATE profiles SET created_revision = revision;
+ALTER TABLE profiles ADD COLUMN revision_xid xid8 NOT NULL DEFAULT pg_current_xact_id();
+ALTER TABLE profiles ADD COLUMN created_xid xid8 NOT NULL DEFAULT pg_current_xact_id();
+
+CREATE OR REPLACE FUNCTION bump_revision() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.revision := nextval('revision_seq');
+    NEW.revision_xid := pg_current_xact_id();
+    NEW.updated_at := CURRENT_TIMESTAMP;
+
+    IF TG_OP = 'INSERT' THEN
+        NEW.created_revision := NEW.revision;
+        NEW.created_xid := NEW.revision_xid;
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER tasks_revision BEFORE INSERT OR UPDATE ON tasks
+    FOR EACH ROW EXECUTE FUNCTION bump_revision();
+CREATE TRIGGER sub_tasks_revision BEFORE INSERT OR UPDATE ON sub_tasks
+    FOR EACH ROW EXECUTE FUNCTION bump_revision();
+CREATE TRIGGER lists_revision BEFORE INSERT OR UPDATE ON lists
+    FOR EACH ROW EXECUTE FUNCTION bump_revision();
+CREATE TRIGGER profiles_revision BEFORE INSERT OR UPDATE ON profiles
+    FOR EACH ROW EXECUTE FUNCTION bump_revision();
+
+CREATE INDEX tasks_revision_idx ON tasks (revision);
+CREATE INDEX sub_tasks_revision_idx ON sub_tasks (revision);
+CREATE INDEX lists_revision_idx ON lists (revision);
+CREATE INDEX profiles_revision_idx ON profiles (revision);
+CREATE INDEX tasks_revision_xid_idx ON tasks (revision_xid);
+CREATE INDEX sub_tasks_revision_xid_idx ON sub_tasks (revision_xid);
+CREATE INDEX lists_revision_xid_idx ON lists (revision_xid);
+CREATE INDEX profiles_revision_xid_idx ON profiles (revision_xid);
+
+-- Rows that are removed for good (purged from the trash, promoted or demoted) leave
+-- a tombstone so that clients can drop them too.
+CREATE TABLE tombstones (
+    revision BIGINT PRIMARY KEY DEFAULT nextval('revision_seq'),
+    table_name TEXT NOT NULL,
+    row_id INT NOT NULL,
+    xid xid8 NOT NULL DEFAULT pg_current_xact_id(),
+    deleted_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX tombstones_xid_idx ON tombstones (xid);
+
+CREATE OR REPLACE FUNCTION add_tombstone() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO tombstones (table_name, row_id) VALUES (TG_TABLE_NAME, OLD.id);
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER tasks_tombstone AFTER DELETE ON tasks
+    FOR EACH ROW EXECUTE FUNCTION add_tombstone();
+CREATE TRIGGER sub_tasks_tombstone AFTER DELETE ON sub_tasks
+    FOR EACH ROW EXECUTE FUNCTION add_tombstone();
+CREATE TRIGGER lists_tombstone AFTER DELETE ON lists
+    FOR EACH ROW EXECUTE FUNCTION add_tombstone();
+CREATE TRIGGER profiles_tombstone AFTER DELETE ON profiles
+    FOR EACH ROW EXECUTE FUNCTION add_tombstone();
+
+
+-- Change events. Every write is announced on the changes channel so that each
+-- server instance can push it to the clients of the profile. Restores announce a
+-- single resync instead, and send no webhooks either.
+CREATE OR REPLACE FUNCTION notify_change() RETURNS TRIGGER AS $$
+DECLARE
+    r RECORD;
+    action TEXT;
+    profile INT;
+BEGIN
+    IF current_setting('todo.skip_change_log', true) = 'on' THEN
+        RETURN NULL;
+    END IF;
+
+    IF TG_OP = 'DELETE' THEN
+        r := OLD;
+        action := 'deleted';
+    ELSE
+        r := NEW;
+        action := CASE
+            WHEN TG_OP = 'INSERT' THEN 'created'
+            WHEN NEW.deleted_at IS NOT NULL THEN 'deleted'
+            ELSE 'updated'
+        END;
+    END IF;
+
+    IF TG_TABLE_NAME = 'profiles' THEN
+        profile := r.id;
+    ELSIF TG_TABLE_NAME = 'sub_tasks' THEN
+        SELECT profile_id INTO profile FROM tasks WHERE id = r.task_id;
+    ELSE
+        profile := r.profile_id;
+    END IF;
+
+    PERFORM pg_notify('changes', json_build_object(
+        'table', TG_TABLE_NAME,
+        'action', action,
+        'id', r.id,
+        'profile_id', profile,
+        'revision', r.revision
+    )::TEXT);
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER tasks_notify AFTER INSERT OR UPDATE OR DELETE ON tasks
+    FOR EACH ROW EXECUTE FUNCTION notify_change();
+CREATE TRIGGER sub_tasks_notify AFTER INSERT OR UPDATE OR DELETE ON sub_tasks
+    FOR EACH ROW EXECUTE FUNCTION notify_change();
+CREATE TRIGGER lists_notify AFTER INSERT OR UPDATE OR DELETE ON lists
+    FOR EACH ROW EXECUTE FUNCTION notify_change();
+CREATE TRIGGER profiles_notify AFTER INSERT OR UPDATE OR DELETE ON profiles
+    FOR EACH ROW EXECUTE FUNCTION notify_change();
+
+
+-- Outgoing webhooks. Deliveries are queued by the triggers below in the same
+-- transaction as the change, and sent by a background job.
+CREATE TABLE webhooks (
+    id SERIAL PRIMARY KEY,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    events TEXT[] NOT NULL,
+    profile_id INT REFERENCES profiles(id) ON DELETE CASCADE,
+    active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- A webhook without a profile gets the events of the rows without a profile, one
+-- with all_profiles those of every profile. Webhooks made before all_profiles
+-- existed got everything and keep doing so.
+ALTER TABLE webhooks ADD COLUMN all_profiles BOOLEAN NOT NULL DEFAULT false;
+UPDATE webhooks SET all_profiles = true WHERE profile_id IS NULL;
+
+CREATE TYPE webhook_delivery_status_enum AS ENUM ('pending', 'delivered', 'failed');
+
+CREATE TABLE webhook_deliveries (
+    id SERIAL PRIMARY KEY,
+    webhook_id INT NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+    event TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    status webhook_delivery_status_enum NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    response_status INT,
+    error TEXT NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    delivered_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX webhook_deliveries_pending_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX webhook_deliveries_webhook_idx ON webhook_deliveries (webhook_id, id);
+
+CREATE OR REPLACE FUNCTION enqueue_webhooks(event TEXT, profile INT, payload JSONB) RETURNS VOID AS $$
+BEGIN
+    INSERT INTO webhook_deliveries (webhook_id, event, payload)
+    SELECT id, event, payload
+    FROM webhooks
+    WHERE active AND event = ANY(events) AND (all_profiles OR profile_id IS NOT DISTINCT FROM profile);
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION task_webhooks() RETURNS TRIGGER AS $$
+BEGIN
+    IF current_setting('todo.skip_change_log', true) = 'on' THEN
+        RETURN NULL;
+    END IF;
+
+    IF TG_OP = 'INSERT' THEN
+        PERFORM enqueue_webhooks('task.created', NEW.profile_id, to_jsonb(NEW));
+    ELSIF NEW.deleted_at IS NOT NULL AND OLD.deleted_at IS NULL THEN
+        PERFORM enqueue_webhooks('task.deleted', NEW.profile_id, to_jsonb(NEW));
+    ELSIF NEW.completed AND NOT OLD.completed THEN
+        PERFORM enqueue_webhooks('task.completed', NEW.profile_id, to_jsonb(NEW));
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION list_webhooks() RETURNS TRIGGER AS $$
+BEGIN
+    IF current_setting('todo.skip_change_log', true) = 'on' THEN
+        RETURN NULL;
+    END IF;
+
+    IF TG_OP = 'INSERT' THEN
+        PERFORM enqueue_webhooks('list.created', NEW.profile_id, to_jsonb(NEW));
+    ELSIF NEW.deleted_at IS NOT NULL AND OLD.deleted_at IS NULL THEN
+        PERFORM enqueue_webhooks('list.deleted', NEW.profile_id, to_jsonb(NEW));
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER tasks_webhooks AFTER INSERT OR UPDATE ON tasks
+    FOR EACH ROW EXECUTE FUNCTION task_webhooks();
+CREATE TRIGGER lists_webhooks AFTER INSERT OR UPDATE ON lists
+    FOR EACH ROW EXECUTE FUNCTION list_webhooks();
+
+
+-- Files attached to tasks, e.g. by the email gateway. The content is kept outside
+-- of the database under storage_key.
+CREATE TABLE attachments (
+    id SERIAL PRIMARY KEY,
+    task_id INT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    file_name TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size BIGINT NOT NULL,
+    storage_key TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX attachments_task_idx ON attachments (task_id);
+
+
+-- Task templates. Sub tasks are kept as a JSON tree and are copied as a whole.
+CREATE TABLE task_templates (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    task_name TEXT NOT NULL,
+    metadata VARCHAR(255) NOT NULL DEFAULT '',
+    priority task_priority_enum NOT NULL DEFAULT 'none',
+    list_id INT REFERENCES lists(id) ON DELETE SET NULL,
+    profile_id INT REFERENCES profiles(id) ON DELETE CASCADE,
+    due_offset_days INT,
+    sub_tasks JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Notes. Each task has at most one Markdown note; every saved version is kept.
+CREATE TABLE task_notes (
+    task_id INT PRIMARY KEY REFERENCES tasks(id) ON DELETE CASCADE,
+    content TEXT NOT NULL,
+    version INT NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE task_note_versions (
+    task_id INT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    version INT NOT NULL,
+    content TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (task_id, version)
+);
+
+-- The stored file of a removed attachment is deleted by a background job, also
+-- when the row goes away with its task.
+CREATE TABLE attachment_deletions (
+    storage_key TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE OR REPLACE FUNCTION queue_attachment_deletion() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO attachment_deletions (storage_key) VALUES (OLD.storage_key) ON CONFLICT DO NOTHING;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER attachments_deletion AFTER DELETE ON attachments
+    FOR EACH ROW EXECUTE FUNCTION queue_attachment_deletion();
+
+-- Link previews, filled from the Open Graph tags of the page.
+ALTER TABLE url_titles
+    ADD COLUMN description TEXT,
+    ADD COLUMN image_url TEXT,
+    ADD COLUMN favicon_url TEXT;
+
+-- Secret addresses of the email gateway, tasks+<token>@domain. The token decides
+-- the profile, the envelope sender of a mail can be forged.
+CREATE TABLE inbound_addresses (
+    id SERIAL PRIMARY KEY,
+    token TEXT NOT NULL UNIQUE,
+    profile_id INT REFERENCES profiles(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);